@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"micrun/pkg/console"
+
+	"golang.org/x/term"
+)
+
+// detachKey ends an attach session, as in telnet.
+const detachKey = 0x1d // Ctrl-]
+
+const attachUsage = `usage: micrun attach <container-id> [--takeover] [--name <session>]
+
+Attach to the RTOS console of a running container, detach with Ctrl-].
+Only one session writes to the console at a time, --takeover grabs the
+input from the session (e.g. the containerd stdio) holding it.
+`
+
+// runAttach implements the attach subcommand, it returns the process exit code.
+func runAttach(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("attach", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, attachUsage) }
+	takeover := fs.Bool("takeover", false, "take the console input over from the session holding it")
+	name := fs.String("name", fmt.Sprintf("micrun-attach-%d", os.Getpid()), "session name shown to other sessions")
+
+	// flags may come before or after the container id
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+	id := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	conn, err := console.Dial(console.SocketPath(id), *name, *takeover)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(stderr, "micrun attach: container %s has no console to attach\n", id)
+		return 1
+	} else if err != nil {
+		fmt.Fprintf(stderr, "micrun attach: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	if fd := int(stdin.Fd()); term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			fmt.Fprintf(stderr, "micrun attach: %v\n", err)
+			return 1
+		}
+		defer term.Restore(fd, state)
+		fmt.Fprintf(stderr, "attached to %s, detach with Ctrl-]\r\n", id)
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(stdout, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		copyUntilDetach(conn, stdin)
+		if uc, ok := conn.(*net.UnixConn); ok {
+			uc.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+	<-done
+	return 0
+}
+
+// copyUntilDetach copies src to dst until EOF or the detach key.
+func copyUntilDetach(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			p := buf[:n]
+			i := bytes.IndexByte(p, detachKey)
+			if i >= 0 {
+				p = p[:i]
+			}
+			if _, werr := dst.Write(p); werr != nil || i >= 0 {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
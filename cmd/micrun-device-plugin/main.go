@@ -0,0 +1,46 @@
+// micrun-device-plugin advertises RTOS pCPUs (mica.openeuler.org/cpu) and the
+// allowlisted passthrough devices (mica.openeuler.org/<name>) to the kubelet.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	log "micrun/logger"
+	configstack "micrun/pkg/configstack"
+	"micrun/pkg/deviceplugin"
+	"micrun/pkg/oci"
+	"micrun/pkg/passthrough"
+	"micrun/pkg/pedestal"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func main() {
+	dir := flag.String("plugin-dir", pluginapi.DevicePluginPath, "kubelet device plugin directory")
+	flag.Parse()
+
+	stack := oci.NewRuntimeStack()
+	files, err := configstack.DiscoverMicrunConfigFiles()
+	if err != nil {
+		log.Warnf("micrun config discovery failed: %v", err)
+	}
+	stack.ApplyMicrunFiles(files)
+	cfg := stack.Config()
+	pedestal.EnableDom0CPUExclusive(cfg.ExclusiveDom0CPU)
+
+	plugins := []*deviceplugin.Plugin{deviceplugin.NewCPUPlugin(*dir, deviceplugin.ClientCPUs())}
+	if allowlist, err := passthrough.LoadAllowlist(cfg.DeviceAllowlist); err == nil {
+		plugins = append(plugins, deviceplugin.NewDevicePlugins(*dir, allowlist)...)
+	} else {
+		log.Infof("no passthrough devices advertised: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	deviceplugin.Serve(ctx, filepath.Join(*dir, filepath.Base(pluginapi.KubeletSocket)), plugins)
+}
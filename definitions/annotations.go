@@ -59,6 +59,78 @@ const (
 	ContainerMinMemMB = ContainerPrefix + "min_memory_mb"
 	// ContainerMaxVcpuNum allows overriding the runtime max_vcpu_num for micad create messages.
 	ContainerMaxVcpuNum = ContainerPrefix + "max_vcpu_num"
+	// ContainerIOMem lists io memory regions passed through to the client: "fe200,1;fe20d,1"
+	// (hex start page frame and hex page number, as in xl iomem).
+	ContainerIOMem = ContainerPrefix + "iomem"
+	// ContainerIRQs lists host interrupts passed through to the client: "42,43".
+	// micad can not deliver them yet, a container asking for them is rejected.
+	ContainerIRQs = ContainerPrefix + "irqs"
+	// ContainerDTDev lists device tree nodes passed through to the client: "/soc/can@fe200000".
+	// micad can not deliver them yet, a container asking for them is rejected.
+	ContainerDTDev = ContainerPrefix + "dtdev"
+	// ContainerCPUSet lists the pCPUs allocated by micrun-device-plugin, it overrides linux.resources.cpu.cpus.
+	ContainerCPUSet = ContainerPrefix + "cpuset"
+	// ContainerDevicePrefix prefixes devices allocated by micrun-device-plugin, one annotation per
+	// device: "<prefix><name>" = "iomem=fe200,1 irqs=42 dtdev=/soc/can@fe200000".
+	ContainerDevicePrefix = ContainerPrefix + "device."
+	// ContainerExecPrompt overrides the regex matching the client shell prompt, used to find the end of exec output.
+	ContainerExecPrompt = ContainerPrefix + "exec_prompt"
+	// ContainerExecSentinel is a shell command printing its argument (e.g. "echo"), when set exec output
+	// ends at an echoed sentinel instead of the prompt.
+	ContainerExecSentinel = ContainerPrefix + "exec_sentinel"
+	// ContainerExecTimeout bounds a single exec command in seconds, default to be 30.
+	ContainerExecTimeout = ContainerPrefix + "exec_timeout"
+	// ContainerResizeCommand is a shell command typed into the client on each terminal resize,
+	// "{rows}" and "{cols}" are replaced by the size, e.g. "resize" for zephyr or "stty rows {rows} cols {cols}".
+	// It overrides the resize command of the OS profile, an empty value disables it, without
+	// either only the host PTY is resized.
+	ContainerResizeCommand = ContainerPrefix + "resize_command"
+	// ContainerConsoleKeys translates keys typed on the container stdin: "^C=forward,^\=SIGKILL,~.=detach".
+	// Actions are forward, drop, detach or a signal name, multi-character keys are escapes typed at line start.
+	ContainerConsoleKeys = ContainerPrefix + "console_keys"
+	// ContainerSignals translates signals sent to the container: "SIGINT=^C,SIGTERM=shutdown,SIGHUP=ignore".
+	// Actions are stop, ignore, a control key, shutdown, reboot, stats, crashdump or cmd:<command>.
+	ContainerSignals = ContainerPrefix + "signals"
+	// ContainerStopTimeout is the grace period in seconds of a graceful shutdown before the client
+	// is destroyed, default to be 10.
+	ContainerStopTimeout = ContainerPrefix + "stop_timeout"
+	// ContainerRestartPolicy restarts a client which went down by itself in place: never, on-failure
+	// (the client crashed) or always, default to be never.
+	ContainerRestartPolicy = ContainerPrefix + "restart_policy"
+	// ContainerRestartMaxRetries bounds the restarts of the restart policy, 0 means no limit.
+	ContainerRestartMaxRetries = ContainerPrefix + "restart_max_retries"
+	// ContainerRestartBackoff is the delay in seconds before the first restart, doubled for every
+	// following restart, default to be 1.
+	ContainerRestartBackoff = ContainerPrefix + "restart_backoff"
+	// ContainerReady waits for the client to boot before the start returns: console (the output
+	// matches ContainerReadyPattern), rpmsg (endpoints announced to micad) or xenstore (domain running).
+	ContainerReady = ContainerPrefix + "ready"
+	// ContainerReadyPattern overrides the regex matching the console of a booted client,
+	// default to be the boot banner of the OS or its shell prompt.
+	ContainerReadyPattern = ContainerPrefix + "ready_pattern"
+	// ContainerReadyTimeout bounds the boot in seconds, default to be 30.
+	ContainerReadyTimeout = ContainerPrefix + "ready_timeout"
+	// ContainerReadyTimeoutAction is fail (the start fails, default) or mark (the start returns
+	// at once, the task exits as failed when the client is not ready in time).
+	ContainerReadyTimeoutAction = ContainerPrefix + "ready_timeout_action"
+	// ContainerExitSuccess is a regex matched against the console lines, a match stops the client
+	// and the task exits 0, e.g. "PROJECT EXECUTION SUCCESSFUL" of zephyr test images.
+	ContainerExitSuccess = ContainerPrefix + "exit_success"
+	// ContainerExitFailure is a regex matched against the console lines, a match stops the client
+	// and the task exits 1, e.g. "PROJECT EXECUTION FAILED".
+	ContainerExitFailure = ContainerPrefix + "exit_failure"
+	// ContainerExitCodeGroup names (or numbers) the capture group of the exit regexes holding the
+	// exit status, default to be "code": "exit status (?P<code>[0-9]+)".
+	ContainerExitCodeGroup = ContainerPrefix + "exit_code_group"
+	// ContainerRestartBackoffMax caps the delay between restarts in seconds, default to be 300.
+	ContainerRestartBackoffMax = ContainerPrefix + "restart_backoff_max"
+	// ContainerRestartCount is the label of the containerd container carrying the number of
+	// restarts in place, set by the shim after every restart.
+	ContainerRestartCount = ContainerPrefix + "restart_count"
+	// ContainerAdopt names a client already running at micad, e.g. started by `mica start` at boot or
+	// a dom0less domain micad tracks. The container takes it over without a reboot when its firmware
+	// (FirmwareHash, or the hash of the image firmware) and resources match the container.
+	ContainerAdopt = ContainerPrefix + "adopt"
 )
 
 const (
@@ -72,7 +144,8 @@ const (
 	RuntimeDebug = RuntimePrefix + "debug"
 	// RuntimeExclusiveDom0CPU toggles whether Dom0 CPUs are kept exclusive (Xen).
 	RuntimeExclusiveDom0CPU = RuntimePrefix + "exclusive_dom0_cpu"
-	// TODO: implement the logic binding vpuc number and size(cpusetUnion of pcpu)
+	// VCPUBinding enables 1:1 binding: vcpu N is pinned to the Nth cpu of the container cpuset,
+	// and the vcpu number equals the cpuset size.
 	VCPUBinding = RuntimePrefix + "vcpu_pcpu_binding"
 )
 
@@ -15,10 +15,16 @@ const (
 	// the external state directory for a container, which containers cached rootfs and serialized states
 	MicrunStateDir            = "/run/micrun"
 	DefaultMicaContainersRoot = "/run/micrun/containers"
-	MicrunContainerStateFile  = "state.json"
-	SandboxStateFile          = "state.json"
-	// directory for sandbox data storage
-	SandboxDataDir = "/run/micrun/sandbox"
+	// directory of the sandbox and container records, see pkg/store
+	SandboxDataDir    = "/run/micrun/sandbox"
+	SandboxStateFile  = "sandbox.json"
+	ContainerStateDir = "containers"
+	TaskStateDir      = "tasks"
+	// record file of micrun before pkg/store: <SandboxDataDir>/<sandbox>/state.json
+	// and <MicrunStateDir>/<sandbox>/<container>/state.json, moved on load
+	LegacyStateFile = "state.json"
+	// persistent copy of the records kept across node reboots, see persist_records
+	PersistentDataDir = "/var/lib/micrun/sandbox"
 
 	// Micrun configuration (INI today, easy to switch to TOML later).
 	MicrunConfDir    = "/etc/mica/micrun"
@@ -1,7 +1,10 @@
 package errors
 
 import (
+	"context"
 	"fmt"
+
+	"github.com/containerd/containerd/errdefs"
 )
 
 // TODO: refactor this packages
@@ -18,6 +21,28 @@ func (e *MicrunErr) Error() string {
 	return fmt.Sprintf("[%d] %s", e.Code, e.Msg)
 }
 
+// Unwrap maps the error onto the containerd error class of its code, so that
+// errdefs.ToGRPC reports a proper code to containerd instead of Unknown.
+func (e *MicrunErr) Unwrap() error {
+	switch e.Code {
+	case invalid, duplicatedKey, parseFailed:
+		return errdefs.ErrInvalidArgument
+	case notFound:
+		return errdefs.ErrNotFound
+	case alreadyExists:
+		return errdefs.ErrAlreadyExists
+	case notSupported:
+		return errdefs.ErrNotImplemented
+	case invalidState, unexpectedStatus:
+		return errdefs.ErrFailedPrecondition
+	case micadFailed, micadAbnormal, socketFailed:
+		return errdefs.ErrUnavailable
+	case timeout:
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
 func new(code ErrCode, msg string) *MicrunErr {
 	return &MicrunErr{
 		Code: code,
@@ -39,6 +64,7 @@ const (
 	notSupported
 	micadAbnormal
 	parseFailed
+	timeout
 )
 
 // Pre-defined errors.
@@ -64,6 +90,7 @@ var (
 	MicaSocketDown  = new(micadAbnormal, "mica-create socket is not alive")
 	NotSupported    = new(notSupported, "micran or mica does not support this")
 	InvalidSignal   = new(invalid, "invalid signal for client os")
+	ClientNotReady  = new(timeout, "client os is not ready")
 )
 
 // Type errors
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"micrun/pkg/gc"
+)
+
+const gcUsage = `usage: micrun gc [--dry-run] [--min-age <duration>] [--address <containerd socket>]
+
+Remove what failed teardowns left on the host: sandbox records, micad clients,
+xen domains, firmware caches and netns holders of containers that containerd
+no longer runs in any namespace, e.g.
+  micrun gc --dry-run
+  micrun gc --min-age 30m
+`
+
+// runGC implements the gc subcommand, it returns the process exit code.
+func runGC(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, gcUsage) }
+	dryRun := fs.Bool("dry-run", false, "only report the orphans")
+	minAge := fs.Duration("min-age", gc.DefaultMinAge, "spare leftovers younger than this")
+	address := fs.String("address", gc.DefaultAddress, "grpc socket of containerd")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	host, err := gc.Scan(ctx, *address)
+	if err != nil {
+		fmt.Fprintf(stderr, "micrun gc: %v\n", err)
+		return 1
+	}
+
+	code := 0
+	for _, o := range host.Orphans(time.Now(), *minAge) {
+		if *dryRun {
+			fmt.Fprintf(stdout, "would remove %s\n", o)
+			continue
+		}
+		if err := gc.Remove(ctx, o); err != nil {
+			fmt.Fprintf(stderr, "micrun gc: failed to remove %s: %v\n", o, err)
+			code = 1
+			continue
+		}
+		fmt.Fprintf(stdout, "removed %s\n", o)
+	}
+	return code
+}
@@ -9,6 +9,7 @@ require (
 	github.com/containerd/fifo v1.1.0
 	github.com/containerd/typeurl/v2 v2.1.1
 	github.com/containers/podman/v4 v4.9.5
+	github.com/docker/go-units v0.5.0
 	github.com/gogo/protobuf v1.3.2
 	github.com/gookit/ini/v2 v2.3.2
 	github.com/hashicorp/go-multierror v1.1.1
@@ -27,6 +28,7 @@ require (
 	golang.org/x/term v0.29.0
 	google.golang.org/grpc v1.59.0
 	google.golang.org/protobuf v1.35.2
+	k8s.io/kubelet v0.24.2
 )
 
 require (
@@ -47,7 +49,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/distribution/reference v0.6.0 // indirect
 	github.com/docker/go-events v0.0.0-20190806004212-e31b211e4f1c // indirect
-	github.com/docker/go-units v0.5.0 // indirect
 	github.com/felixge/httpsnoop v1.0.3 // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/go-logr/logr v1.4.2 // indirect
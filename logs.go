@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"micrun/pkg/console"
+)
+
+const logsUsage = `usage: micrun logs <container-id> [--follow] [--since <duration|RFC3339 time>]
+
+Print the RTOS console recorded for a container, e.g.
+  micrun logs rtos0 --since 10m
+  micrun logs rtos0 -f --since 2026-01-02T15:04:05Z
+`
+
+// runLogs implements the logs subcommand, it returns the process exit code.
+func runLogs(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, logsUsage) }
+	follow := fs.Bool("follow", false, "keep printing new console output")
+	fs.BoolVar(follow, "f", false, "shorthand for --follow")
+	sinceFlag := fs.String("since", "", "only show output newer than a duration (e.g. 10m) or an RFC3339 time")
+
+	// flags may come before or after the container id
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+	id := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	since, err := parseSince(*sinceFlag, time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "micrun logs: %v\n", err)
+		return 2
+	}
+
+	show := func(e console.Entry) {
+		fmt.Fprintln(stdout, e.String())
+	}
+	path := console.Path(id)
+	if *follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = console.Follow(ctx, path, since, show)
+	} else {
+		err = console.Read(path, since, show)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(stderr, "micrun logs: no console recorded for container %s\n", id)
+		return 1
+	} else if err != nil {
+		fmt.Fprintf(stderr, "micrun logs: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want a duration or an RFC3339 time", v)
+}
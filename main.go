@@ -15,6 +15,17 @@ import (
 var ShimName string
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "logs":
+			os.Exit(runLogs(os.Args[2:], os.Stdout, os.Stderr))
+		case "attach":
+			os.Exit(runAttach(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "gc":
+			os.Exit(runGC(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	if err := log.CleanDebugFile(); err != nil {
 		log.Errorf("failed to clean debug file: %v", err)
 	}
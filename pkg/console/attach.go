@@ -0,0 +1,119 @@
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+
+	defs "micrun/definitions"
+	log "micrun/logger"
+)
+
+// SocketName is the attach socket in the container state directory.
+const SocketName = "console.sock"
+
+const (
+	modeAttach   = "attach"
+	modeTakeOver = "takeover"
+)
+
+// SocketPath returns the attach socket of a container.
+func SocketPath(id string) string {
+	return filepath.Join(defs.DefaultMicaContainersRoot, id, SocketName)
+}
+
+// Dial opens an attach session on the socket at path. The session receives the
+// console output, its input is written to the console once it holds the input,
+// takeover acquires the input from the session holding it.
+func Dial(path, name string, takeover bool) (net.Conn, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	mode := modeAttach
+	if takeover {
+		mode = modeTakeOver
+	}
+	if _, err := fmt.Fprintf(conn, "%s %s\n", mode, name); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Serve runs attach sessions accepted from l until it is closed.
+func (h *Hub) Serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Debugf("console attach socket closed: %v", err)
+			}
+			return
+		}
+		go h.serveConn(conn)
+	}
+}
+
+func (h *Hub) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	mode, name, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if mode != modeAttach && mode != modeTakeOver {
+		fmt.Fprintf(conn, "micrun: unknown attach mode %q\r\n", mode)
+		return
+	}
+	if name == "" {
+		name = "attach"
+	}
+
+	out := h.Attach(name)
+	defer out.Close()
+	in := h.Input(name)
+	defer in.Close()
+	if mode == modeTakeOver {
+		in.TakeOver()
+	}
+	log.Debugf("console session %s attached (%s)", name, mode)
+
+	go func() {
+		// the console ended or the session was dropped
+		if _, err := io.Copy(conn, out); err != nil {
+			fmt.Fprintf(conn, "\r\nmicrun: %v\r\n", err)
+		}
+		conn.Close()
+	}()
+
+	buf := make([]byte, 1024)
+	notified := false
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := in.Write(buf[:n]); werr != nil {
+				if !errors.Is(werr, ErrInputBusy) && !errors.Is(werr, ErrInputTakenOver) {
+					log.Debugf("console session %s input: %v", name, werr)
+					return
+				}
+				// tell the user once why the keys are ignored
+				if !notified {
+					fmt.Fprintf(conn, "\r\nmicrun: %v, input ignored\r\n", werr)
+					notified = true
+				}
+			} else {
+				notified = false
+			}
+		}
+		if err != nil {
+			log.Debugf("console session %s detached", name)
+			return
+		}
+	}
+}
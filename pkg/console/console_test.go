@@ -0,0 +1,541 @@
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func fakeClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func readAll(t *testing.T, path string, since time.Time) []Entry {
+	t.Helper()
+	var entries []Entry
+	if err := Read(path, since, func(e Entry) { entries = append(entries, e) }); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	return entries
+}
+
+func TestLogRecordsLinesAndSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rtos", FileName)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	l, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = fakeClock(start)
+	if session, err := l.BootSession(); err != nil || session != 1 {
+		t.Fatalf("BootSession() = %d, %v", session, err)
+	}
+	l.Write([]byte("NuttShell (NSH)\r\nnsh> he"))
+	l.Write([]byte("lp\r\n"))
+	l.Write([]byte("partial"))
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted shim keeps counting sessions
+	l, err = Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = fakeClock(start.Add(time.Minute))
+	if session, _ := l.BootSession(); session != 2 {
+		t.Fatalf("second boot session = %d, want 2", session)
+	}
+	l.Write([]byte("rebooted\n"))
+	l.Close()
+
+	var got []string
+	for _, e := range readAll(t, path, time.Time{}) {
+		got = append(got, e.String())
+	}
+	want := []string{
+		"--- boot session 1 at " + start.Add(time.Second).Local().Format(time.RFC3339) + " ---",
+		"NuttShell (NSH)",
+		"nsh> help",
+		"partial",
+		"--- boot session 2 at " + start.Add(time.Minute+time.Second).Local().Format(time.RFC3339) + " ---",
+		"rebooted",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("entries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	since := readAll(t, path, start.Add(time.Minute))
+	if len(since) != 2 || !since[0].Boot || since[1].Text != "rebooted" {
+		t.Fatalf("entries since second boot = %+v", since)
+	}
+}
+
+func TestLogRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	const retention = 1024
+	l, err := Open(path, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := l.Write([]byte("0123456789abcdef\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Write([]byte("last line\n"))
+	l.Close()
+
+	var total int64
+	for _, p := range []string{path, rotatedPath(path)} {
+		st, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += st.Size()
+	}
+	if total > retention {
+		t.Fatalf("console log uses %d bytes, retention is %d", total, retention)
+	}
+	entries := readAll(t, path, time.Time{})
+	if entries[len(entries)-1].Text != "last line" {
+		t.Fatalf("newest entry = %+v", entries[len(entries)-1])
+	}
+}
+
+func TestFollowAcrossRotation(t *testing.T) {
+	old := followInterval
+	followInterval = 10 * time.Millisecond
+	defer func() { followInterval = old }()
+
+	path := filepath.Join(t.TempDir(), FileName)
+	l, err := Open(path, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Write([]byte("before follow\n"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string, 64)
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(ctx, path, time.Time{}, func(e Entry) { lines <- e.Text })
+	}()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Fatalf("followed %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q was not followed", want)
+		}
+	}
+	expect("before follow")
+	// every line is big enough to rotate the 256 bytes log
+	for _, line := range []string{"line one with some padding to rotate the log", "line two with some padding to rotate the log",
+		"line three with some padding to rotate the log"} {
+		l.Write([]byte(line + "\n"))
+		expect(line)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+}
+
+func TestHubKeepsRecordingWithoutReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	l, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, feed := io.Pipe()
+	h := NewHub(l, io.Discard)
+	finished := make(chan struct{})
+	go func() {
+		h.Run(src)
+		close(finished)
+	}()
+
+	feed.Write([]byte("unattached\n"))
+	// wait until the unattached output is recorded before attaching
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if raw, _ := os.ReadFile(path); strings.Contains(string(raw), "unattached") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("unattached output was not recorded")
+		}
+	}
+	first, second := h.Attach("fifo"), h.Attach("attach")
+	feed.Write([]byte("attached\n"))
+	buf := make([]byte, 64)
+	for _, reader := range []io.Reader{first, second} {
+		if n, err := reader.Read(buf); err != nil || string(buf[:n]) != "attached\n" {
+			t.Fatalf("attached reader got %q, %v", buf[:n], err)
+		}
+	}
+	// the containerd side goes away, the console must not block
+	first.Close()
+	feed.Write([]byte("detached\n"))
+	feed.Close()
+	<-finished
+	l.Close()
+
+	var got []string
+	for _, e := range readAll(t, path, time.Time{}) {
+		got = append(got, e.Text)
+	}
+	if strings.Join(got, ",") != "unattached,attached,detached" {
+		t.Fatalf("recorded %v", got)
+	}
+	if all, err := io.ReadAll(second); err != nil || string(all) != "detached\n" {
+		t.Fatalf("remaining reader got %q, %v", all, err)
+	}
+	if _, err := h.Attach("late").Read(buf); err != io.EOF {
+		t.Fatalf("attach after the console ended should return EOF, got %v", err)
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	src, feed := io.Pipe()
+	h := NewHub(nil, io.Discard)
+	finished := make(chan struct{})
+	go func() {
+		h.Run(src)
+		close(finished)
+	}()
+
+	slow := h.Subscribe("slow", 1)
+	fast := h.Subscribe("fast", 4)
+	feed.Write([]byte("first"))
+	// the slow subscriber is full, the console must not block on it
+	feed.Write([]byte("second"))
+	for _, want := range []string{"first", "second"} {
+		if got := string(<-fast.C); got != want {
+			t.Fatalf("fast subscriber got %q, want %q", got, want)
+		}
+	}
+	if got := string(<-slow.C); got != "first" {
+		t.Fatalf("slow subscriber got %q", got)
+	}
+	if _, ok := <-slow.C; ok || !errors.Is(slow.Err(), ErrSlowSubscriber) {
+		t.Fatalf("slow subscriber was not dropped: %v", slow.Err())
+	}
+	slow.Close()
+
+	feed.Close()
+	<-finished
+	// the subscriber is closed when the console ends
+	if _, ok := <-fast.C; ok || fast.Err() != nil {
+		t.Fatalf("fast subscriber after the console ended: %v", fast.Err())
+	}
+	fast.Close()
+}
+
+func TestHubInput(t *testing.T) {
+	var console bytes.Buffer
+	h := NewHub(nil, &console)
+	fifo, attach, exec := h.Input("fifo"), h.Input("attach"), h.Input("exec")
+
+	if _, err := fifo.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attach.Write([]byte("b")); !errors.Is(err, ErrInputBusy) {
+		t.Fatalf("second writer got %v", err)
+	}
+	attach.TakeOver()
+	if _, err := attach.Write([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fifo.Write([]byte("d")); !errors.Is(err, ErrInputTakenOver) {
+		t.Fatalf("previous holder got %v", err)
+	}
+	if err := exec.Lock(); !errors.Is(err, ErrInputBusy) {
+		t.Fatalf("Lock() = %v", err)
+	}
+	attach.Close()
+	// released, the first writer gets it again
+	if _, err := fifo.Write([]byte("e")); err != nil {
+		t.Fatal(err)
+	}
+	if console.String() != "ace" {
+		t.Fatalf("console input %q", console.String())
+	}
+}
+
+func TestHubInputBorrow(t *testing.T) {
+	var console bytes.Buffer
+	h := NewHub(nil, &console)
+	stdio, attach, exec := h.Input("stdio"), h.Input("attach"), h.Input("exec")
+
+	if _, err := stdio.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	giveBack := exec.Borrow()
+	if _, err := exec.Write([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdio.Write([]byte("x")); !errors.Is(err, ErrInputTakenOver) {
+		t.Fatalf("lender got %v", err)
+	}
+	giveBack()
+	if _, err := exec.Write([]byte("x")); !errors.Is(err, ErrInputBusy) {
+		t.Fatalf("borrower after giving back got %v", err)
+	}
+	if _, err := stdio.Write([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a lender closed meanwhile does not get the input back
+	giveBack = exec.Borrow()
+	stdio.Close()
+	giveBack()
+	if _, err := attach.Write([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	if console.String() != "abcd" {
+		t.Fatalf("console input %q", console.String())
+	}
+}
+
+func TestHubServe(t *testing.T) {
+	src, feed := io.Pipe()
+	var mu sync.Mutex
+	var console bytes.Buffer
+	h := NewHub(nil, writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return console.Write(p)
+	}))
+	go h.Run(src)
+	defer feed.Close()
+
+	sock := filepath.Join(t.TempDir(), SocketName)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go h.Serve(l)
+
+	first, err := Dial(sock, "first", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	first.Write([]byte("ls\n"))
+	waitFor := func(want string) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+			mu.Lock()
+			got := console.String()
+			mu.Unlock()
+			if got == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("console input %q, want %q", got, want)
+			}
+		}
+	}
+	waitFor("ls\n")
+
+	second, err := Dial(sock, "second", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.Write([]byte("ps\n"))
+	waitFor("ls\nps\n")
+
+	// both sessions see the output, the one whose input was taken over is told so
+	feed.Write([]byte("output\n"))
+	for _, conn := range []net.Conn{first, second} {
+		r := bufio.NewReader(conn)
+		if line, err := r.ReadString('\n'); err != nil || line != "output\n" {
+			t.Fatalf("session got %q, %v", line, err)
+		}
+		if conn == first {
+			first.Write([]byte("ignored\n"))
+			if line, err := r.ReadString('\n'); err != nil || strings.TrimSpace(line) != "" {
+				t.Fatalf("session got %q, %v", line, err)
+			}
+			if line, err := r.ReadString('\n'); err != nil || !strings.Contains(line, ErrInputTakenOver.Error()) {
+				t.Fatalf("session got %q, %v", line, err)
+			}
+		}
+	}
+	waitFor("ls\nps\n")
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// fakeShell echoes every input line and answers it like a client shell would,
+// the output is split in small chunks as it comes from a uart.
+func fakeShell(t *testing.T, prompt string, answer func(cmd string) string) (io.WriteCloser, <-chan []byte) {
+	t.Helper()
+	in, input := io.Pipe()
+	out := make(chan []byte, 64)
+	send := func(s string) {
+		for len(s) > 0 {
+			n := min(len(s), 5)
+			out <- []byte(s[:n])
+			s = s[n:]
+		}
+	}
+	go func() {
+		defer close(out)
+		send("*** Booting ***\r\n" + prompt)
+		sc := bufio.NewScanner(in)
+		for sc.Scan() {
+			cmd := sc.Text()
+			send(cmd + "\r\n" + answer(cmd) + "\x1b[1;32m" + prompt + "\x1b[m")
+		}
+	}()
+	t.Cleanup(func() { input.Close() })
+	return input, out
+}
+
+func TestShellPrompt(t *testing.T) {
+	in, out := fakeShell(t, "uart:~$ ", func(cmd string) string {
+		switch cmd {
+		case "kernel version":
+			return "Zephyr version 3.6.0\r\n"
+		default:
+			return cmd + ": command not found\r\n"
+		}
+	})
+	sh := &Shell{
+		Prompt:  regexp.MustCompile(`uart:~\$ ?$`),
+		Errors:  []ShellError{{Pattern: regexp.MustCompile(`command not found`), Code: 127}},
+		Timeout: 5 * time.Second,
+	}
+	// let the boot banner arrive, it must not leak into the output
+	time.Sleep(10 * time.Millisecond)
+
+	var buf bytes.Buffer
+	code, err := sh.Run(context.Background(), in, out, []string{"kernel", "version"}, &buf)
+	if err != nil || code != 0 || buf.String() != "Zephyr version 3.6.0\n" {
+		t.Fatalf("Run() = %d, %v, output %q", code, err, buf.String())
+	}
+
+	buf.Reset()
+	code, err = sh.Run(context.Background(), in, out, []string{"reboot"}, &buf)
+	if err != nil || code != 127 || buf.String() != "reboot: command not found\n" {
+		t.Fatalf("Run() = %d, %v, output %q", code, err, buf.String())
+	}
+}
+
+func TestShellSentinelAndStatus(t *testing.T) {
+	status := "0"
+	in, out := fakeShell(t, "# ", func(cmd string) string {
+		switch {
+		case cmd == "false":
+			status = "1"
+			return ""
+		case cmd == "echo $?":
+			return status + "\r\n"
+		case strings.HasPrefix(cmd, "echo "):
+			return strings.TrimPrefix(cmd, "echo ") + "\r\n"
+		}
+		status = "0"
+		return "line one\r\n# not a prompt\r\n"
+	})
+	sh := &Shell{SentinelCommand: "echo", StatusCommand: "echo $?", Timeout: 5 * time.Second}
+
+	var buf bytes.Buffer
+	code, err := sh.Run(context.Background(), in, out, []string{"cat", "/proc/version"}, &buf)
+	if err != nil || code != 0 || buf.String() != "line one\n# not a prompt\n" {
+		t.Fatalf("Run() = %d, %v, output %q", code, err, buf.String())
+	}
+	buf.Reset()
+	if code, err := sh.Run(context.Background(), in, out, []string{"false"}, &buf); err != nil || code != 1 || buf.Len() != 0 {
+		t.Fatalf("Run(false) = %d, %v, output %q", code, err, buf.String())
+	}
+}
+
+func TestShellTimeoutAndClose(t *testing.T) {
+	out := make(chan []byte)
+	sh := &Shell{Prompt: regexp.MustCompile(`> $`), Timeout: 20 * time.Millisecond}
+	if _, err := sh.Run(context.Background(), io.Discard, out, []string{"hang"}, io.Discard); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() on a silent console = %v", err)
+	}
+	close(out)
+	if _, err := sh.Run(context.Background(), io.Discard, out, []string{"hang"}, io.Discard); !errors.Is(err, ErrConsoleClosed) {
+		t.Fatalf("Run() on a closed console = %v", err)
+	}
+}
+
+// openPTYPair returns the master of a new pty and the path of its slave.
+func openPTYPair(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pty available: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Skipf("unlockpt: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Skipf("ptsname: %v", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestPTYResize(t *testing.T) {
+	master, path := openPTYPair(t)
+	p, err := OpenPTY(path)
+	if err != nil {
+		t.Skipf("OpenPTY: %v", err)
+	}
+	defer p.Close()
+
+	if err := p.Resize(40, 132); err != nil {
+		t.Fatal(err)
+	}
+	if rows, cols, err := p.Size(); err != nil || rows != 40 || cols != 132 {
+		t.Fatalf("Size() = %d, %d, %v", rows, cols, err)
+	}
+	// the size is seen from the client side of the pty as well
+	ws, err := unix.IoctlGetWinsize(int(master.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Row != 40 || ws.Col != 132 {
+		t.Fatalf("master size = %+v, %v", ws, err)
+	}
+
+	// raw mode: no echo and no newline translation
+	master.Write([]byte("uart:~$ \n"))
+	buf := make([]byte, 64)
+	n, err := p.Read(buf)
+	if err != nil || string(buf[:n]) != "uart:~$ \n" {
+		t.Fatalf("Read() = %q, %v", buf[:n], err)
+	}
+}
@@ -0,0 +1,311 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	log "micrun/logger"
+)
+
+var (
+	// ErrSlowSubscriber ends a subscriber which did not keep up with the console output.
+	ErrSlowSubscriber = errors.New("console subscriber too slow, dropped")
+	// ErrInputBusy is returned when another session holds the console input.
+	ErrInputBusy = errors.New("console input is held by another session")
+	// ErrInputTakenOver is returned to a session whose console input was taken over.
+	ErrInputTakenOver = errors.New("console input was taken over")
+)
+
+var errNoInput = errors.New("console input is not connected")
+
+// SubscriberBuffer is the number of output chunks a subscriber may lag behind
+// before it is dropped.
+const SubscriberBuffer = 256
+
+// Hub owns the client console. It records the output into a Log and fans it
+// out to any number of subscribers (containerd stdio, attach sessions, exec),
+// the RTOS output never waits for them: subscribers that fall behind are dropped.
+//
+// The console input has a single writer at a time, the first session writing
+// holds it until it closes its Input, unless another session takes it over or
+// borrows it for a command, e.g. exec.
+type Hub struct {
+	log *Log
+	in  io.Writer
+	// inMu serializes writes to in.
+	inMu sync.Mutex
+
+	mu     sync.Mutex
+	subs   map[*Subscriber]struct{}
+	holder *Input
+	done   bool
+}
+
+// NewHub records into l and writes the console input to in, l may be nil when
+// the console log is unavailable and in when the console is not connected yet.
+func NewHub(l *Log, in io.Writer) *Hub {
+	return &Hub{log: l, in: in, subs: make(map[*Subscriber]struct{})}
+}
+
+// SetInput connects the console input, e.g. once the client PTY is opened.
+func (h *Hub) SetInput(in io.Writer) {
+	h.inMu.Lock()
+	defer h.inMu.Unlock()
+	h.in = in
+}
+
+// Run copies src until EOF or error, then ends all subscribers.
+func (h *Hub) Run(src io.Reader) {
+	h.Pipe(src)
+	h.End()
+}
+
+// Pipe copies src until EOF or error and keeps the subscribers, so that the
+// console of the next boot can be piped into the same hub.
+func (h *Hub) Pipe(src io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if h.log != nil {
+				if _, werr := h.log.Write(buf[:n]); werr != nil {
+					log.Debugf("failed to record console output: %v", werr)
+				}
+			}
+			h.forward(buf[:n])
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Debugf("console ended: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// End ends all subscribers, the hub takes no new ones.
+func (h *Hub) End() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.done = true
+	for sub := range h.subs {
+		sub.end(nil)
+	}
+}
+
+// Done tells whether the hub was ended.
+func (h *Hub) Done() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.done
+}
+
+func (h *Hub) forward(p []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		select {
+		case sub.ch <- append([]byte(nil), p...):
+		default:
+			log.Warnf("console subscriber %s is too slow, dropping it", sub.name)
+			sub.end(ErrSlowSubscriber)
+		}
+	}
+}
+
+// Subscriber receives the console output on C, which is closed when the
+// console ends or the subscriber is dropped.
+type Subscriber struct {
+	C <-chan []byte
+
+	name string
+	hub  *Hub
+	ch   chan []byte
+	err  error
+}
+
+// Subscribe adds a subscriber buffering up to size output chunks.
+func (h *Hub) Subscribe(name string, size int) *Subscriber {
+	ch := make(chan []byte, size)
+	sub := &Subscriber{C: ch, name: name, hub: h, ch: ch}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done {
+		close(ch)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// end is called with hub.mu held.
+func (s *Subscriber) end(err error) {
+	delete(s.hub.subs, s)
+	s.err = err
+	close(s.ch)
+}
+
+// Err tells why C was closed: ErrSlowSubscriber, or nil when the console ended.
+func (s *Subscriber) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close unsubscribes.
+func (s *Subscriber) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		s.end(nil)
+	}
+}
+
+// Attach returns a reader of the live console output, closing it unsubscribes.
+// It returns EOF when the console ends and ErrSlowSubscriber if it is dropped.
+func (h *Hub) Attach(name string) io.ReadCloser {
+	return &subscriberReader{sub: h.Subscribe(name, SubscriberBuffer)}
+}
+
+type subscriberReader struct {
+	sub *Subscriber
+	buf []byte
+}
+
+func (r *subscriberReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		chunk, ok := <-r.sub.C
+		if !ok {
+			if err := r.sub.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		r.buf = chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *subscriberReader) Close() error {
+	r.sub.Close()
+	return nil
+}
+
+// Input is the console input of a session.
+type Input struct {
+	name string
+	hub  *Hub
+	// takenOver is set while another session holds the input taken from this one.
+	takenOver bool
+}
+
+// Input returns the console input of the session name, it holds nothing until
+// the first write, Lock or TakeOver.
+func (h *Hub) Input(name string) *Input {
+	return &Input{name: name, hub: h}
+}
+
+// Lock acquires the console input, it fails with ErrInputBusy if another session holds it.
+func (i *Input) Lock() error {
+	h := i.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case h.holder == i:
+		return nil
+	case h.holder == nil:
+		h.holder = i
+		i.takenOver = false
+		return nil
+	case i.takenOver:
+		return fmt.Errorf("%w by %s", ErrInputTakenOver, h.holder.name)
+	default:
+		return fmt.Errorf("%w: %s", ErrInputBusy, h.holder.name)
+	}
+}
+
+// TakeOver acquires the console input even when another session holds it.
+func (i *Input) TakeOver() {
+	h := i.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if prev := h.holder; prev != nil && prev != i {
+		log.Infof("console input taken over by %s from %s", i.name, prev.name)
+		prev.takenOver = true
+	}
+	h.holder = i
+	i.takenOver = false
+}
+
+// Borrow takes the console input over for the duration of a command, the
+// returned function gives it back to the session it was taken from.
+func (i *Input) Borrow() (giveBack func()) {
+	h := i.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prev := h.holder
+	if prev == i {
+		prev = nil
+	}
+	if prev != nil {
+		log.Debugf("console input borrowed by %s from %s", i.name, prev.name)
+		prev.takenOver = true
+	}
+	h.holder = i
+	i.takenOver = false
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.holder != i {
+			return
+		}
+		h.holder = nil
+		// a session closed meanwhile does not get it back
+		if prev != nil && prev.takenOver {
+			h.holder = prev
+			prev.takenOver = false
+		}
+	}
+}
+
+// Write writes p to the console if the session holds, or can acquire, the input.
+func (i *Input) Write(p []byte) (int, error) {
+	if err := i.Lock(); err != nil {
+		return 0, err
+	}
+	h := i.hub
+	h.inMu.Lock()
+	defer h.inMu.Unlock()
+	if h.in == nil {
+		return 0, errNoInput
+	}
+	return h.in.Write(p)
+}
+
+// Inject writes on behalf of the host, e.g. ^C or a shutdown command for a
+// signal, whichever session holds the input.
+func (h *Hub) Inject(p []byte) error {
+	h.inMu.Lock()
+	defer h.inMu.Unlock()
+	if h.in == nil {
+		return errNoInput
+	}
+	_, err := h.in.Write(p)
+	return err
+}
+
+// Close releases the console input.
+func (i *Input) Close() error {
+	h := i.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.holder == i {
+		h.holder = nil
+	}
+	i.takenOver = false
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package console keeps the RTOS console output of each container on disk, so
+// output produced while nobody is attached can still be read by `micrun logs`.
+//
+// The log is a bounded ring made of two segments, console.log and console.log.1.
+// Once console.log reaches half of the retention size it replaces console.log.1.
+// Every line is a record "<RFC3339Nano timestamp> <kind> <text>", where kind is
+// "out" for console output and "boot" for the boot session markers written on
+// every client start.
+//
+// The Hub owns the live console: it feeds the log, the containerd stdio, exec
+// and `micrun attach` sessions, see hub.go.
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	defs "micrun/definitions"
+)
+
+const (
+	// FileName is the console log name in the container state directory.
+	FileName = "console.log"
+	// DefaultRetention is the default on-disk budget of a console log.
+	DefaultRetention int64 = 1 << 20
+
+	kindOut  = "out"
+	kindBoot = "boot"
+
+	// maxLine splits console output that never ends a line.
+	maxLine = 4096
+)
+
+// Path returns the console log of a container.
+func Path(id string) string {
+	return filepath.Join(defs.DefaultMicaContainersRoot, id, FileName)
+}
+
+func rotatedPath(path string) string {
+	return path + ".1"
+}
+
+// Log is the writer side of a console log, safe for concurrent use.
+type Log struct {
+	mu        sync.Mutex
+	path      string
+	retention int64
+	f         *os.File
+	size      int64
+	session   int
+
+	pending   []byte
+	pendingAt time.Time
+
+	now func() time.Time
+}
+
+// Open opens the console log at path for append. A retention <= 0 uses DefaultRetention.
+func Open(path string, retention int64) (*Log, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	if err := os.MkdirAll(filepath.Dir(path), defs.DirMode); err != nil {
+		return nil, fmt.Errorf("failed to create console log dir: %w", err)
+	}
+	l := &Log{path: path, retention: retention, now: time.Now}
+	l.session = lastSession(path)
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defs.FileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open console log: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = st.Size()
+	return nil
+}
+
+// BootSession starts a new boot session and returns its number.
+func (l *Log) BootSession() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.flush(); err != nil {
+		return 0, err
+	}
+	l.session++
+	return l.session, l.record(l.now(), kindBoot, strconv.Itoa(l.session))
+}
+
+// Write records console output line by line, a trailing partial line is kept
+// until it is completed, the next boot session or Close.
+func (l *Log) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, os.ErrClosed
+	}
+	rest := p
+	for len(rest) > 0 {
+		if len(l.pending) == 0 {
+			l.pendingAt = l.now()
+		}
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			l.pending = append(l.pending, rest...)
+			if len(l.pending) >= maxLine {
+				if err := l.flush(); err != nil {
+					return len(p) - len(rest), err
+				}
+			}
+			break
+		}
+		l.pending = append(l.pending, rest[:i]...)
+		rest = rest[i+1:]
+		if err := l.flush(); err != nil {
+			return len(p) - len(rest), err
+		}
+	}
+	return len(p), nil
+}
+
+func (l *Log) flush() error {
+	if len(l.pending) == 0 {
+		return nil
+	}
+	text := strings.TrimRight(string(l.pending), "\r")
+	l.pending = l.pending[:0]
+	return l.record(l.pendingAt, kindOut, text)
+}
+
+func (l *Log) record(at time.Time, kind, text string) error {
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	line := at.UTC().Format(time.RFC3339Nano) + " " + kind + " " + text + "\n"
+	if l.size > 0 && l.size+int64(len(line)) > l.retention/2 {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write([]byte(line))
+	l.size += int64(n)
+	return err
+}
+
+func (l *Log) rotate() error {
+	l.f.Close()
+	l.f = nil
+	if err := os.Rename(l.path, rotatedPath(l.path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to rotate console log: %w", err)
+	}
+	return l.open()
+}
+
+// Close flushes a pending partial line and closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.flush()
+	err = errors.Join(err, l.f.Close())
+	l.f = nil
+	return err
+}
+
+// lastSession finds the latest boot session recorded in the log, so that a
+// restarted shim keeps counting.
+func lastSession(path string) int {
+	last := 0
+	for _, p := range []string{rotatedPath(path), path} {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, maxLine), 4*maxLine)
+		for scanner.Scan() {
+			if e, ok := parseEntry(scanner.Text()); ok && e.Boot {
+				last = e.Session
+			}
+		}
+		f.Close()
+	}
+	return last
+}
@@ -0,0 +1,60 @@
+package console
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// PTY is the host side of a client console, the rpmsg PTY of micad or the
+// Xen console PTY.
+type PTY struct {
+	*os.File
+}
+
+// OpenPTY opens the console PTY at path in raw mode, micrun must not cook the
+// RTOS console.
+func OpenPTY(path string) (*PTY, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(f.Fd())
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a terminal: %w", path, err)
+	}
+	// cfmakeraw(3)
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to set %s raw: %w", path, err)
+	}
+	return &PTY{File: f}, nil
+}
+
+// Resize sets the window size of the PTY (TIOCSWINSZ).
+func (p *PTY) Resize(rows, cols uint16) error {
+	ws := &unix.Winsize{Row: rows, Col: cols}
+	if err := unix.IoctlSetWinsize(int(p.Fd()), unix.TIOCSWINSZ, ws); err != nil {
+		return fmt.Errorf("failed to resize %s: %w", p.Name(), err)
+	}
+	return nil
+}
+
+// Size returns the window size of the PTY.
+func (p *PTY) Size() (rows, cols uint16, err error) {
+	ws, err := unix.IoctlGetWinsize(int(p.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ws.Row, ws.Col, nil
+}
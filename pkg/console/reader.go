@@ -0,0 +1,141 @@
+package console
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// followInterval is how often Follow polls the log for new records.
+var followInterval = 200 * time.Millisecond
+
+// Entry is one record of a console log.
+type Entry struct {
+	Time time.Time
+	// Boot marks the start of boot session Session, Text is empty.
+	Boot    bool
+	Session int
+	Text    string
+}
+
+// String renders the entry for `micrun logs`.
+func (e Entry) String() string {
+	if e.Boot {
+		return fmt.Sprintf("--- boot session %d at %s ---", e.Session, e.Time.Local().Format(time.RFC3339))
+	}
+	return e.Text
+}
+
+func parseEntry(line string) (Entry, bool) {
+	ts, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return Entry{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return Entry{}, false
+	}
+	kind, text, _ := strings.Cut(rest, " ")
+	switch kind {
+	case kindOut:
+		return Entry{Time: at, Text: text}, true
+	case kindBoot:
+		session, err := strconv.Atoi(text)
+		if err != nil {
+			return Entry{}, false
+		}
+		return Entry{Time: at, Boot: true, Session: session}, true
+	}
+	return Entry{}, false
+}
+
+// Read calls fn for every record of the log at path newer than since, oldest first.
+func Read(path string, since time.Time, fn func(Entry)) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	for _, p := range []string{rotatedPath(path), path} {
+		f, err := os.Open(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		_, err = readRecords(bufio.NewReader(f), "", since, fn)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Follow is Read, then keeps calling fn for new records until ctx is done.
+// It survives rotation of the log.
+func Follow(ctx context.Context, path string, since time.Time, fn func(Entry)) error {
+	if rotated, err := os.Open(rotatedPath(path)); err == nil {
+		_, err = readRecords(bufio.NewReader(rotated), "", since, fn)
+		rotated.Close()
+		if err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	r := bufio.NewReader(f)
+	partial := ""
+	for {
+		if partial, err = readRecords(r, partial, since, fn); err != nil {
+			return err
+		}
+
+		// The writer rotated the log: finish the old file, continue with the new one.
+		if cur, err := f.Stat(); err == nil {
+			if onDisk, err := os.Stat(path); err == nil && !os.SameFile(cur, onDisk) {
+				next, err := os.Open(path)
+				if err == nil {
+					if partial, err = readRecords(r, partial, since, fn); err != nil {
+						next.Close()
+						return err
+					}
+					f.Close()
+					f, r, partial = next, bufio.NewReader(next), ""
+					continue
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+// readRecords reads complete lines until EOF and returns the trailing partial line.
+func readRecords(r *bufio.Reader, partial string, since time.Time, fn func(Entry)) (string, error) {
+	for {
+		line, err := r.ReadString('\n')
+		partial += line
+		if err == io.EOF {
+			return partial, nil
+		} else if err != nil {
+			return partial, err
+		}
+		if e, ok := parseEntry(strings.TrimSuffix(partial, "\n")); ok && !e.Time.Before(since) {
+			fn(e)
+		}
+		partial = ""
+	}
+}
@@ -0,0 +1,196 @@
+package console
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrConsoleClosed is returned when the console ends while a shell command is running.
+var ErrConsoleClosed = errors.New("console closed")
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// ShellError maps an error message printed by the client shell to an exit status.
+type ShellError struct {
+	Pattern *regexp.Regexp
+	Code    int
+}
+
+// Shell drives the interactive shell of a client OS over its console, the way
+// exec is emulated for RTOS clients which have no processes.
+//
+// The client shell is expected to echo its input, as shells on a serial console
+// do: the command output starts after the echo of the command line. The end of
+// the output is found either by an echoed sentinel, when SentinelCommand is set,
+// or by the shell prompt.
+type Shell struct {
+	// Prompt matches the shell prompt, which is not followed by a newline.
+	Prompt *regexp.Regexp
+	// SentinelCommand prints its argument on a line, e.g. "echo".
+	SentinelCommand string
+	// StatusCommand prints the exit status of the previous command, e.g. "echo $?".
+	// Without it the status is derived from Errors.
+	StatusCommand string
+	// Errors are checked against every output line when there is no StatusCommand.
+	Errors []ShellError
+	// Timeout bounds every command, zero means no limit.
+	Timeout time.Duration
+}
+
+// Run writes args as one command line to in and copies the command output
+// received from out to w. It returns the exit status of the command.
+func (sh *Shell) Run(ctx context.Context, in io.Writer, out <-chan []byte, args []string, w io.Writer) (int, error) {
+	if len(args) == 0 {
+		return -1, fmt.Errorf("empty command")
+	}
+	if sh.Prompt == nil && sh.SentinelCommand == "" {
+		return -1, fmt.Errorf("neither a prompt nor a sentinel is configured to detect the end of output")
+	}
+	if sh.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sh.Timeout)
+		defer cancel()
+	}
+
+	r := &shellReader{out: out}
+	// drop whatever is left from a previous command or the boot banner
+	r.drain()
+
+	code := 0
+	cmd := strings.Join(args, " ")
+	err := sh.command(ctx, in, r, cmd, func(line string) error {
+		if sh.StatusCommand == "" && code == 0 {
+			for _, e := range sh.Errors {
+				if e.Pattern.MatchString(line) {
+					code = e.Code
+					break
+				}
+			}
+		}
+		_, err := io.WriteString(w, line+"\n")
+		return err
+	})
+	if err != nil || sh.StatusCommand == "" {
+		return code, err
+	}
+
+	var lines []string
+	if err := sh.command(ctx, in, r, sh.StatusCommand, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}); err != nil {
+		return -1, err
+	}
+	for _, line := range lines {
+		if status, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+			return status, nil
+		}
+	}
+	return -1, fmt.Errorf("unexpected output of %q: %q", sh.StatusCommand, lines)
+}
+
+// command runs one command line and hands every output line to fn.
+func (sh *Shell) command(ctx context.Context, in io.Writer, r *shellReader, cmd string, fn func(string) error) error {
+	input := cmd + "\n"
+	var token string
+	if sh.SentinelCommand != "" {
+		token = fmt.Sprintf("__micrun_exec_%d__", time.Now().UnixNano())
+		input += sh.SentinelCommand + " " + token + "\n"
+	}
+	if _, err := io.WriteString(in, input); err != nil {
+		return fmt.Errorf("failed to write to the console: %w", err)
+	}
+
+	echoed := false
+	for {
+		line, complete, err := r.next(ctx)
+		if err != nil {
+			return err
+		}
+		if !complete {
+			// a prompt is printed without newline, it only counts after the echo of the command
+			if token == "" && echoed && sh.Prompt.MatchString(line) {
+				return nil
+			}
+			continue
+		}
+		if token != "" {
+			if line == token {
+				return nil
+			}
+			if strings.Contains(line, token) {
+				continue
+			}
+		}
+		if !echoed {
+			// output starts after the echo of the command, anything before is left
+			// from the boot or from a previous command
+			echoed = strings.HasSuffix(strings.TrimSpace(line), cmd)
+			continue
+		}
+		if token == "" && sh.Prompt.MatchString(line) {
+			// a bare prompt line, e.g. an empty command echoed by the shell
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+}
+
+// shellReader splits the console output into lines without ANSI escapes.
+type shellReader struct {
+	out     <-chan []byte
+	pending string
+}
+
+func (r *shellReader) drain() {
+	for {
+		select {
+		case _, ok := <-r.out:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next complete line, or the pending partial line when more
+// output is needed to complete it.
+func (r *shellReader) next(ctx context.Context) (string, bool, error) {
+	if line, ok := r.line(); ok {
+		return line, true, nil
+	}
+	select {
+	case p, ok := <-r.out:
+		if !ok {
+			return "", false, ErrConsoleClosed
+		}
+		// escapes may be split across reads, strip them from the whole pending output
+		r.pending = strings.ReplaceAll(ansiEscape.ReplaceAllString(r.pending+string(p), ""), "\r", "")
+		if line, ok := r.line(); ok {
+			return line, true, nil
+		}
+		return r.pending, false, nil
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+}
+
+func (r *shellReader) line() (string, bool) {
+	i := strings.IndexByte(r.pending, '\n')
+	if i < 0 {
+		return "", false
+	}
+	line := r.pending[:i]
+	r.pending = r.pending[i+1:]
+	return line, true
+}
@@ -0,0 +1,202 @@
+// Package deviceplugin advertises RTOS pCPUs and passthrough devices to the kubelet,
+// so that the scheduler only places RTOS pods on nodes with free client resources.
+//
+// Allocations are handed to micrun through container annotations: the cpu resource
+// sets defs.ContainerCPUSet, each passthrough device sets defs.ContainerDevicePrefix+<name>.
+// Both are consumed by oci.ParseContainerCfg.
+package deviceplugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "micrun/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const dialTimeout = 5 * time.Second
+
+// Plugin serves one extended resource on its own socket in the device plugin directory.
+type Plugin struct {
+	resource string
+	socket   string
+	devices  []*pluginapi.Device
+	// allocate builds the response for the device IDs requested by one container.
+	allocate func(ids []string) (*pluginapi.ContainerAllocateResponse, error)
+
+	server *grpc.Server
+	stop   chan struct{}
+}
+
+// Resource returns the extended resource name, e.g. mica.openeuler.org/cpu.
+func (p *Plugin) Resource() string {
+	return p.resource
+}
+
+// Socket returns the path of the plugin socket.
+func (p *Plugin) Socket() string {
+	return p.socket
+}
+
+// Start serves the plugin socket and registers the resource to the kubelet.
+func (p *Plugin) Start(kubeletSocket string) error {
+	if err := os.Remove(p.socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket %s: %w", p.socket, err)
+	}
+	lis, err := net.Listen("unix", p.socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", p.socket, err)
+	}
+
+	p.server = grpc.NewServer()
+	p.stop = make(chan struct{})
+	pluginapi.RegisterDevicePluginServer(p.server, p)
+	go func(server *grpc.Server) {
+		if err := server.Serve(lis); err != nil {
+			log.Debugf("device plugin %s stopped serving: %v", p.resource, err)
+		}
+	}(p.server)
+
+	if err := p.register(kubeletSocket); err != nil {
+		p.Stop()
+		return err
+	}
+	log.Infof("device plugin %s registered with %d devices", p.resource, len(p.devices))
+	return nil
+}
+
+// Stop closes the plugin socket, pending ListAndWatch streams return.
+func (p *Plugin) Stop() {
+	if p.server == nil {
+		return
+	}
+	close(p.stop)
+	p.server.Stop()
+	p.server = nil
+	if err := os.Remove(p.socket); err != nil && !os.IsNotExist(err) {
+		log.Debugf("failed to remove socket %s: %v", p.socket, err)
+	}
+}
+
+func (p *Plugin) register(kubeletSocket string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, "unix://"+kubeletSocket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("failed to connect to kubelet %s: %w", kubeletSocket, err)
+	}
+	defer conn.Close()
+
+	_, err = pluginapi.NewRegistrationClient(conn).Register(ctx, &pluginapi.RegisterRequest{
+		Version:      pluginapi.Version,
+		Endpoint:     filepath.Base(p.socket),
+		ResourceName: p.resource,
+		Options:      &pluginapi.DevicePluginOptions{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register %s: %w", p.resource, err)
+	}
+	return nil
+}
+
+// GetDevicePluginOptions implements pluginapi.DevicePluginServer.
+func (p *Plugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return &pluginapi.DevicePluginOptions{}, nil
+}
+
+// ListAndWatch implements pluginapi.DevicePluginServer. The inventory is static for
+// the plugin lifetime, it is sent once and the stream is held until the plugin stops.
+func (p *Plugin) ListAndWatch(_ *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) error {
+	if err := stream.Send(&pluginapi.ListAndWatchResponse{Devices: p.devices}); err != nil {
+		return err
+	}
+	select {
+	case <-p.stop:
+	case <-stream.Context().Done():
+	}
+	return nil
+}
+
+// GetPreferredAllocation implements pluginapi.DevicePluginServer, no preference is given.
+func (p *Plugin) GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	return &pluginapi.PreferredAllocationResponse{}, nil
+}
+
+// Allocate implements pluginapi.DevicePluginServer.
+func (p *Plugin) Allocate(_ context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	resp := &pluginapi.AllocateResponse{}
+	for _, creq := range req.ContainerRequests {
+		for _, id := range creq.DevicesIDs {
+			if !p.hasDevice(id) {
+				return nil, fmt.Errorf("unknown %s device %q", p.resource, id)
+			}
+		}
+		cresp, err := p.allocate(creq.DevicesIDs)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("allocated %s %v: %v", p.resource, creq.DevicesIDs, cresp.Annotations)
+		resp.ContainerResponses = append(resp.ContainerResponses, cresp)
+	}
+	return resp, nil
+}
+
+// PreStartContainer implements pluginapi.DevicePluginServer, it is not requested by the options.
+func (p *Plugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	return &pluginapi.PreStartContainerResponse{}, nil
+}
+
+func (p *Plugin) hasDevice(id string) bool {
+	for _, dev := range p.devices {
+		if dev.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// HealthCheckInterval is how often Serve checks that the kubelet still knows the plugins.
+var HealthCheckInterval = 5 * time.Second
+
+// Serve starts the plugins and keeps them registered until ctx is done. The kubelet
+// removes every socket of the device plugin directory when it restarts, so a missing
+// socket means the plugins have to be started and registered again.
+func Serve(ctx context.Context, kubeletSocket string, plugins []*Plugin) {
+	start := func() {
+		for _, p := range plugins {
+			if p.server != nil {
+				if _, err := os.Stat(p.socket); err == nil {
+					continue
+				}
+				log.Infof("socket of %s is gone, kubelet restarted", p.resource)
+				p.Stop()
+			}
+			if err := p.Start(kubeletSocket); err != nil {
+				log.Warnf("device plugin %s: %v", p.resource, err)
+			}
+		}
+	}
+
+	start()
+	ticker := time.NewTicker(HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			for _, p := range plugins {
+				p.Stop()
+			}
+			return
+		case <-ticker.C:
+			start()
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package deviceplugin
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	defs "micrun/definitions"
+	"micrun/pkg/passthrough"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// fakeKubelet records registrations like the kubelet device manager.
+type fakeKubelet struct {
+	pluginapi.UnimplementedRegistrationServer
+	requests chan *pluginapi.RegisterRequest
+}
+
+func (k *fakeKubelet) Register(_ context.Context, req *pluginapi.RegisterRequest) (*pluginapi.Empty, error) {
+	k.requests <- req
+	return &pluginapi.Empty{}, nil
+}
+
+func startFakeKubelet(t *testing.T, dir string) (*fakeKubelet, string) {
+	t.Helper()
+	socket := filepath.Join(dir, "kubelet.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &fakeKubelet{requests: make(chan *pluginapi.RegisterRequest, 8)}
+	server := grpc.NewServer()
+	pluginapi.RegisterRegistrationServer(server, k)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return k, socket
+}
+
+func (k *fakeKubelet) expectRegister(t *testing.T, resource string) *pluginapi.RegisterRequest {
+	t.Helper()
+	select {
+	case req := <-k.requests:
+		if req.ResourceName != resource || req.Version != pluginapi.Version {
+			t.Fatalf("unexpected registration %+v, want resource %s", req, resource)
+		}
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not registered", resource)
+	}
+	return nil
+}
+
+func dialPlugin(t *testing.T, dir, endpoint string) pluginapi.DevicePluginClient {
+	t.Helper()
+	conn, err := grpc.Dial("unix://"+filepath.Join(dir, endpoint), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pluginapi.NewDevicePluginClient(conn)
+}
+
+func TestCPUPlugin(t *testing.T) {
+	dir := t.TempDir()
+	kubelet, kubeletSocket := startFakeKubelet(t, dir)
+
+	p := NewCPUPlugin(dir, []int{2, 3, 4})
+	if err := p.Start(kubeletSocket); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer p.Stop()
+	req := kubelet.expectRegister(t, CPUResource)
+	client := dialPlugin(t, dir, req.Endpoint)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.ListAndWatch(ctx, &pluginapi.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("ListAndWatch: %v", err)
+	}
+	if len(list.Devices) != 3 || list.Devices[0].ID != "2" || list.Devices[0].Health != pluginapi.Healthy {
+		t.Fatalf("unexpected devices %v", list.Devices)
+	}
+
+	resp, err := client.Allocate(ctx, &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"4", "2", "3"}}},
+	})
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+	if got := resp.ContainerResponses[0].Annotations[defs.ContainerCPUSet]; got != "2-4" {
+		t.Fatalf("cpuset annotation = %q, want 2-4", got)
+	}
+
+	if _, err := client.Allocate(ctx, &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"0"}}},
+	}); err == nil {
+		t.Fatal("allocating a Linux cpu should fail")
+	}
+}
+
+func TestDevicePlugins(t *testing.T) {
+	dir := t.TempDir()
+	kubelet, kubeletSocket := startFakeKubelet(t, dir)
+
+	allowlistPath := filepath.Join(dir, "devices.allow")
+	content := "can0 iomem=fe200,1 irqs=42 dtdev=/soc/can@fe200000\nbad/name irqs=50\n"
+	if err := os.WriteFile(allowlistPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	al, err := passthrough.LoadAllowlist(allowlistPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plugins := NewDevicePlugins(dir, al)
+	if len(plugins) != 1 {
+		t.Fatalf("got %d plugins, want only can0", len(plugins))
+	}
+	p := plugins[0]
+	if err := p.Start(kubeletSocket); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer p.Stop()
+	req := kubelet.expectRegister(t, ResourceDomain+"/can0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := dialPlugin(t, dir, req.Endpoint).Allocate(ctx, &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"can0"}}},
+	})
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+	value := resp.ContainerResponses[0].Annotations[defs.ContainerDevicePrefix+"can0"]
+	a, err := passthrough.Decode(value)
+	if err != nil {
+		t.Fatalf("Decode(%q): %v", value, err)
+	}
+	if a.Encode() != al["can0"].Encode() {
+		t.Fatalf("allocated %q, want %q", a.Encode(), al["can0"].Encode())
+	}
+}
+
+func TestServeReregistersAfterKubeletRestart(t *testing.T) {
+	old := HealthCheckInterval
+	HealthCheckInterval = 50 * time.Millisecond
+	defer func() { HealthCheckInterval = old }()
+
+	dir := t.TempDir()
+	kubelet, kubeletSocket := startFakeKubelet(t, dir)
+	p := NewCPUPlugin(dir, []int{1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Serve(ctx, kubeletSocket, []*Plugin{p})
+		close(done)
+	}()
+	kubelet.expectRegister(t, CPUResource)
+
+	// The kubelet wipes plugin sockets on restart.
+	if err := os.Remove(p.Socket()); err != nil {
+		t.Fatal(err)
+	}
+	kubelet.expectRegister(t, CPUResource)
+
+	cancel()
+	<-done
+	if _, err := os.Stat(p.Socket()); !os.IsNotExist(err) {
+		t.Fatalf("socket should be removed on exit, stat err = %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package deviceplugin
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	defs "micrun/definitions"
+	log "micrun/logger"
+	"micrun/pkg/cpuset"
+	"micrun/pkg/passthrough"
+	"micrun/pkg/pedestal"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	// ResourceDomain is the vendor domain of the extended resources.
+	ResourceDomain = "mica.openeuler.org"
+	// CPUResource counts the pCPUs that can be pinned to RTOS clients.
+	CPUResource = ResourceDomain + "/cpu"
+
+	cpuSocketName = "micrun-cpu.sock"
+)
+
+// resource names must be valid qualified names, e.g. mica.openeuler.org/can0.
+var deviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+
+// ClientCPUs lists the pCPUs RTOS clients can use. Linux keeps the lowest CPUs
+// (Dom0 vCPUs or the baremetal reservation), see pedestal.ClientCPUCapacity.
+func ClientCPUs() []int {
+	inv := pedestal.HostCPUCounts()
+	capacity := pedestal.ClientCPUCapacity()
+	if capacity > inv.Physical {
+		capacity = inv.Physical
+	}
+	cpus := make([]int, 0, capacity)
+	for cpu := inv.Physical - capacity; cpu < inv.Physical; cpu++ {
+		cpus = append(cpus, int(cpu))
+	}
+	return cpus
+}
+
+// NewCPUPlugin advertises one mica.openeuler.org/cpu device per client pCPU. The
+// allocated pCPUs become the container cpuset.
+func NewCPUPlugin(dir string, cpus []int) *Plugin {
+	devices := make([]*pluginapi.Device, 0, len(cpus))
+	for _, cpu := range cpus {
+		devices = append(devices, &pluginapi.Device{ID: strconv.Itoa(cpu), Health: pluginapi.Healthy})
+	}
+	return &Plugin{
+		resource: CPUResource,
+		socket:   filepath.Join(dir, cpuSocketName),
+		devices:  devices,
+		allocate: allocateCPUs,
+	}
+}
+
+func allocateCPUs(ids []string) (*pluginapi.ContainerAllocateResponse, error) {
+	cpus := make([]int, 0, len(ids))
+	for _, id := range ids {
+		cpu, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu device %q: %w", id, err)
+		}
+		cpus = append(cpus, cpu)
+	}
+	return &pluginapi.ContainerAllocateResponse{
+		Annotations: map[string]string{
+			defs.ContainerCPUSet: cpuset.NewCPUSet(cpus...).String(),
+		},
+	}, nil
+}
+
+// NewDevicePlugins advertises every allowlisted device as its own resource,
+// mica.openeuler.org/<name>, holding a single device.
+func NewDevicePlugins(dir string, al passthrough.Allowlist) []*Plugin {
+	names := make([]string, 0, len(al))
+	for name := range al {
+		if !deviceNamePattern.MatchString(name) {
+			log.Warnf("allowlisted device %q is not a valid resource name, not advertised", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	plugins := make([]*Plugin, 0, len(names))
+	for _, name := range names {
+		name, assignment := name, al[name]
+		plugins = append(plugins, &Plugin{
+			resource: ResourceDomain + "/" + name,
+			socket:   filepath.Join(dir, "micrun-"+name+".sock"),
+			devices:  []*pluginapi.Device{{ID: name, Health: pluginapi.Healthy}},
+			allocate: func([]string) (*pluginapi.ContainerAllocateResponse, error) {
+				return &pluginapi.ContainerAllocateResponse{
+					Annotations: map[string]string{
+						defs.ContainerDevicePrefix + name: assignment.Encode(),
+					},
+				}, nil
+			},
+		})
+	}
+	return plugins
+}
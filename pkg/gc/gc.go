@@ -0,0 +1,222 @@
+// Package gc finds what micrun leaves behind on the host when a teardown does
+// not complete: sandbox records, micad clients, pinned xen domains, firmware
+// caches and network namespace holders of containers containerd no longer
+// runs. What the host runs is cross-referenced with the containerd tasks of
+// every namespace, leftovers are reported as orphans and can be removed.
+package gc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultMinAge spares leftovers younger than it, containerd lists a task
+// only once its create returned while micrun already wrote its records.
+const DefaultMinAge = 5 * time.Minute
+
+// RecordMaxAge spares the persistent records of a workload that is not running
+// for that long, the pod is expected back once the node rebooted.
+const RecordMaxAge = 7 * 24 * time.Hour
+
+// Kind is the kind of an orphan.
+type Kind string
+
+const (
+	KindSandbox Kind = "sandbox"
+	KindClient  Kind = "client"
+	KindDomain  Kind = "domain"
+	KindCache   Kind = "cache"
+	KindHolder  Kind = "netns-holder"
+	KindRecord  Kind = "record"
+)
+
+// kinds is the order orphans are reported and removed in, a sandbox is torn
+// down first as it takes its clients, caches and holder with it.
+var kinds = []Kind{KindSandbox, KindClient, KindDomain, KindCache, KindHolder, KindRecord}
+
+// Orphan is a leftover of a container containerd does not run.
+type Orphan struct {
+	Kind Kind
+	// ID is the id of the sandbox, client, domain or cache, the sandbox a
+	// holder holds the network namespace for, or the workload of a record.
+	ID string
+	// Pid is the pid of a holder.
+	Pid int
+	// Containers are the containers recorded in an orphan sandbox.
+	Containers []string
+}
+
+func (o Orphan) String() string {
+	switch o.Kind {
+	case KindSandbox:
+		if len(o.Containers) > 0 {
+			return fmt.Sprintf("%s %s (containers: %s)", o.Kind, o.ID, strings.Join(o.Containers, ", "))
+		}
+	case KindHolder:
+		return fmt.Sprintf("%s %d of sandbox %s", o.Kind, o.Pid, o.ID)
+	}
+	return fmt.Sprintf("%s %s", o.Kind, o.ID)
+}
+
+// Sandbox is a sandbox recorded in the store.
+type Sandbox struct {
+	Containers []string
+	HolderPid  int
+	Modified   time.Time
+	// Workload names the pod across re-creations, empty without persist_records.
+	Workload string
+}
+
+// Host is what the host runs and what micrun recorded on it.
+type Host struct {
+	// Tasks are the ids of the containerd tasks of all namespaces.
+	Tasks map[string]bool
+	// Sandboxes are the sandbox records by sandbox id.
+	Sandboxes map[string]Sandbox
+	// Clients are the ids of the clients registered at micad.
+	Clients map[string]bool
+	// Domains are the names of the pedestal domains.
+	Domains map[string]bool
+	// Caches are the firmware cache dirs by container id with their mtime.
+	Caches map[string]time.Time
+	// Holders are the sandbox ids of the netns holder processes by pid.
+	Holders map[int]string
+	// Started are the start times of the holders by pid.
+	Started map[int]time.Time
+	// Records are the persistent records by workload with their mtime.
+	Records map[string]time.Time
+}
+
+// Orphans returns the leftovers on h older than minAge at now.
+//
+// Only what micrun created is considered: a client or a domain is an orphan
+// when micrun recorded or cached a container of its name. Clients started
+// outside of micrun, e.g. by systemd at boot, are never touched.
+func (h *Host) Orphans(now time.Time, minAge time.Duration) []Orphan {
+	old := func(t time.Time) bool { return !t.IsZero() && now.Sub(t) >= minAge }
+
+	// ids of live sandboxes and their containers, and ids taken care of by
+	// the teardown of an orphan sandbox
+	live := make(map[string]bool)
+	covered := make(map[string]bool)
+	coveredPids := make(map[int]bool)
+	// the last sign of life of a container id left by micrun
+	traces := make(map[string]time.Time)
+	trace := func(id string, t time.Time) {
+		if t.After(traces[id]) {
+			traces[id] = t
+		}
+	}
+
+	var orphans []Orphan
+	for id, sb := range h.Sandboxes {
+		ids := append([]string{id}, sb.Containers...)
+		for _, cid := range ids {
+			trace(cid, sb.Modified)
+		}
+		if h.anyTask(ids) {
+			for _, cid := range ids {
+				live[cid] = true
+			}
+			continue
+		}
+		if !old(sb.Modified) {
+			// a sandbox being created, nothing of it is collected
+			for _, cid := range ids {
+				live[cid] = true
+			}
+			continue
+		}
+		orphans = append(orphans, Orphan{Kind: KindSandbox, ID: id, Containers: sortedCopy(sb.Containers)})
+		for _, cid := range ids {
+			covered[cid] = true
+		}
+		if sb.HolderPid > 0 {
+			coveredPids[sb.HolderPid] = true
+		}
+	}
+	for id, t := range h.Caches {
+		trace(id, t)
+	}
+
+	// a leftover of container id, which is neither running nor torn down with its sandbox
+	stale := func(id string) bool {
+		return !h.Tasks[id] && !live[id] && !covered[id] && old(traces[id])
+	}
+
+	for id := range h.Clients {
+		if stale(id) {
+			orphans = append(orphans, Orphan{Kind: KindClient, ID: id})
+		}
+	}
+	for id := range h.Domains {
+		// micad takes the domain of a client with it
+		if !h.Clients[id] && stale(id) {
+			orphans = append(orphans, Orphan{Kind: KindDomain, ID: id})
+		}
+	}
+	for id := range h.Caches {
+		if stale(id) {
+			orphans = append(orphans, Orphan{Kind: KindCache, ID: id})
+		}
+	}
+	for pid, id := range h.Holders {
+		if coveredPids[pid] || h.Tasks[id] || live[id] || !old(h.Started[pid]) {
+			continue
+		}
+		orphans = append(orphans, Orphan{Kind: KindHolder, ID: id, Pid: pid})
+	}
+
+	workloads := make(map[string]bool)
+	for _, sb := range h.Sandboxes {
+		if sb.Workload != "" {
+			workloads[sb.Workload] = true
+		}
+	}
+	for workload, t := range h.Records {
+		// kept for a pod re-created after a node reboot, which did not come back
+		if !workloads[workload] && !t.IsZero() && now.Sub(t) >= max(minAge, RecordMaxAge) {
+			orphans = append(orphans, Orphan{Kind: KindRecord, ID: workload})
+		}
+	}
+
+	sortOrphans(orphans)
+	return orphans
+}
+
+func (h *Host) anyTask(ids []string) bool {
+	for _, id := range ids {
+		if h.Tasks[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortOrphans(orphans []Orphan) {
+	rank := make(map[Kind]int, len(kinds))
+	for i, k := range kinds {
+		rank[k] = i
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		a, b := orphans[i], orphans[j]
+		if a.Kind != b.Kind {
+			return rank[a.Kind] < rank[b.Kind]
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Pid < b.Pid
+	})
+}
+
+func sortedCopy(ids []string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	out := append([]string(nil), ids...)
+	sort.Strings(out)
+	return out
+}
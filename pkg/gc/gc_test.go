@@ -0,0 +1,62 @@
+package gc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrphans(t *testing.T) {
+	now := time.Now()
+	old, young := now.Add(-time.Hour), now.Add(-time.Second)
+
+	h := &Host{
+		Tasks: map[string]bool{"pod-live": true, "rtos-single": true},
+		Sandboxes: map[string]Sandbox{
+			// pod-live runs, so do its containers
+			"pod-live": {Containers: []string{"pod-live", "rtos-a"}, HolderPid: 100, Modified: old, Workload: "default_live"},
+			// torn down halfway, e.g. the shim was killed during delete
+			"pod-dead": {Containers: []string{"rtos-c", "rtos-b"}, HolderPid: 200, Modified: old},
+			// being created, containerd does not list it yet
+			"pod-new": {Containers: []string{"rtos-new"}, HolderPid: 300, Modified: young},
+		},
+		Clients: map[string]bool{
+			"rtos-a": true, "rtos-b": true, "rtos-new": true, "rtos-single": true,
+			"rtos-left": true,
+			// started by systemd at boot, micrun knows nothing of it
+			"rtos-boot": true,
+		},
+		Domains: map[string]bool{"rtos-left": true, "rtos-pinned": true, "rtos-boot": true},
+		Caches: map[string]time.Time{
+			"rtos-a": old, "rtos-single": old, "rtos-b": old,
+			"rtos-left": old, "rtos-pinned": old, "rtos-fresh": young,
+		},
+		Holders: map[int]string{100: "pod-live", 200: "pod-dead", 300: "pod-new", 400: "pod-gone"},
+		Started: map[int]time.Time{100: old, 200: old, 300: young, 400: old},
+		Records: map[string]time.Time{
+			"default_live": now.Add(-2 * RecordMaxAge),
+			// waits for its pod to come back after a node reboot
+			"default_rebooted": old,
+			"default_gone":     now.Add(-2 * RecordMaxAge),
+		},
+	}
+
+	want := []Orphan{
+		{Kind: KindSandbox, ID: "pod-dead", Containers: []string{"rtos-b", "rtos-c"}},
+		{Kind: KindClient, ID: "rtos-left"},
+		// a domain left by a client micad no longer knows
+		{Kind: KindDomain, ID: "rtos-pinned"},
+		{Kind: KindCache, ID: "rtos-left"},
+		{Kind: KindCache, ID: "rtos-pinned"},
+		{Kind: KindHolder, ID: "pod-gone", Pid: 400},
+		{Kind: KindRecord, ID: "default_gone"},
+	}
+	if got := h.Orphans(now, DefaultMinAge); !reflect.DeepEqual(got, want) {
+		t.Fatalf("orphans:\n got %v\nwant %v", got, want)
+	}
+
+	// everything is spared while younger than the min age
+	if got := h.Orphans(now, 3*RecordMaxAge); len(got) != 0 {
+		t.Fatalf("orphans younger than the min age: %v", got)
+	}
+}
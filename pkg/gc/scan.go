@@ -0,0 +1,206 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	defs "micrun/definitions"
+	er "micrun/errors"
+	log "micrun/logger"
+	"micrun/pkg/libmica"
+	cntr "micrun/pkg/micantainer"
+	"micrun/pkg/netns"
+	"micrun/pkg/pedestal"
+	"micrun/pkg/store"
+	"micrun/pkg/utils"
+
+	nsapi "github.com/containerd/containerd/api/services/namespaces/v1"
+	tasksapi "github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/namespaces"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DefaultAddress is the grpc socket of containerd.
+const DefaultAddress = "/run/containerd/containerd.sock"
+
+const dialTimeout = 5 * time.Second
+
+// persistentStore holds the records kept over a node reboot, see persist_records.
+var persistentStore = store.New(defs.PersistentDataDir)
+
+// Scan gathers what the host runs. The containerd tasks are listed through
+// the grpc socket at address, no orphan can be told without them, so Scan
+// fails when containerd is not reachable.
+func Scan(ctx context.Context, address string) (*Host, error) {
+	tasks, err := listTasks(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	h := &Host{
+		Tasks:     tasks,
+		Sandboxes: make(map[string]Sandbox),
+		Clients:   make(map[string]bool),
+		Domains:   make(map[string]bool),
+		Caches:    make(map[string]time.Time),
+		Started:   make(map[int]time.Time),
+		Records:   make(map[string]time.Time),
+	}
+
+	if err := h.scanStore(store.Default()); err != nil {
+		return nil, err
+	}
+	if err := h.scanRecords(persistentStore); err != nil {
+		return nil, err
+	}
+
+	clients, err := libmica.Clients()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range clients {
+		h.Clients[id] = true
+	}
+
+	if pedestal.GetHostPed() == pedestal.Xen {
+		domains, err := pedestal.Domains()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range domains {
+			h.Domains[name] = true
+		}
+	}
+
+	entries, err := os.ReadDir(defs.DefaultMicaContainersRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list container caches: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			h.Caches[e.Name()] = info.ModTime()
+		}
+	}
+
+	if h.Holders, err = netns.Holders(); err != nil {
+		return nil, err
+	}
+	for pid := range h.Holders {
+		// /proc/<pid> is created when the process starts
+		if info, err := os.Stat(fmt.Sprintf("/proc/%d", pid)); err == nil {
+			h.Started[pid] = info.ModTime()
+		}
+	}
+	return h, nil
+}
+
+// sandboxRecord is the part of a sandbox record gc reads, see SandboxStorage.
+type sandboxRecord struct {
+	Config struct {
+		Workload string
+	} `json:"config"`
+	Network struct {
+		HolderPid int `json:"holder_pid"`
+	} `json:"network"`
+}
+
+func (h *Host) scanStore(st *store.Store) error {
+	ids, err := st.Sandboxes()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		sb := Sandbox{}
+		if info, err := os.Stat(st.SandboxDir(id)); err == nil {
+			sb.Modified = info.ModTime()
+		}
+		var rec sandboxRecord
+		if err := st.PeekSandbox(id, &rec); err != nil && err != er.SandboxNotFound {
+			// a sandbox dir without a readable record is a leftover all the same
+			log.Warnf("gc: unreadable record of sandbox %s: %v", id, err)
+		}
+		sb.HolderPid = rec.Network.HolderPid
+		sb.Workload = rec.Config.Workload
+		if sb.Containers, err = st.Containers(id); err != nil {
+			return err
+		}
+		h.Sandboxes[id] = sb
+	}
+	return nil
+}
+
+// scanRecords lists the persistent records by workload, a record is rewritten
+// with each change of its workload.
+func (h *Host) scanRecords(st *store.Store) error {
+	workloads, err := st.Sandboxes()
+	if err != nil {
+		return err
+	}
+	for _, workload := range workloads {
+		if info, err := os.Stat(st.SandboxDir(workload)); err == nil {
+			h.Records[workload] = info.ModTime()
+		}
+	}
+	return nil
+}
+
+func listTasks(ctx context.Context, address string) (map[string]bool, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, "unix://"+address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to containerd at %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	nsList, err := nsapi.NewNamespacesClient(conn).List(ctx, &nsapi.ListNamespacesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containerd namespaces: %w", err)
+	}
+	tasks := make(map[string]bool)
+	client := tasksapi.NewTasksClient(conn)
+	for _, ns := range nsList.Namespaces {
+		resp, err := client.List(namespaces.WithNamespace(ctx, ns.Name), &tasksapi.ListTasksRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tasks of namespace %s: %w", ns.Name, err)
+		}
+		for _, t := range resp.Tasks {
+			tasks[t.GetID()] = true
+		}
+	}
+	return tasks, nil
+}
+
+// Remove tears orphan o down.
+func Remove(ctx context.Context, o Orphan) error {
+	switch o.Kind {
+	case KindSandbox:
+		for _, cid := range o.Containers {
+			if err := cntr.CleanupContainer(ctx, o.ID, cid, true); err != nil {
+				log.Warnf("gc: failed to clean up container %s of sandbox %s: %v", cid, o.ID, err)
+			}
+		}
+		// a sandbox without containers, or one cleanup could not load
+		return store.Default().DeleteSandbox(o.ID)
+	case KindClient:
+		if err := libmica.Stop(o.ID); err != nil {
+			return err
+		}
+		return libmica.Remove(o.ID)
+	case KindDomain:
+		return pedestal.Destroy(o.ID)
+	case KindCache:
+		return utils.RemoveContainerCacheDir(o.ID)
+	case KindHolder:
+		return netns.Terminate(o.ID, o.Pid)
+	case KindRecord:
+		return persistentStore.DeleteSandbox(o.ID)
+	}
+	return fmt.Errorf("gc: unknown orphan kind %q", o.Kind)
+}
@@ -0,0 +1,292 @@
+// Package irqaffinity steers movable host interrupts away from physical CPUs
+// that are dedicated to RTOS clients.
+//
+// Shims of different sandboxes share the same host, so the set of isolated
+// CPUs and the original IRQ affinities are kept in a small state file guarded
+// by flock(2) rather than in process memory.
+package irqaffinity
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	defs "micrun/definitions"
+	log "micrun/logger"
+	"micrun/pkg/cpuset"
+	"micrun/pkg/store"
+)
+
+const (
+	irqDir             = "irq"
+	affinityListFile   = "smp_affinity_list"
+	defaultAffinityKey = "default_smp_affinity"
+	stateFileName      = "irq_affinity.json"
+)
+
+// Steerer rewrites IRQ affinities below procRoot and records the original
+// values in stateFile so that they can be restored later.
+type Steerer struct {
+	procRoot  string
+	stateFile string
+}
+
+// record is the persisted view shared by all shims on the host.
+type record struct {
+	// Owners maps a client ID to the cpuset it has isolated.
+	Owners map[string]string `json:"owners"`
+	// Original maps a path relative to procRoot to its content before micrun touched it.
+	Original map[string]string `json:"original"`
+}
+
+var defaultSteerer = New("/proc", filepath.Join(defs.MicrunStateDir, stateFileName))
+
+// New returns a Steerer operating on the given procfs root and state file.
+func New(procRoot, stateFile string) *Steerer {
+	return &Steerer{procRoot: procRoot, stateFile: stateFile}
+}
+
+// Isolate moves movable IRQs away from the CPUs of the given client using the host procfs.
+func Isolate(owner, cpus string) error {
+	return defaultSteerer.Isolate(owner, cpus)
+}
+
+// Release drops the isolation held by the given client using the host procfs.
+func Release(owner string) error {
+	return defaultSteerer.Release(owner)
+}
+
+// Isolate registers cpus as exclusively used by owner and rewrites the affinity of
+// every movable IRQ, as well as the default affinity, to exclude all isolated CPUs.
+func (s *Steerer) Isolate(owner, cpus string) error {
+	if owner == "" {
+		return fmt.Errorf("irqaffinity: empty owner")
+	}
+	set, err := cpuset.Parse(cpus)
+	if err != nil {
+		return fmt.Errorf("irqaffinity: invalid cpuset %q: %w", cpus, err)
+	}
+	if set.IsEmpty() {
+		return nil
+	}
+
+	return s.withRecord(func(rec *record) error {
+		rec.Owners[owner] = set.String()
+		return s.apply(rec)
+	})
+}
+
+// Release unregisters owner. CPUs no longer used by any owner get their interrupts
+// back, and once the last owner is gone every recorded affinity is restored.
+func (s *Steerer) Release(owner string) error {
+	if _, err := os.Stat(s.stateFile); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return s.withRecord(func(rec *record) error {
+		if _, ok := rec.Owners[owner]; !ok {
+			return nil
+		}
+		delete(rec.Owners, owner)
+		if len(rec.Owners) > 0 {
+			return s.apply(rec)
+		}
+		return s.restore(rec)
+	})
+}
+
+func (rec *record) isolated() (cpuset.CPUSet, error) {
+	union := cpuset.NewCPUSet()
+	for owner, cpus := range rec.Owners {
+		set, err := cpuset.Parse(cpus)
+		if err != nil {
+			return union, fmt.Errorf("irqaffinity: corrupted cpuset %q for %s: %w", cpus, owner, err)
+		}
+		union = union.Union(set)
+	}
+	return union, nil
+}
+
+// apply recomputes every affinity from its original value, so CPUs released by a
+// previous owner are handed back without extra bookkeeping.
+func (s *Steerer) apply(rec *record) error {
+	isolated, err := rec.isolated()
+	if err != nil {
+		return err
+	}
+
+	irqs, err := s.listIRQs()
+	if err != nil {
+		return err
+	}
+
+	for _, irq := range irqs {
+		rel := filepath.Join(irqDir, irq, affinityListFile)
+		_, known := rec.Original[rel]
+		original, err := s.original(rec, rel)
+		if err != nil {
+			log.Debugf("irqaffinity: skip irq %s: %v", irq, err)
+			continue
+		}
+		origSet, err := cpuset.Parse(original)
+		if err != nil {
+			log.Debugf("irqaffinity: skip irq %s with unparsable affinity %q: %v", irq, original, err)
+			continue
+		}
+		target := origSet.Difference(isolated)
+		if target.IsEmpty() {
+			// the irq is only routable to isolated cpus, moving it would break the device
+			log.Debugf("irqaffinity: irq %s affinity %s is fully isolated, leave it untouched", irq, original)
+			continue
+		}
+		if err := s.write(rel, target.String()); err != nil {
+			// managed and per-cpu interrupts reject the write with EIO, they are not movable
+			log.Debugf("irqaffinity: irq %s is not movable: %v", irq, err)
+			if !known {
+				delete(rec.Original, rel)
+			}
+		}
+	}
+
+	rel := filepath.Join(irqDir, defaultAffinityKey)
+	original, err := s.original(rec, rel)
+	if err != nil {
+		log.Warnf("irqaffinity: failed to read default irq affinity: %v", err)
+		return nil
+	}
+	origSet, err := parseMask(original)
+	if err != nil {
+		return fmt.Errorf("irqaffinity: invalid default affinity %q: %w", original, err)
+	}
+	if target := origSet.Difference(isolated); !target.IsEmpty() {
+		if err := s.write(rel, formatMask(target)); err != nil {
+			log.Warnf("irqaffinity: failed to update default irq affinity: %v", err)
+		}
+	}
+	return nil
+}
+
+// restore writes back every recorded original value and drops the record.
+func (s *Steerer) restore(rec *record) error {
+	for _, rel := range sortedKeys(rec.Original) {
+		if err := s.write(rel, rec.Original[rel]); err != nil {
+			// the irq may have disappeared together with its device
+			log.Debugf("irqaffinity: failed to restore %s: %v", rel, err)
+		}
+	}
+	rec.Original = make(map[string]string)
+	return nil
+}
+
+// original returns the recorded original content of rel, reading and recording it on first use.
+func (s *Steerer) original(rec *record, rel string) (string, error) {
+	if v, ok := rec.Original[rel]; ok {
+		return v, nil
+	}
+	raw, err := os.ReadFile(filepath.Join(s.procRoot, rel))
+	if err != nil {
+		return "", err
+	}
+	v := strings.TrimSpace(string(raw))
+	rec.Original[rel] = v
+	return v, nil
+}
+
+func (s *Steerer) write(rel, value string) error {
+	f, err := os.OpenFile(filepath.Join(s.procRoot, rel), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(value + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *Steerer) listIRQs() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.procRoot, irqDir))
+	if err != nil {
+		return nil, fmt.Errorf("irqaffinity: failed to list irqs: %w", err)
+	}
+	var irqs []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		irqs = append(irqs, e.Name())
+	}
+	return irqs, nil
+}
+
+// withRecord loads the state under an exclusive lock, runs fn and persists the result.
+// The state file is removed once nothing is isolated anymore.
+func (s *Steerer) withRecord(fn func(rec *record) error) error {
+	rec := &record{}
+	return store.UpdateRecord(s.stateFile, rec, func() (bool, error) {
+		if rec.Owners == nil {
+			rec.Owners = make(map[string]string)
+		}
+		if rec.Original == nil {
+			rec.Original = make(map[string]string)
+		}
+		if err := fn(rec); err != nil {
+			return false, err
+		}
+		return len(rec.Owners) > 0 || len(rec.Original) > 0, nil
+	})
+}
+
+// parseMask parses the comma separated hex mask used by default_smp_affinity,
+// e.g. "ff" or "00000000,0000000f".
+func parseMask(mask string) (cpuset.CPUSet, error) {
+	hex := strings.ReplaceAll(strings.TrimSpace(mask), ",", "")
+	if hex == "" {
+		return cpuset.NewCPUSet(), fmt.Errorf("empty mask")
+	}
+	n, ok := new(big.Int).SetString(hex, 16)
+	if !ok {
+		return cpuset.NewCPUSet(), fmt.Errorf("not a hex mask")
+	}
+	var cpus []int
+	for i := 0; i < n.BitLen(); i++ {
+		if n.Bit(i) == 1 {
+			cpus = append(cpus, i)
+		}
+	}
+	return cpuset.NewCPUSet(cpus...), nil
+}
+
+// formatMask renders a cpuset in the format accepted by default_smp_affinity.
+func formatMask(set cpuset.CPUSet) string {
+	n := new(big.Int)
+	for _, cpu := range set.ToSlice() {
+		n.SetBit(n, cpu, 1)
+	}
+	hex := n.Text(16)
+	// group by 32-bit words like the kernel does
+	var groups []string
+	for len(hex) > 8 {
+		groups = append([]string{hex[len(hex)-8:]}, groups...)
+		hex = hex[:len(hex)-8]
+	}
+	groups = append([]string{hex}, groups...)
+	return strings.Join(groups, ",")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package irqaffinity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fakeProc(t *testing.T, irqs map[string]string, defaultMask string) string {
+	t.Helper()
+	root := t.TempDir()
+	for irq, affinity := range irqs {
+		dir := filepath.Join(root, irqDir, irq)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, affinityListFile), []byte(affinity+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, irqDir, defaultAffinityKey), []byte(defaultMask+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func readAffinity(t *testing.T, root, rel string) string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(root, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func TestIsolateAndRestore(t *testing.T) {
+	root := fakeProc(t, map[string]string{
+		"10": "0-3",
+		"11": "2",
+		"12": "1,3",
+	}, "f")
+	state := filepath.Join(t.TempDir(), stateFileName)
+	s := New(root, state)
+
+	if err := s.Isolate("rtos-a", "2"); err != nil {
+		t.Fatalf("Isolate rtos-a: %v", err)
+	}
+	if err := s.Isolate("rtos-b", "3"); err != nil {
+		t.Fatalf("Isolate rtos-b: %v", err)
+	}
+
+	checks := map[string]string{
+		"irq/10/smp_affinity_list": "0-1",
+		"irq/11/smp_affinity_list": "2", // only routable to an isolated cpu
+		"irq/12/smp_affinity_list": "1",
+		"irq/default_smp_affinity": "3",
+	}
+	for rel, want := range checks {
+		if got := readAffinity(t, root, rel); got != want {
+			t.Errorf("%s = %q, want %q", rel, got, want)
+		}
+	}
+
+	// CPU 2 goes back to the host while CPU 3 stays isolated.
+	if err := s.Release("rtos-a"); err != nil {
+		t.Fatalf("Release rtos-a: %v", err)
+	}
+	if got := readAffinity(t, root, "irq/10/smp_affinity_list"); got != "0-2" {
+		t.Errorf("irq 10 after partial release = %q, want 0-2", got)
+	}
+	if got := readAffinity(t, root, "irq/default_smp_affinity"); got != "7" {
+		t.Errorf("default affinity after partial release = %q, want 7", got)
+	}
+
+	if err := s.Release("rtos-b"); err != nil {
+		t.Fatalf("Release rtos-b: %v", err)
+	}
+	restored := map[string]string{
+		"irq/10/smp_affinity_list": "0-3",
+		"irq/11/smp_affinity_list": "2",
+		"irq/12/smp_affinity_list": "1,3",
+		"irq/default_smp_affinity": "f",
+	}
+	for rel, want := range restored {
+		if got := readAffinity(t, root, rel); got != want {
+			t.Errorf("%s after restore = %q, want %q", rel, got, want)
+		}
+	}
+	if _, err := os.Stat(state); !os.IsNotExist(err) {
+		t.Errorf("state file should be removed after last release, stat err = %v", err)
+	}
+}
+
+func TestReleaseUnknownOwner(t *testing.T) {
+	root := fakeProc(t, map[string]string{"5": "0-1"}, "3")
+	s := New(root, filepath.Join(t.TempDir(), stateFileName))
+
+	if err := s.Release("missing"); err != nil {
+		t.Fatalf("Release without state: %v", err)
+	}
+	if err := s.Isolate("rtos", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release("missing"); err != nil {
+		t.Fatalf("Release unknown owner: %v", err)
+	}
+	if got := readAffinity(t, root, "irq/5/smp_affinity_list"); got != "0" {
+		t.Errorf("irq 5 = %q, want 0", got)
+	}
+}
+
+func TestMaskRoundTrip(t *testing.T) {
+	for _, mask := range []string{"1", "f0", "1,00000000", "80000000,00000001"} {
+		set, err := parseMask(mask)
+		if err != nil {
+			t.Fatalf("parseMask(%q): %v", mask, err)
+		}
+		if got := formatMask(set); got != mask {
+			t.Errorf("formatMask(parseMask(%q)) = %q", mask, got)
+		}
+	}
+}
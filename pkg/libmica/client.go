@@ -98,6 +98,7 @@ type MicaStatus struct {
 	CPU      string        `json:"cpu"`
 	State    MicaState     `json:"state"`
 	Services []MicaService `json:"services"`
+	PTY      string        `json:"pty"` // host PTY of the pty service, if micad reports it
 	Raw      string        `json:"raw"` // Original raw response
 }
 
@@ -117,6 +118,16 @@ func (ms MicaStatus) IsStopped() bool {
 	return ms.State == stopped
 }
 
+// IsDown checks if the client os is not running anymore
+func (ms MicaStatus) IsDown() bool {
+	return ms.State == stopped || ms.State == offline
+}
+
+// IsFailed checks if the client os crashed
+func (ms MicaStatus) IsFailed() bool {
+	return ms.State == stateErr
+}
+
 // hasService checks if the client has a specific service
 func (ms MicaStatus) hasService(service MicaService) bool {
 	for _, s := range ms.Services {
@@ -404,13 +415,42 @@ func Start(id string) error {
 	return nil
 }
 
+// Shutdown asks the client os to power off and returns without waiting for it,
+// the client stays registered at micad. Only a xen guest can be asked from the
+// host, other clients are only stopped, which is what Stop does.
+func Shutdown(id string) error {
+	if pedestal.GetHostPed() == pedestal.Xen {
+		return pedestal.Shutdown(id)
+	}
+	return fmt.Errorf("%w: shutdown of %s from the host", er.NotSupported, id)
+}
+
+// Restart boots a stopped client again without registering it anew. A xen
+// domain left by the previous boot is rebooted, micad starts the client otherwise.
+func Restart(id string) error {
+	if pedestal.GetHostPed() == pedestal.Xen {
+		if _, err := pedestal.DomainID(id); err == nil {
+			return pedestal.Reboot(id)
+		}
+	}
+	return Start(id)
+}
+
 // TODO: Extend mica response data, loading more information
-// TODO: completely migrate remove to stop, currently use remove instead of stop
-// we have to make sure that client os is down really
+// Stop forcibly stops the client without asking the client os, micad destroys
+// the xen domain or stops the remote processor. The client stays registered at
+// micad so that it can be restarted in place, Remove unregisters it. Stop is the
+// second phase of a graceful stop which starts with Shutdown.
 func Stop(id string) error {
 	if ClientNotExist(id) {
 		log.Infof("%s is already down, not need to stop it", id)
-	} else if err := micaCtl(MRemove, id); err != nil {
+		return nil
+	}
+	if status, err := Status(id, Filter{}); err == nil && status.IsDown() {
+		log.Debugf("%s is already stopped", id)
+		return nil
+	}
+	if err := micaCtl(MStop, id); err != nil {
 		return fmt.Errorf("failed to stop mica client %s %w", id, err)
 	}
 	return nil
@@ -2,6 +2,7 @@ package libmica
 
 import (
 	"fmt"
+	er "micrun/errors"
 	log "micrun/logger"
 	"micrun/pkg/pedestal"
 	"strconv"
@@ -223,6 +224,36 @@ func (me *MicaExecutor) VcpuPin(cpuList []int) error {
 	return me.UpdatePCPUConstrains(cpustr)
 }
 
+// VcpuBind binds vCPU N to cpuList[N] (1:1 binding mode). The vCPU number is
+// forced to the list size first, so that every cpu gets exactly one vCPU.
+// Only Xen exposes per-vCPU placement.
+func (me *MicaExecutor) VcpuBind(cpuList []int) error {
+	if ped := pedestal.GetHostPed(); ped != pedestal.Xen {
+		return fmt.Errorf("%w: vcpu binding of %s on pedestal %s", er.NotSupported, me.Id, ped)
+	}
+	cpustr := pedestal.ParseCPUArr(cpuList)
+	if cpustr == "" {
+		return fmt.Errorf("received cpuList %v, parsed into an empty array", cpuList)
+	}
+
+	vcpus := uint32(len(cpuList))
+	if me.records.vcpuNum != int(vcpus) {
+		if _, _, err := me.UpdateVCPUNum(vcpus); err != nil {
+			return err
+		}
+		me.records.vcpuNum = int(vcpus)
+	}
+
+	if err := pedestal.BindVCPUs(me.Id, cpuList); err != nil {
+		log.Warnf("failed to bind vcpus of %s to %s: %v", me.Id, cpustr, err)
+		return err
+	}
+	me.records.cpuStr = [MaxCPUStringLen]byte{}
+	copy(me.records.cpuStr[:], []byte(cpustr))
+	log.Debugf("bound vcpus of %s one by one to %s", me.Id, cpustr)
+	return nil
+}
+
 func (me *MicaExecutor) NeedUpdateCpuCap(target uint32) bool {
 	current := uint32(0)
 	if me.records.cpuCapacity > 0 {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	defs "micrun/definitions"
 	ped "micrun/pkg/pedestal"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -99,10 +100,21 @@ func parseMicaStatus(rawOutput string) (*MicaStatus, error) {
 		CPU:      cpuStr,
 		State:    state,
 		Services: services,
+		PTY:      parsePTYPath(fields[3:]),
 		Raw:      rawOutput,
 	}, nil
 }
 
+// parsePTYPath extracts the host side of the rpmsg PTY from a "pty(/dev/pts/1)" service field
+func parsePTYPath(fields []string) string {
+	for _, field := range fields {
+		if path, ok := strings.CutPrefix(field, "pty("); ok {
+			return strings.TrimSuffix(path, ")")
+		}
+	}
+	return ""
+}
+
 // parseMicaState converts string to MicaState
 func parseMicaState(stateStr string) MicaState {
 	switch stateStr {
@@ -232,3 +244,24 @@ func ClientNotExist(id string) bool {
 	valid := validSocketPath(socketPath)
 	return !valid
 }
+
+// Clients returns the ids of the clients registered at micad, i.e. the
+// clients with a control socket in the mica state dir.
+func Clients() ([]string, error) {
+	entries, err := os.ReadDir(defs.MicaStateDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mica clients: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".socket")
+		if !ok || e.Name() == defs.MicaSocketName || e.Type()&os.ModeSocket == 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,216 @@
+package micantainer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	defs "micrun/definitions"
+	log "micrun/logger"
+	"micrun/pkg/cpuset"
+	"micrun/pkg/libmica"
+	"micrun/pkg/osprofile"
+	"micrun/pkg/passthrough"
+	ped "micrun/pkg/pedestal"
+	"micrun/pkg/store"
+	"micrun/pkg/utils"
+)
+
+// clientID is the name of the client of the container at micad.
+func (cfg *ContainerConfig) clientID() string {
+	if cfg.Client != "" {
+		return cfg.Client
+	}
+	return cfg.ID
+}
+
+func (c *Container) clientID() string {
+	if c.config == nil {
+		return c.id
+	}
+	return c.config.clientID()
+}
+
+// runningClient is what the host tells about a client to adopt.
+type runningClient struct {
+	// FirmwareHash is the sha-256 of the firmware the client was started from.
+	FirmwareHash string
+	// CPUs are the pcpus of the client as reported by micad.
+	CPUs string
+	// MemMB is the memory of the client, 0 when the pedestal does not tell.
+	MemMB uint32
+}
+
+// adopt takes the running client named by ContainerAdopt over at create:
+// the client is checked against the container, and registered with micrun in
+// place of a new client. The client is neither stopped nor rebooted.
+func (c *Container) adopt() error {
+	name := c.clientID()
+	if libmica.ClientNotExist(name) {
+		if HostPedType == ped.Xen {
+			if _, err := ped.DomainID(name); err == nil {
+				return fmt.Errorf("domain %s is not registered at micad, it can not be adopted before micad tracks it", name)
+			}
+		}
+		return fmt.Errorf("no client %s to adopt", name)
+	}
+	if owner := adoptedBy(name, c.id); owner != "" {
+		return fmt.Errorf("client %s is adopted by container %s already", name, owner)
+	}
+	status, err := libmica.Status(name, libmica.Filter{})
+	if err != nil {
+		return err
+	}
+	if status.IsDown() || status.IsFailed() {
+		return fmt.Errorf("client %s is %s, only a running client is adopted", name, status.State)
+	}
+
+	got := runningClient{CPUs: status.CPU}
+	if got.FirmwareHash, err = clientFirmwareHash(name); err != nil {
+		return err
+	}
+	if HostPedType == ped.Xen {
+		if got.MemMB, err = ped.DomainMemMB(name); err != nil {
+			return err
+		}
+	}
+	want, err := c.config.firmwareHash()
+	if err != nil {
+		return err
+	}
+	if err := c.config.checkAdoption(want, got); err != nil {
+		return fmt.Errorf("client %s can not be adopted by container %s: %w", name, c.id, err)
+	}
+
+	// the devices are owned by the client already, no other pod may claim them
+	if err := passthrough.Claim(c.id, c.config.Passthrough); err != nil {
+		return err
+	}
+	if got.MemMB > 0 {
+		c.me.RecordMemoryState(got.MemMB, max(got.MemMB, c.config.memoryLimitMB()))
+	}
+	log.Infof("container %s adopts client %s", c.id, name)
+	return nil
+}
+
+// adoptedBy returns the container other than id which adopted client name.
+func adoptedBy(name, id string) string {
+	st := store.Default()
+	sandboxes, err := st.Sandboxes()
+	if err != nil {
+		log.Warnf("adopted clients are not known: %v", err)
+		return ""
+	}
+	for _, sid := range sandboxes {
+		containers, _ := st.Containers(sid)
+		for _, cid := range containers {
+			var rec struct {
+				Config struct {
+					Client string `json:"client"`
+				} `json:"config"`
+			}
+			if cid == id || st.LoadContainer(sid, cid, &rec) != nil {
+				continue
+			}
+			if rec.Config.Client == name {
+				return cid
+			}
+		}
+	}
+	return ""
+}
+
+// checkAdoption tells whether the running client got is the one the container asks for.
+func (cfg *ContainerConfig) checkAdoption(wantHash string, got runningClient) error {
+	if !strings.EqualFold(wantHash, got.FirmwareHash) {
+		return fmt.Errorf("firmware sha-256 %s, want %s", got.FirmwareHash, wantHash)
+	}
+	if want := cfg.CPUSet(); want != "" {
+		wantSet, err := cpuset.Parse(want)
+		if err != nil {
+			return fmt.Errorf("invalid cpuset %q: %w", want, err)
+		}
+		gotSet, err := cpuset.Parse(got.CPUs)
+		if err != nil {
+			return fmt.Errorf("client runs on invalid cpus %q: %w", got.CPUs, err)
+		}
+		if !wantSet.Equals(gotSet) {
+			return fmt.Errorf("client runs on cpus %s, want %s", gotSet, wantSet)
+		}
+	}
+	if limit := cfg.memoryLimitMB(); limit > 0 && got.MemMB > limit {
+		return fmt.Errorf("client has %d MiB of memory, over the limit of %d MiB", got.MemMB, limit)
+	}
+	return nil
+}
+
+// firmwareHash is the sha-256 of the firmware the container asks for: the
+// FirmwareHash annotation, or the hash of the firmware of the image.
+func (cfg *ContainerConfig) firmwareHash() (string, error) {
+	if h := strings.TrimSpace(cfg.Annotations[defs.FirmwareHash]); h != "" {
+		return h, nil
+	}
+	if cfg.ImageAbsPath == "" {
+		return "", fmt.Errorf("no firmware to check the adopted client against, set %s", defs.FirmwareHash)
+	}
+	return hashFile(cfg.ImageAbsPath)
+}
+
+// clientFirmwareHash hashes the firmware client name was started from, which
+// is read from the mica config naming it.
+func clientFirmwareHash(name string) (string, error) {
+	confs, err := filepath.Glob(filepath.Join(defs.MicaConfDir, "*.conf"))
+	if err != nil {
+		return "", err
+	}
+	for _, conf := range confs {
+		// whitelist the [Mica] section, keys are lowercased
+		fields, err := utils.ParseINI(conf, []string{"mica"})
+		if err != nil || strings.TrimSpace(fields["name"]) != name {
+			continue
+		}
+		path := strings.TrimSpace(fields["clientpath"])
+		if path == "" {
+			return "", fmt.Errorf("%s names no firmware of client %s", conf, name)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(defs.MicaConfDir, path)
+		}
+		return hashFile(path)
+	}
+	return "", fmt.Errorf("firmware of client %s is unknown, no config in %s names it", name, defs.MicaConfDir)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// takeOverClient is the start of an adopted client: it runs already, its
+// console is recorded and watched from now on as for a client micrun booted.
+func takeOverClient(ctx context.Context, sandbox SandboxTraits, c *Container, restart bool) error {
+	exits, err := osprofile.ParseExitPatterns(c.config.Annotations)
+	if err != nil {
+		return err
+	}
+	if c.startConsole() {
+		c.exited = c.watchExitPatterns(exits)
+	}
+	c.connectConsole()
+	c.steerIRQs()
+	log.Infof("container %s took client %s over", c.id, c.clientID())
+	return nil
+}
@@ -0,0 +1,63 @@
+package micantainer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	defs "micrun/definitions"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestCheckAdoption(t *testing.T) {
+	limit := int64(64 * miB)
+	cfg := &ContainerConfig{
+		Resources: &specs.LinuxResources{
+			CPU:    &specs.LinuxCPU{Cpus: "2-3"},
+			Memory: &specs.LinuxMemory{Limit: &limit},
+		},
+	}
+	const hash = "ab01"
+
+	tests := []struct {
+		name string
+		got  runningClient
+		ok   bool
+	}{
+		{"same client", runningClient{FirmwareHash: "AB01", CPUs: "3,2", MemMB: 64}, true},
+		{"memory unknown", runningClient{FirmwareHash: hash, CPUs: "2-3"}, true},
+		{"other firmware", runningClient{FirmwareHash: "cd02", CPUs: "2-3", MemMB: 64}, false},
+		{"other cpus", runningClient{FirmwareHash: hash, CPUs: "2", MemMB: 64}, false},
+		{"invalid cpus", runningClient{FirmwareHash: hash, CPUs: "x", MemMB: 64}, false},
+		{"memory over the limit", runningClient{FirmwareHash: hash, CPUs: "2-3", MemMB: 128}, false},
+	}
+	for _, tt := range tests {
+		err := cfg.checkAdoption(hash, tt.got)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: checkAdoption = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestFirmwareHash(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "rtos.elf")
+	if err := os.WriteFile(image, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ContainerConfig{ImageAbsPath: image}
+	const abc = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got, err := cfg.firmwareHash(); err != nil || got != abc {
+		t.Fatalf("firmwareHash of the image = %q, %v, want %s", got, err, abc)
+	}
+
+	// the annotation wins over the image
+	cfg.Annotations = map[string]string{defs.FirmwareHash: "ab01"}
+	if got, err := cfg.firmwareHash(); err != nil || got != "ab01" {
+		t.Fatalf("firmwareHash of the annotation = %q, %v", got, err)
+	}
+
+	if _, err := (&ContainerConfig{}).firmwareHash(); err == nil {
+		t.Fatal("firmwareHash without firmware should fail")
+	}
+}
@@ -0,0 +1,124 @@
+package micantainer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	er "micrun/errors"
+	log "micrun/logger"
+	ped "micrun/pkg/pedestal"
+)
+
+// Files of a checkpoint directory, containerd packs the directory into the
+// checkpoint image and unpacks it again for a create from the checkpoint.
+const (
+	checkpointImage  = "domain.img"
+	checkpointRecord = "container.json"
+)
+
+// CheckpointContainer writes the record of container id to dir, the returned
+// function saves its client there, the client keeps running.
+func (s *Sandbox) CheckpointContainer(ctx context.Context, id, dir string) (func() error, error) {
+	c, ok := s.containers[id]
+	if !ok {
+		return nil, er.ContainerNotFound
+	}
+	return c.checkpoint(dir)
+}
+
+// checkpoint checks the container and writes its record at once, the domain is
+// saved when the returned function is called: xl save takes as long as writing
+// the memory of the client, the caller does not hold its locks meanwhile.
+func (c *Container) checkpoint(dir string) (func() error, error) {
+	if c.config.IsInfra {
+		return nil, fmt.Errorf("%w: checkpoint of the sandbox container %s", er.NotSupported, c.id)
+	}
+	if HostPedType != ped.Xen {
+		return nil, fmt.Errorf("%w: checkpoint on pedestal %s", er.NotSupported, HostPedType)
+	}
+	if state := c.checkState(); state != StateRunning && state != StatePaused {
+		return nil, fmt.Errorf("%w: container %s is %s, can not checkpoint it", er.ContainerDown, c.id, state)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(c.record())
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, checkpointRecord), data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint record of %s: %w", c.id, err)
+	}
+	id, client := c.id, c.clientID()
+	return func() error {
+		if err := ped.Save(client, filepath.Join(dir, checkpointImage), true); err != nil {
+			return err
+		}
+		log.Infof("container %s checkpointed to %s", id, dir)
+		return nil
+	}, nil
+}
+
+// checkRestore tells whether the checkpoint of the container can be restored
+// with the placement the container asks for, before the client is registered.
+func (c *Container) checkRestore() error {
+	dir := c.config.Checkpoint
+	if HostPedType != ped.Xen {
+		return fmt.Errorf("%w: restore on pedestal %s", er.NotSupported, HostPedType)
+	}
+	if c.config.Client != "" {
+		return fmt.Errorf("container %s can not both adopt a client and restore a checkpoint", c.id)
+	}
+	if _, err := os.Stat(filepath.Join(dir, checkpointImage)); err != nil {
+		return fmt.Errorf("checkpoint %s has no domain image: %w", dir, err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, checkpointRecord))
+	if err != nil {
+		return fmt.Errorf("checkpoint %s has no container record: %w", dir, err)
+	}
+	var rec ContainerStorage
+	if err := decodeRecord(containerSchema, raw, &rec); err != nil {
+		return err
+	}
+	return c.config.checkCheckpoint(&rec.Config)
+}
+
+// checkCheckpoint compares the config of the container with the config saved
+// in the checkpoint: the domain is restored with the name, cpus and memory it was saved with.
+func (cfg *ContainerConfig) checkCheckpoint(saved *ContainerConfig) error {
+	if saved.clientID() != cfg.clientID() {
+		return fmt.Errorf("checkpoint is of client %s, the container runs client %s", saved.clientID(), cfg.clientID())
+	}
+	if saved.PedestalType != cfg.PedestalType {
+		return fmt.Errorf("checkpoint is of pedestal %s, the container runs on %s", saved.PedestalType, cfg.PedestalType)
+	}
+	if saved.CPUSet() != cfg.CPUSet() {
+		return fmt.Errorf("checkpoint runs on cpus %q, the container asks for %q", saved.CPUSet(), cfg.CPUSet())
+	}
+	if saved.memoryLimitMB() != cfg.memoryLimitMB() {
+		return fmt.Errorf("checkpoint has a memory limit of %d MiB, the container asks for %d MiB",
+			saved.memoryLimitMB(), cfg.memoryLimitMB())
+	}
+	return nil
+}
+
+// restoreClient is the first start of a container created from a checkpoint:
+// the domain is restored instead of booted, the client registered at create
+// takes it over as its own. micad never started that client and keeps it
+// stopped, the restored domain is told apart by domainAlive.
+func restoreClient(ctx context.Context, sandbox SandboxTraits, c *Container, restart bool) error {
+	if err := ped.Restore(filepath.Join(c.config.Checkpoint, checkpointImage)); err != nil {
+		return err
+	}
+	if mem, err := ped.DomainMemMB(c.clientID()); err == nil {
+		c.me.RecordMemoryState(mem, max(mem, c.config.memoryLimitMB()))
+	} else {
+		log.Warnf("memory of restored client %s is unknown: %v", c.clientID(), err)
+	}
+	log.Infof("container %s restored from %s", c.id, c.config.Checkpoint)
+	return takeOverClient(ctx, sandbox, c, restart)
+}
@@ -0,0 +1,44 @@
+package micantainer
+
+import (
+	"testing"
+
+	ped "micrun/pkg/pedestal"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestCheckCheckpoint(t *testing.T) {
+	newConfig := func(id, cpus string, memMB int64) *ContainerConfig {
+		limit := memMB * miB
+		return &ContainerConfig{
+			ID:           id,
+			PedestalType: ped.Xen,
+			Resources: &specs.LinuxResources{
+				CPU:    &specs.LinuxCPU{Cpus: cpus},
+				Memory: &specs.LinuxMemory{Limit: &limit},
+			},
+		}
+	}
+	saved := newConfig("rtos", "2-3", 64)
+
+	if err := newConfig("rtos", "2-3", 64).checkCheckpoint(saved); err != nil {
+		t.Fatalf("same placement rejected: %v", err)
+	}
+	for name, cfg := range map[string]*ContainerConfig{
+		"other client": newConfig("rtos-2", "2-3", 64),
+		"other cpus":   newConfig("rtos", "1", 64),
+		"other memory": newConfig("rtos", "2-3", 128),
+	} {
+		if err := cfg.checkCheckpoint(saved); err == nil {
+			t.Errorf("%s: checkpoint accepted", name)
+		}
+	}
+
+	// the checkpoint of an adopted client is restored under the client name
+	adopted := newConfig("pod-rtos", "2-3", 64)
+	adopted.Client = "rtos"
+	if err := newConfig("rtos", "2-3", 64).checkCheckpoint(adopted); err != nil {
+		t.Fatalf("checkpoint of an adopted client rejected: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package micantainer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	defs "micrun/definitions"
+	er "micrun/errors"
+	log "micrun/logger"
+	"micrun/pkg/console"
+	"micrun/pkg/libmica"
+	"micrun/pkg/osprofile"
+	ped "micrun/pkg/pedestal"
+)
+
+// startConsole records a new boot session in the console log and starts the
+// console hub, which keeps recording when nobody is attached and serves
+// `micrun attach` sessions on the console socket. fresh tells whether a new hub
+// was started, rather than the hub of a restart in place kept.
+func (c *Container) startConsole() (fresh bool) {
+	if c.config != nil && c.config.IsInfra {
+		return false
+	}
+	c.openConsoleLog()
+	if c.consoleLog != nil {
+		if session, err := c.consoleLog.BootSession(); err != nil {
+			log.Warnf("failed to record boot session of %s: %v", c.id, err)
+		} else {
+			log.Debugf("container %s boot session %d", c.id, session)
+		}
+	}
+	// the pty of a previous boot is stale
+	if c.pty != nil {
+		c.pty.Close()
+		c.pty = nil
+	}
+	// the console is connected by connectConsole once the client runs, a hub
+	// kept over a restart in place gets the console of the new boot
+	if c.hub == nil || c.hub.Done() {
+		c.hub = console.NewHub(c.consoleLog, nil)
+		fresh = true
+		// attach sessions are served by the hub of the previous boot
+		if c.attachListener != nil {
+			c.attachListener.Close()
+			c.attachListener = nil
+		}
+	}
+	c.serveAttach()
+	return fresh
+}
+
+// reconnectConsole serves the console of a client which kept running while
+// its shim was gone, the console log goes on with the current boot session.
+func (c *Container) reconnectConsole() {
+	if c.config != nil && c.config.IsInfra {
+		return
+	}
+	c.openConsoleLog()
+	c.hub = console.NewHub(c.consoleLog, nil)
+	c.serveAttach()
+	exits, err := osprofile.ParseExitPatterns(c.config.Annotations)
+	if err != nil {
+		log.Warnf("exit patterns of %s are not matched: %v", c.id, err)
+	}
+	c.exited = c.watchExitPatterns(exits)
+	c.connectConsole()
+}
+
+func (c *Container) openConsoleLog() {
+	if c.consoleLog != nil {
+		return
+	}
+	l, err := console.Open(console.Path(c.id), c.consoleRetention())
+	if err != nil {
+		log.Warnf("console of %s is not recorded: %v", c.id, err)
+		return
+	}
+	c.consoleLog = l
+}
+
+// serveAttach serves `micrun attach` sessions of the hub on the console socket.
+func (c *Container) serveAttach() {
+	if c.attachListener != nil {
+		return
+	}
+	sock := console.SocketPath(c.id)
+	os.Remove(sock)
+	if err := os.MkdirAll(filepath.Dir(sock), defs.DirMode); err != nil {
+		log.Warnf("console of %s can not be attached: %v", c.id, err)
+		return
+	}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		log.Warnf("console of %s can not be attached: %v", c.id, err)
+		return
+	}
+	c.attachListener = l
+	go c.hub.Serve(l)
+}
+
+// connectConsole opens the client console PTY and pumps it through the hub.
+// Without a PTY the hub ends at once, the console is then only attachable to
+// read what was recorded before.
+func (c *Container) connectConsole() {
+	if c.hub == nil {
+		return
+	}
+	path, err := c.consolePTYPath()
+	if err == nil {
+		c.pty, err = console.OpenPTY(path)
+	}
+	if err != nil {
+		log.Warnf("console of %s is not connected: %v", c.id, err)
+		go c.hub.Run(bytes.NewReader(nil))
+		return
+	}
+	log.Debugf("console of %s connected to %s", c.id, path)
+	c.hub.SetInput(c.pty)
+	if c.restartPolicy().Mode == RestartNever {
+		go c.hub.Run(c.pty)
+	} else {
+		// the client may be restarted in place when its console ends, the hub
+		// is ended when the container stops
+		go c.hub.Pipe(c.pty)
+	}
+}
+
+// watchExitPatterns delivers the exit status of the first console line matching
+// the exit patterns, nil when there are none.
+func (c *Container) watchExitPatterns(e *osprofile.ExitPatterns) <-chan int {
+	if e == nil || c.hub == nil {
+		return nil
+	}
+	ch := make(chan int, 1)
+	sub := c.hub.Subscribe("exit", console.SubscriberBuffer)
+	go func() {
+		defer sub.Close()
+		var pending string
+		for p := range sub.C {
+			pending += string(p)
+			for {
+				i := strings.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				line := strings.TrimRight(pending[:i], "\r")
+				pending = pending[i+1:]
+				if code, ok := e.Match(line); ok {
+					log.Infof("container %s printed %q, exit %d", c.id, line, code)
+					ch <- code
+					return
+				}
+			}
+		}
+		if err := sub.Err(); err != nil {
+			log.Warnf("exit patterns of %s are not matched anymore: %v", c.id, err)
+		}
+	}()
+	return ch
+}
+
+// endConsole ends the console subscribers, e.g. the container stdout.
+func (c *Container) endConsole() {
+	if c.hub != nil {
+		c.hub.End()
+	}
+}
+
+// consoleControls returns the control character and signal translation table of the client.
+func (c *Container) consoleControls() (*osprofile.Controls, error) {
+	return osprofile.Lookup(c.os()).Controls(c.config.Annotations)
+}
+
+// consolePTYPath resolves the host side of the client console: the Xen console
+// for legacy PTY clients, the rpmsg PTY of micad otherwise.
+func (c *Container) consolePTYPath() (string, error) {
+	if c.config.LegacyPty && HostPedType == ped.Xen {
+		return ped.ConsolePTYPathForDomain(c.clientID())
+	}
+	status, err := libmica.Status(c.clientID(), libmica.Filter{})
+	if err != nil {
+		return "", err
+	}
+	if status.PTY == "" {
+		return "", fmt.Errorf("micad reports no pty for client %s", c.clientID())
+	}
+	return status.PTY, nil
+}
+
+// winresize applies the terminal size to the console PTY, and tells the client
+// shell when its OS profile knows how to.
+func (c *Container) winresize(height, width uint32) error {
+	if c.notOperational() {
+		return fmt.Errorf("container not ready or running, impossible to resize the container pty")
+	}
+	if c.pty == nil {
+		log.Debugf("container %s has no console pty to resize", c.id)
+		return nil
+	}
+	rows, cols := uint16(height), uint16(width)
+	if r, cl, err := c.pty.Size(); err == nil && r == rows && cl == cols {
+		return nil
+	}
+	log.Debugf("resizing PTY for container %s to [%dx%d]", c.id, width, height)
+	if err := c.pty.Resize(rows, cols); err != nil {
+		return err
+	}
+
+	cmd := osprofile.Lookup(c.os()).GuestResize(c.config.Annotations, rows, cols)
+	if cmd == "" || c.stdio == nil {
+		return nil
+	}
+	// typed as the containerd session, it is skipped while another session holds the input
+	if _, err := c.stdio.Write([]byte(cmd)); err != nil {
+		log.Debugf("terminal size of %s not pushed to the client: %v", c.id, err)
+	}
+	return nil
+}
+
+func (c *Container) consoleRetention() int64 {
+	if c.sandbox != nil && c.sandbox.config != nil {
+		return c.sandbox.config.ConsoleLogSize
+	}
+	return 0
+}
+
+func (c *Container) closeConsole() {
+	if c.attachListener != nil {
+		c.attachListener.Close()
+		c.attachListener = nil
+	}
+	if c.pty != nil {
+		c.pty.Close()
+		c.pty = nil
+	}
+	if c.consoleLog == nil {
+		return
+	}
+	if err := c.consoleLog.Close(); err != nil {
+		log.Debugf("failed to close console log of %s: %v", c.id, err)
+	}
+	c.consoleLog = nil
+}
+
+// ExecFunc runs a prepared exec, the output is copied to w and the exit status
+// reported by the shell is returned.
+type ExecFunc func(ctx context.Context, w io.Writer) (int, error)
+
+// exec emulates a process by running args in the client shell. The state of the
+// container is checked at once, the command runs when the returned function is
+// called. Commands are serialized since they share one console.
+func (c *Container) exec(args []string) (ExecFunc, error) {
+	if c.notOperational() {
+		return nil, fmt.Errorf("container %s is not running, impossible to exec", c.id)
+	}
+	sh, err := osprofile.Lookup(c.os()).ExecShell(c.config.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if sh == nil {
+		return nil, fmt.Errorf("%w: client os %q has no shell to exec in", er.NotSupported, c.os())
+	}
+	hub := c.hub
+	if hub == nil {
+		return nil, fmt.Errorf("console of container %s is not started", c.id)
+	}
+
+	return func(ctx context.Context, w io.Writer) (int, error) {
+		return c.runExec(ctx, hub, sh, args, w)
+	}, nil
+}
+
+// runExec runs args in the client shell. The console input is borrowed for the
+// duration of the command, from the containerd stdio or an attach session, and
+// given back to it when the command ends.
+func (c *Container) runExec(ctx context.Context, hub *console.Hub, sh *console.Shell, args []string, w io.Writer) (int, error) {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	in := hub.Input("exec")
+	giveBack := in.Borrow()
+	defer giveBack()
+	out := hub.Subscribe("exec", console.SubscriberBuffer)
+	defer out.Close()
+
+	log.Debugf("exec %q in container %s", args, c.id)
+	code, err := sh.Run(ctx, in, out.C, args, w)
+	if errors.Is(err, console.ErrConsoleClosed) && out.Err() != nil {
+		err = out.Err()
+	}
+	return code, err
+}
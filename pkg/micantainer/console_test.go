@@ -0,0 +1,53 @@
+package micantainer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"micrun/pkg/console"
+)
+
+func TestExecAfterStdio(t *testing.T) {
+	// a client shell answering every command line on the console, the prompt
+	// comes in a read of its own as from a uart
+	in, input := io.Pipe()
+	src, output := io.Pipe()
+	go func() {
+		sc := bufio.NewScanner(in)
+		for sc.Scan() {
+			cmd := sc.Text()
+			output.Write([]byte(cmd + "\r\n" + cmd + " done\r\n"))
+			output.Write([]byte("# "))
+		}
+	}()
+	t.Cleanup(func() {
+		input.Close()
+		output.Close()
+	})
+	hub := console.NewHub(nil, input)
+	go hub.Run(src)
+
+	// the containerd stdio holds the input after its first write
+	stdio := hub.Input("task")
+	if _, err := stdio.Write([]byte("ls\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Container{id: "c1"}
+	sh := &console.Shell{Prompt: regexp.MustCompile(`# $`), Timeout: 5 * time.Second}
+	var buf bytes.Buffer
+	code, err := c.runExec(context.Background(), hub, sh, []string{"uname"}, &buf)
+	if err != nil || code != 0 || buf.String() != "uname done\n" {
+		t.Fatalf("runExec() = %d, %v, output %q", code, err, buf.String())
+	}
+
+	// given back once the command ended
+	if _, err := stdio.Write([]byte("ps\n")); err != nil {
+		t.Fatalf("stdio after exec: %v", err)
+	}
+}
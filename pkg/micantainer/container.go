@@ -10,11 +10,17 @@ import (
 	defs "micrun/definitions"
 	er "micrun/errors"
 	log "micrun/logger"
+	"micrun/pkg/console"
 	"micrun/pkg/cpuset"
+	"micrun/pkg/irqaffinity"
 	"micrun/pkg/libmica"
 	"micrun/pkg/netns"
+	"micrun/pkg/osprofile"
+	"micrun/pkg/passthrough"
 	ped "micrun/pkg/pedestal"
+	"micrun/pkg/store"
 	"micrun/pkg/utils"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -22,6 +28,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -43,6 +50,22 @@ type Container struct {
 	infraExitCh    chan helperCh
 	exitNotifier   chan struct{}
 	exitNotifierMu sync.Mutex
+	// consoleLog keeps the client console on disk across boot sessions.
+	consoleLog *console.Log
+	hub        *console.Hub
+	pty        *console.PTY
+	// stdio is the console input of the containerd stdio session.
+	stdio *console.Input
+	// attachListener serves `micrun attach` sessions of the console hub.
+	attachListener net.Listener
+	// execMu serializes exec commands on the client shell.
+	execMu sync.Mutex
+	// ready delivers the readiness of a boot which is waited for after the start returned.
+	ready <-chan error
+	// readyFail tells that a client not ready in time fails its start, see ContainerReadyTimeoutAction.
+	readyFail bool
+	// exited delivers the exit status printed on the console, see ContainerExitSuccess.
+	exited <-chan int
 }
 
 type ContainerConfig struct {
@@ -68,13 +91,33 @@ type ContainerConfig struct {
 	PCPUNum int `json:"ncpu"`
 	// MaxVcpuNum is the pedestal max virtual CPUs configured for this container.
 	MaxVcpuNum uint32 `json:"max_vcpu_num"`
+	// VCPUBinding pins vCPU N to the Nth CPU of the cpuset instead of letting all vCPUs float in it.
+	VCPUBinding bool `json:"vcpu_binding"`
 
 	// MemoryThresholdMB is the pedestal maximum allocable memory in MiB.
 	MemoryThresholdMB uint32 `json:"memory_threshold"`
+	// Passthrough holds the host devices assigned to the client.
+	Passthrough passthrough.Assignment `json:"passthrough"`
+
+	// StaticMemory allocates the whole client memory up front from superpage-backed
+	// regions, memory of such clients can not be updated at runtime.
+	StaticMemory bool `json:"static_memory"`
 
 	// 	// LegacyPty specifies whether to use legacy PTY mode (true) or micad's rpmsg PTY (false)
 	LegacyPty bool `json:"legacy_pty"`
 
+	// Client is the name of a running client adopted by the container, see
+	// ContainerAdopt. The client of a container is named after its id otherwise.
+	Client string `json:"client,omitempty"`
+
+	// Checkpoint is the directory of a checkpoint the client is restored from
+	// at its first start instead of booting, see CheckpointContainer.
+	Checkpoint string `json:"checkpoint,omitempty"`
+
+	// Workload names the container the same across re-creations of its pod,
+	// it keys the persistent copy of the record.
+	Workload string `json:"workload,omitempty"`
+
 	// Cmdline is the boot command line for the guest.
 	// TODO: consider passing the cmdline as a parameter to the pty, acting as if we "execute" command
 	Cmdline string `json:"cmdline"`
@@ -110,7 +153,7 @@ func newContainer(ctx context.Context, s *Sandbox, cc *ContainerConfig) (*Contai
 
 	c := &Container{
 		id:            cc.ID,
-		me:            libmica.MicaExecutor{Id: cc.ID},
+		me:            libmica.MicaExecutor{Id: cc.clientID()},
 		sandbox:       s,
 		config:        cc,
 		rootfs:        cc.Rootfs,
@@ -120,7 +163,11 @@ func newContainer(ctx context.Context, s *Sandbox, cc *ContainerConfig) (*Contai
 		ctx:           s.ctx,
 	}
 
-	if err := c.RestoreState(); err != nil {
+	if err := c.RestoreState(); err != nil && !errors.Is(err, er.ContainerNotFound) {
+		// a newer micrun wrote the record, overwriting it would lose what it knows
+		if errors.Is(err, store.ErrNewerSchema) {
+			return &Container{}, err
+		}
 		log.Warnf("Failed to restore container state: %v.", err)
 	}
 
@@ -141,6 +188,13 @@ func CleanupContainer(ctx context.Context, sandboxID string, containerID string,
 		return er.EmptyContainerID
 	}
 
+	// the running shim may persist the sandbox while it is torn down here
+	unlock, err := store.Default().Lock(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	sandbox, err := loadSandbox(ctx, sandboxID)
 	if err != nil {
 		if err == er.SandboxNotFound {
@@ -214,11 +268,20 @@ func (c *Container) start(ctx context.Context) error {
 		return err
 	}
 
-	if err := startClient(ctx, c.sandbox, c); err != nil {
+	start := startClient
+	switch {
+	case c.config.Client != "" && currentState == StateReady:
+		// the adopted client runs already, it is not booted again
+		start = takeOverClient
+	case c.config.Checkpoint != "" && currentState == StateReady:
+		start = restoreClient
+	}
+	if err := start(ctx, c.sandbox, c, currentState == StateStopped); err != nil {
 		log.Warnf("Failed to start container: %v, stopping it", err)
 		if err := c.stop(ctx, true); err != nil {
 			log.Warn("Failed to stop the container after start failed.")
 		}
+		return err
 	}
 
 	return c.setContainerState(ctx, StateRunning)
@@ -325,8 +388,11 @@ func (c *Container) monitorInfraExit(cmd *exec.Cmd) {
 }
 
 func (c *Container) ioStream(taskID string) (io.WriteCloser, io.Reader, io.Reader, error) {
-	_ = taskID
-	// TODO: hook up actual PTY/TTY endpoints for mica clients.
+	// the containerd stdio is one more session of the console hub
+	if c.hub != nil {
+		c.stdio = c.hub.Input(taskID)
+		return c.stdio, c.hub.Attach(taskID), bytes.NewReader(nil), nil
+	}
 	return noopWriteCloser{}, bytes.NewReader(nil), bytes.NewReader(nil), nil
 }
 
@@ -458,7 +524,17 @@ func (c *Container) create(ctx context.Context) error {
 		return c.setContainerState(ctx, StateReady)
 	}
 
-	if _, err := c.ensureClientPresence(); err != nil {
+	c.takeHistory()
+	if c.config.Checkpoint != "" {
+		if err := c.checkRestore(); err != nil {
+			return err
+		}
+	}
+	if c.config.Client != "" {
+		if err := c.adopt(); err != nil {
+			return err
+		}
+	} else if _, err := c.ensureClientPresence(); err != nil {
 		return err
 	}
 
@@ -468,7 +544,8 @@ func (c *Container) create(ctx context.Context) error {
 	return nil
 }
 
-// doStop performs the actual stop operation on the client.
+// doStop performs the actual stop operation on the client, which stays registered at micad
+// so that it can be restarted in place until the container is deleted.
 func (c *Container) doStop(force bool) error {
 	if c.config != nil && c.config.IsInfra {
 		if c.infraCmd == nil || c.infraCmd.Process == nil {
@@ -482,6 +559,7 @@ func (c *Container) doStop(force bool) error {
 	currentState := c.checkState()
 	if currentState == StateStopped {
 		log.Debugf("Container %s is already stopped.", c.id)
+		c.endConsole()
 		return nil
 	}
 
@@ -489,20 +567,30 @@ func (c *Container) doStop(force bool) error {
 		return err
 	}
 
-	if err := libmica.Stop(c.ID()); err != nil {
+	if err := libmica.Stop(c.clientID()); err != nil {
 		return err
 	}
+	// micad skips a restored domain as stopped, it is destroyed through the pedestal
+	if domainAlive(c.clientID()) {
+		if err := ped.Destroy(c.clientID()); err != nil {
+			return err
+		}
+	}
+	c.endConsole()
 	return nil
 }
 
-// stop stops the container.
+// stop stops the container, a non forced stop lets the client power off before destroying it.
 // for semantic continuation, register client at micad even if client is not here
 func (c *Container) stop(ctx context.Context, force bool) error {
-	if _, err := c.ensureClientPresence(); err != nil {
+	state, err := c.ensureClientPresence()
+	if err != nil {
 		return err
 	}
+	if !force && state == StateRunning && c.config != nil && !c.config.IsInfra {
+		c.shutdownGracefully(ctx)
+	}
 
-	var err error
 	if err = c.doStop(force); err != nil {
 		log.Debugf("failed to stop container %s: %v", c.id, err)
 		return err
@@ -532,7 +620,8 @@ func (c *Container) kill() error {
 	}
 	log.Debugf("Container state is %s.", currentState)
 
-	if libmica.ClientNotExist(c.id) {
+	if libmica.ClientNotExist(c.clientID()) {
+		c.endConsole()
 		return c.setContainerState(c.ctx, StateStopped)
 	} else if err := c.doStop(true); err != nil {
 		log.Debugf("failed to stop container %s: %v", c.id, err)
@@ -564,10 +653,16 @@ func (c *Container) delete(ctx context.Context) error {
 	}
 
 	if c.config == nil || !c.config.IsInfra {
-		if err := libmica.Remove(c.id); err != nil {
+		if err := libmica.Remove(c.clientID()); err != nil {
 			log.Debugf("Failed to remove container %s.", err)
 			return err
 		}
+		if err := irqaffinity.Release(c.id); err != nil {
+			log.Warnf("failed to restore irq affinity released by %s: %v", c.id, err)
+		}
+		if err := passthrough.Release(c.id); err != nil {
+			log.Warnf("failed to release devices passed through to %s: %v", c.id, err)
+		}
 	}
 	if err := c.sandbox.removeContainer(c.id); err != nil {
 		return err
@@ -575,6 +670,10 @@ func (c *Container) delete(ctx context.Context) error {
 	if err := c.sandbox.StoreSandbox(ctx); err != nil {
 		return fmt.Errorf("failed to store sandbox")
 	}
+	if err := store.Default().DeleteContainer(c.sandbox.SandboxID(), c.id); err != nil {
+		log.Warnf("failed to remove record of container %s: %v", c.id, err)
+	}
+	c.closeConsole()
 	if err := utils.RemoveContainerCacheDir(c.id); err != nil {
 		log.Warnf("failed to remove cache directory for container %s: %v", c.id, err)
 	}
@@ -593,7 +692,7 @@ func (c *Container) pause(ctx context.Context) error {
 	if c.config != nil && c.config.IsInfra {
 		return c.setContainerState(ctx, StatePaused)
 	}
-	if err := libmica.Pause(c.id); err != nil {
+	if err := libmica.Pause(c.clientID()); err != nil {
 		return er.MicadOpFailed
 	}
 	return c.setContainerState(ctx, StatePaused)
@@ -615,7 +714,7 @@ func (c *Container) resume(ctx context.Context) error {
 		return c.setContainerState(ctx, StateRunning)
 	}
 	log.Debugf("resuming container %s (restarting RTOS)", c.id)
-	if err := libmica.Start(c.id); err != nil {
+	if err := libmica.Start(c.clientID()); err != nil {
 		return er.MicadOpFailed
 	}
 	return c.setContainerState(ctx, StateRunning)
@@ -689,10 +788,14 @@ func (c *Container) extractChanges(resources specs.LinuxResources) (*ped.Essenti
 	}
 
 	if mem := resources.Memory; mem != nil && mem.Limit != nil {
-		limitMiB := uint32(*mem.Limit >> 20)
-		pedRes.MemoryMinMB = limitMiB
-		pedRes.MemoryMaxMB = copyUint32(limitMiB)
-		hasUpdates = true
+		if c.config.StaticMemory {
+			log.Warnf("container %s uses static hugepage memory, ignore memory update", c.id)
+		} else {
+			limitMiB := uint32(*mem.Limit >> 20)
+			pedRes.MemoryMinMB = limitMiB
+			pedRes.MemoryMaxMB = copyUint32(limitMiB)
+			hasUpdates = true
+		}
 	}
 
 	return pedRes, hasUpdates
@@ -721,7 +824,7 @@ func (c *Container) applyChanges(ctx context.Context, pedRes *ped.EssentialResou
 		}
 	}
 
-	if mem := resources.Memory; mem != nil && mem.Limit != nil {
+	if mem := resources.Memory; mem != nil && mem.Limit != nil && !c.config.StaticMemory {
 		res.Memory.Limit = mem.Limit
 	}
 
@@ -779,6 +882,48 @@ func (c *Container) Signal(ctx context.Context, signal syscall.Signal) error {
 		return fmt.Errorf("client os is not running, ready or paused, can not signal container")
 	}
 
+	controls, err := c.consoleControls()
+	if err != nil {
+		return err
+	}
+	action, ok := controls.Signal(signal)
+	if !ok {
+		return fmt.Errorf("%w: %v is not supported by client os %q", er.InvalidSignal, signal, c.os())
+	}
+	switch action.Kind {
+	case osprofile.SignalIgnore:
+		log.Debugf("signal %v ignored by container %s", signal, c.id)
+	case osprofile.SignalStop:
+		return fmt.Errorf("%w: %v stops the client, use KillContainer", er.InvalidSignal, signal)
+	case osprofile.SignalCrashDump:
+		return c.crashDump()
+	case osprofile.SignalShutdown:
+		return c.shutdown(action.Command)
+	default:
+		if c.hub == nil {
+			return fmt.Errorf("console of container %s is not started", c.id)
+		}
+		log.Debugf("signal %v of container %s: %q", signal, c.id, action.Console())
+		return c.hub.Inject(action.Console())
+	}
+	return nil
+}
+
+// crashDump captures the memory image of the client under the micrun state dir.
+// Only Xen can read the memory of a running client from the host.
+func (c *Container) crashDump() error {
+	if HostPedType != ped.Xen {
+		return fmt.Errorf("%w: crash dump of client %s on %v", er.NotSupported, c.id, HostPedType)
+	}
+	dir := filepath.Join(defs.MicrunStateDir, "crash")
+	if err := os.MkdirAll(dir, defs.DirMode); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.core", c.id, time.Now().Format("20060102T150405")))
+	if err := ped.DumpCore(c.clientID(), path); err != nil {
+		return err
+	}
+	log.Infof("crash dump of container %s written to %s", c.id, path)
 	return nil
 }
 
@@ -918,7 +1063,7 @@ func (c *Container) checkState() StateString {
 		return c.state.State
 	}
 
-	if libmica.ClientNotExist(c.id) {
+	if libmica.ClientNotExist(c.clientID()) {
 		if c.state.State != StateDown {
 			if err := c.setContainerState(c.ctx, StateDown); err != nil {
 				log.Warnf("failed to mark container %s as down: %v", c.id, err)
@@ -937,7 +1082,7 @@ func (c *Container) ensureClientPresence() (StateString, error) {
 		return state, nil
 	}
 
-	if c.shouldPresent() && !libmica.ClientNotExist(c.id) {
+	if c.shouldPresent() && !libmica.ClientNotExist(c.clientID()) {
 		if err := c.registerClient(); err != nil {
 			return StateDown, err
 		}
@@ -965,7 +1110,15 @@ func (c *Container) registerClient() error {
 		return err
 	}
 
+	// claim devices before the client owns them, another pod may hold them already
+	if err := passthrough.Claim(c.id, c.config.Passthrough); err != nil {
+		return err
+	}
+
 	if err := libmica.Create(conf); err != nil {
+		if rerr := passthrough.Release(c.id); rerr != nil {
+			log.Warnf("failed to release devices of %s: %v", c.id, rerr)
+		}
 		return err
 	}
 
@@ -995,6 +1148,11 @@ func (c *Container) setupMemory() error {
 		return nil
 	}
 
+	// static memory is fully allocated when the client is created
+	if c.config.StaticMemory {
+		return nil
+	}
+
 	limit := c.config.memoryLimitMB()
 	if limit == 0 {
 		return nil
@@ -1017,6 +1175,54 @@ func (c *Container) setupMemory() error {
 	return nil
 }
 
+// steerIRQs moves host irqs away from the client cpuset when the sandbox asks for it.
+// On Xen the host irqs are bound to dom0 vcpus, so the steering makes no sense there.
+// Only an exclusive cpuset is steered, cpus shared with other clients stay as they are.
+func (c *Container) steerIRQs() {
+	if c.sandbox == nil || c.sandbox.config == nil || !c.sandbox.config.IRQAffinitySteering {
+		return
+	}
+	if HostPedType == ped.Xen {
+		return
+	}
+	cpus := c.GetClientCPU()
+	if cpus == "" {
+		return
+	}
+	if !c.exclusiveCPUs() {
+		log.Debugf("cpus %s of %s are shared, host irqs are not steered", cpus, c.id)
+		return
+	}
+	if err := irqaffinity.Isolate(c.id, cpus); err != nil {
+		log.Warnf("failed to steer host irqs away from cpus %s of %s: %v", cpus, c.id, err)
+	}
+}
+
+// exclusiveCPUs reports whether the cpuset of the client is its own: the sandbox
+// does not share one cpu pool and no other container of the sandbox overlaps it.
+func (c *Container) exclusiveCPUs() bool {
+	if c.sandbox.config.SharedCPUPool {
+		return false
+	}
+	own, err := cpuset.Parse(c.config.CPUSet())
+	if err != nil || own.IsEmpty() {
+		return false
+	}
+	for _, cc := range c.sandbox.config.ContainerConfigs {
+		if cc == nil || cc.ID == c.id || cc.IsInfra {
+			continue
+		}
+		other, err := cpuset.Parse(cc.CPUSet())
+		if err != nil {
+			return false
+		}
+		if !own.Intersection(other).IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Container) GetClientCPU() string {
 	if c.cpuUnset() {
 		return ""
@@ -1024,102 +1230,52 @@ func (c *Container) GetClientCPU() string {
 	return c.config.cpuMask()
 }
 
-// SaveState persists the container's state to disk at two locations for redundancy.
-func (c *Container) SaveState() error {
-	serializable := struct {
-		ID            string          `json:"id"`
-		SandboxID     string          `json:"sandbox_id"`
-		State         ContainerState  `json:"state"`
-		Config        ContainerConfig `json:"config"`
-		Mounts        []Mount         `json:"mounts"`
-		ContainerPath string          `json:"container_path"`
-	}{
+// ContainerStorage is the record of a container in the store.
+type ContainerStorage struct {
+	Schema        int             `json:"schema"`
+	ID            string          `json:"id"`
+	SandboxID     string          `json:"sandbox_id"`
+	State         ContainerState  `json:"state"`
+	Config        ContainerConfig `json:"config"`
+	Mounts        []Mount         `json:"mounts"`
+	ContainerPath string          `json:"container_path"`
+	// BootID is the boot of the node the record was saved in.
+	BootID string `json:"boot_id,omitempty"`
+}
+
+func (c *Container) record() ContainerStorage {
+	return ContainerStorage{
+		Schema:        containerSchemaVersion,
 		ID:            c.id,
 		SandboxID:     c.sandbox.SandboxID(),
 		State:         c.state,
 		Config:        *c.config,
 		Mounts:        c.mounts,
 		ContainerPath: c.containerPath,
+		BootID:        bootID(),
 	}
+}
 
-	failed, failed1 := false, false
-	var err error
-	var err1 error
-
-	cwd, err := os.Getwd()
-	if err != nil {
-		log.Warnf("Failed to get current working directory: %v", err)
-		cwd = "."
-	}
-	stateInBundle := filepath.Join(cwd, c.containerPath, defs.MicrunContainerStateFile)
-	stateInMicrunDir := filepath.Join(defs.MicrunStateDir, c.containerPath, defs.MicrunContainerStateFile)
-	log.Infof("stateInBundle: %s", stateInBundle)
-
-	bundleDir := filepath.Dir(stateInBundle)
-	if err := utils.EnsureDir(bundleDir, defs.DirMode); err != nil {
-		log.Warnf("Failed to ensure bundle directory: %v.", err)
-	}
-	if err := utils.EnsureDir(filepath.Dir(stateInMicrunDir), defs.DirMode); err != nil {
-		log.Warnf("Failed to ensure micran state directory: %v.", err)
-	}
-
-	if err = utils.SaveStructToJSON(stateInBundle, serializable); err != nil {
-		failed = true
-		err = fmt.Errorf("failed to save state to <%s>: %w", stateInBundle, err)
-	}
-
-	if err1 = utils.SaveStructToJSON(stateInMicrunDir, serializable); err1 != nil {
-		failed1 = true
-		err1 = fmt.Errorf("failed to save state to <%s>: %w", stateInMicrunDir, err1)
-	}
-
-	if failed1 && failed {
-		return fmt.Errorf("failed to save container state to both locations: %w, %w", err, err1)
+// SaveState persists the container's state to its record in the store.
+func (c *Container) SaveState() error {
+	rec := c.record()
+	if err := store.Default().SaveContainer(rec.SandboxID, c.id, &rec); err != nil {
+		return fmt.Errorf("failed to save state of container %s: %w", c.id, err)
 	}
+	c.persist(&rec)
 	return nil
 }
 
-// RestoreState loads the container's state from disk, trying the primary and fallback locations.
+// RestoreState loads the container's state from its record in the store.
 func (c *Container) RestoreState() error {
-	type ContainerStorage struct {
-		ID            string          `json:"id"`
-		SandboxID     string          `json:"sandbox_id"`
-		State         ContainerState  `json:"state"`
-		Config        ContainerConfig `json:"config"`
-		Mounts        []Mount         `json:"mounts"`
-		ContainerPath string          `json:"container_path"`
-	}
-
-	var storage ContainerStorage
-
-	stateInMicrunDir := filepath.Join(defs.MicrunStateDir, c.id, defs.MicrunContainerStateFile)
-	raw, err := utils.RestoreStructFromJSON(stateInMicrunDir)
-
-	if err != nil {
-		cwd, err := os.Getwd()
-		if err != nil {
-			log.Warnf("Failed to get current working directory: %v", err)
-			cwd = "."
-		}
-		stateInBundle := filepath.Join(cwd, c.containerPath, defs.MicrunContainerStateFile)
-		raw, err = utils.RestoreStructFromJSON(stateInBundle)
-		if err != nil {
-			return fmt.Errorf("failed to restore container state from both locations: %w", err)
-		}
-	}
-
-	jsonBytes, err := json.Marshal(raw)
+	rec, err := loadContainerRecord(store.Default(), c.sandbox.SandboxID(), c.id)
 	if err != nil {
-		return fmt.Errorf("failed to marshal raw data: %w", err)
+		return fmt.Errorf("failed to restore state of container %s: %w", c.id, err)
 	}
 
-	if err := json.Unmarshal(jsonBytes, &storage); err != nil {
-		return fmt.Errorf("failed to unmarshal container storage: %w", err)
-	}
-
-	c.state = storage.State
-	c.mounts = storage.Mounts
-	c.containerPath = storage.ContainerPath
+	c.state = rec.State
+	c.mounts = rec.Mounts
+	c.containerPath = rec.ContainerPath
 	c.updateExitNotifier(c.state.State)
 
 	return nil
@@ -1140,7 +1296,7 @@ func (c *Container) stats() (*ContainerStats, error) {
 	if vcpuInfo, err := ped.XlVcpuList(); err == nil && vcpuInfo != nil {
 		var entries []ped.VCPUEntry
 
-		if v, ok := vcpuInfo.DomainVCPUMap[c.id]; ok {
+		if v, ok := vcpuInfo.DomainVCPUMap[c.clientID()]; ok {
 			entries = v
 		}
 		for _, e := range entries {
@@ -1155,6 +1311,11 @@ func (c *Container) stats() (*ContainerStats, error) {
 	if thrMB == 0 {
 		thrMB = c.config.memoryLimitMB()
 	}
+	if c.config.StaticMemory {
+		// the whole region is allocated at creation and never changes
+		curMB = c.config.staticMemoryMB()
+		thrMB = curMB
+	}
 	usageBytes := uint64(curMB) << 20
 	limitBytes := uint64(thrMB) << 20
 
@@ -1172,7 +1333,9 @@ func (c *Container) stats() (*ContainerStats, error) {
 					MaxEver: limitBytes, // Conservative default until HWM tracking exists.
 					Usage:   usageBytes,
 				},
-				Stats: map[string]uint64{}, // Reserved for future detailed stats.
+				Stats:        map[string]uint64{}, // Reserved for future detailed stats.
+				Static:       c.config.StaticMemory,
+				HugePageSize: c.config.hugePageSize(),
 			},
 		},
 		NetworkStats: nil,
@@ -1184,7 +1347,11 @@ func (c *Container) stats() (*ContainerStats, error) {
 func (c *Container) setVcpuAffinity(cpuSet cpuset.CPUSet) error {
 	var result *multierror.Error
 	cpulist := cpuSet.ToSlice()
-	if err := c.me.VcpuPin(cpulist); err != nil {
+	pin := c.me.VcpuPin
+	if c.config.VCPUBinding {
+		pin = c.me.VcpuBind
+	}
+	if err := pin(cpulist); err != nil {
 		result = multierror.Append(result, err)
 	}
 
@@ -1199,16 +1366,6 @@ func (c *Container) setVcpuAffinity(cpuSet cpuset.CPUSet) error {
 	return ret
 }
 
-// winresize resizes the container's PTY.
-// TODO: Resize the terminal connected to /dev/ttyRPMSG*.
-func (c *Container) winresize(height, width uint32) error {
-	if c.notOperational() {
-		return fmt.Errorf("container not ready or running, impossible to resize the container pty")
-	}
-	log.Debugf("resizing PTY for container %s to [%dx%d]", c.id, width, height)
-	return nil
-}
-
 // firmware is the elf file of rtos
 func (c *Container) getFirmware() string {
 	return c.config.ImageAbsPath
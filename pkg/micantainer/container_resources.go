@@ -6,6 +6,8 @@ import (
 	log "micrun/logger"
 	"micrun/pkg/libmica"
 	"micrun/pkg/pedestal"
+	"strconv"
+	"strings"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
@@ -201,6 +203,14 @@ func (cfg *ContainerConfig) SetMemoryReservationMB(mb uint32) {
 	cfg.setMemoryReservationMB(mb)
 }
 
+// SetCPUSet overrides the container cpuset, e.g. with pCPUs allocated by the device plugin.
+// 设置 RTOS Client CPUS（硬亲和性）
+func (cfg *ContainerConfig) SetCPUSet(cpus string) {
+	if cpu := cfg.ensureCPU(); cpu != nil {
+		cpu.Cpus = cpus
+	}
+}
+
 // ParseOCIResources parses both CPU and Memory resource limits from OCI spec in a single pass
 // 遵循资源映射规范：
 // 1. CPU 资源：Share -> Weight, Quota/Period -> Capacity, cpuset -> CPUS
@@ -286,6 +296,11 @@ func (r *ContainerConfig) ParseOCIResources(spec *specs.Spec) error {
 		log.Warn("No Memory resources specified in OCI spec")
 	}
 
+	// Container hugepageLimits -> RTOS Client static memory
+	if spec.Linux != nil && spec.Linux.Resources != nil && len(spec.Linux.Resources.HugepageLimits) > 0 {
+		r.Resources.HugepageLimits = append([]specs.LinuxHugepageLimit(nil), spec.Linux.Resources.HugepageLimits...)
+	}
+
 	return nil
 }
 
@@ -320,6 +335,75 @@ func cloneLinuxMemory(src *specs.LinuxMemory) *specs.LinuxMemory {
 	}
 }
 
+// HasHugepageLimits reports whether the container requests hugepages.
+func (cfg *ContainerConfig) HasHugepageLimits() bool {
+	return cfg != nil && cfg.Resources != nil && len(cfg.Resources.HugepageLimits) > 0
+}
+
+// hugePageSize returns the page size backing static memory, the largest requested
+// hugepage size wins; Xen superpages (2MB) are used by default.
+func (cfg *ContainerConfig) hugePageSize() string {
+	if cfg == nil || !cfg.StaticMemory {
+		return ""
+	}
+	size, best := defaultHugePageSize, uint64(0)
+	if cfg.Resources != nil {
+		for _, lim := range cfg.Resources.HugepageLimits {
+			if b, err := pageSizeBytes(lim.Pagesize); err == nil && b > best {
+				size, best = lim.Pagesize, b
+			}
+		}
+	}
+	return size
+}
+
+// staticMemoryMB is the memory of a static memory client: the sum of hugepage limits
+// or, without them, the configured memory, rounded up to whole hugepages.
+// The same value is used as memory and memory threshold, so that the pedestal has no
+// room to balloon the client.
+func (cfg *ContainerConfig) staticMemoryMB() uint32 {
+	var total uint64
+	if cfg.Resources != nil {
+		for _, lim := range cfg.Resources.HugepageLimits {
+			total += lim.Limit
+		}
+	}
+	if total == 0 {
+		total = uint64(cfg.containerMaxMemMB()) * miB
+	}
+
+	page, err := pageSizeBytes(cfg.hugePageSize())
+	if err != nil || page == 0 {
+		page, _ = pageSizeBytes(defaultHugePageSize)
+	}
+	total = (total + page - 1) / page * page
+	return uint32(total / miB)
+}
+
+const defaultHugePageSize = "2MB"
+
+// pageSizeBytes parses OCI hugepage sizes such as "64KB", "2MB" or "1GB".
+func pageSizeBytes(size string) (uint64, error) {
+	units := []struct {
+		suffix string
+		factor uint64
+	}{
+		{"KB", 1 << 10},
+		{"MB", 1 << 20},
+		{"GB", 1 << 30},
+	}
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(size, u.suffix); ok {
+			n, err := strconv.ParseUint(num, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid hugepage size %q: %w", size, err)
+			}
+			return n * u.factor, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid hugepage size %q", size)
+}
+
 func bytesToMiB(value *int64) uint32 {
 	if value == nil || *value <= 0 {
 		return 0
@@ -0,0 +1,80 @@
+package micantainer
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestStaticMemoryMB(t *testing.T) {
+	limit := int64(33 * miB)
+	tests := []struct {
+		name     string
+		res      *specs.LinuxResources
+		wantMB   uint32
+		wantPage string
+	}{
+		{
+			name: "hugepage limits are summed",
+			res: &specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{
+					{Pagesize: "2MB", Limit: 64 * miB},
+					{Pagesize: "2MB", Limit: 32 * miB},
+				},
+			},
+			wantMB:   96,
+			wantPage: "2MB",
+		},
+		{
+			name:     "memory limit rounded up to superpages",
+			res:      &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: &limit}},
+			wantMB:   34,
+			wantPage: defaultHugePageSize,
+		},
+		{
+			name: "largest page size wins",
+			res: &specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{
+					{Pagesize: "2MB", Limit: 2 * miB},
+					{Pagesize: "1GB", Limit: 512 * miB},
+				},
+			},
+			wantMB:   1024,
+			wantPage: "1GB",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &ContainerConfig{StaticMemory: true, Resources: tt.res}
+			if got := cfg.hugePageSize(); got != tt.wantPage {
+				t.Errorf("hugePageSize() = %q, want %q", got, tt.wantPage)
+			}
+			if got := cfg.staticMemoryMB(); got != tt.wantMB {
+				t.Errorf("staticMemoryMB() = %d, want %d", got, tt.wantMB)
+			}
+		})
+	}
+}
+
+func TestExclusiveCPUs(t *testing.T) {
+	cfg := func(id, cpus string) *ContainerConfig {
+		return &ContainerConfig{ID: id, Resources: &specs.LinuxResources{CPU: &specs.LinuxCPU{Cpus: cpus}}}
+	}
+	a, b := cfg("a", "2-3"), cfg("b", "4")
+	sc := &SandboxConfig{ContainerConfigs: map[string]*ContainerConfig{"a": a, "b": b}}
+	c := &Container{id: "a", sandbox: &Sandbox{config: sc}, config: a}
+
+	if !c.exclusiveCPUs() {
+		t.Error("cpus of no other container are exclusive")
+	}
+	sc.ContainerConfigs["c"] = cfg("c", "3-4")
+	if c.exclusiveCPUs() {
+		t.Error("cpus overlapping another container are not exclusive")
+	}
+	delete(sc.ContainerConfigs, "c")
+	sc.SharedCPUPool = true
+	if c.exclusiveCPUs() {
+		t.Error("cpus of a shared cpu pool are not exclusive")
+	}
+}
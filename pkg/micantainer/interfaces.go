@@ -5,6 +5,8 @@ import (
 	"io"
 	"syscall"
 
+	"micrun/pkg/osprofile"
+
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
@@ -59,4 +61,24 @@ type SandboxTraits interface {
 	UpdateContainer(ctx context.Context, id string, resources specs.LinuxResources) error
 	WaitContainerExit(ctx context.Context, id string) (int32, error)
 	WinResize(ctx context.Context, containerID string, height, width uint32) error
+	// ConsoleControls returns how stdin keys and signals are translated for the client.
+	ConsoleControls(containerID string) (*osprofile.Controls, error)
+	SignalContainer(ctx context.Context, containerID string, signal syscall.Signal) error
+	// ContainerReadiness delivers the readiness of a client whose start did not wait for it,
+	// and whether the start fails when the client is not ready in time.
+	ContainerReadiness(containerID string) (<-chan error, bool)
+	// ContainerExited delivers the exit status printed on the client console.
+	ContainerExited(containerID string) <-chan int
+	// RestartContainer boots a client which went down by itself again in place.
+	RestartContainer(ctx context.Context, containerID string) error
+	// ContainerRestartCount is the number of restarts in place of a container.
+	ContainerRestartCount(containerID string) int
+	// RecordContainerExit records why the client went down in the container record.
+	RecordContainerExit(id, reason string, code int) error
+	// CheckpointContainer writes the record of a running client to a directory, the
+	// returned function saves the client itself and is safe to call without the sandbox lock.
+	CheckpointContainer(ctx context.Context, id, dir string) (func() error, error)
+	// ExecContainer emulates exec by running a command in the client shell, the
+	// returned function runs it and is safe to call without the sandbox lock.
+	ExecContainer(ctx context.Context, containerID string, args []string) (ExecFunc, error)
 }
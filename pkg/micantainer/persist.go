@@ -0,0 +1,223 @@
+package micantainer
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	defs "micrun/definitions"
+	er "micrun/errors"
+	log "micrun/logger"
+	"micrun/pkg/cpuset"
+	"micrun/pkg/store"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// The records below /run are gone after a node reboot. With persist_records a
+// copy of each record is kept below PersistentDataDir too, keyed by workload
+// names instead of ids: a pod re-created by kubelet after the reboot gets new
+// ids, but finds the placement of its clients and their history there.
+//
+// The copies are removed with the pod, a node reboot deletes no pod. The
+// copies of a pod which does not come back are collected by gc after
+// gc.RecordMaxAge.
+var persistentStore = store.New(defs.PersistentDataDir)
+
+// liveStore holds the records of this boot, which tell the cpus in use.
+var liveStore = store.Default()
+
+const bootIDFile = "/proc/sys/kernel/random/boot_id"
+
+// bootID tells the boots of the node apart, it is empty when unknown.
+func bootID() string {
+	raw, err := os.ReadFile(bootIDFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func (s *Sandbox) persist(rec *SandboxStorage) {
+	if s.config == nil || !s.config.PersistRecords || s.config.Workload == "" {
+		return
+	}
+	if err := persistentStore.SaveSandbox(s.config.Workload, rec); err != nil {
+		log.Warnf("failed to keep a persistent record of sandbox %s: %v", s.id, err)
+	}
+}
+
+// unpersist removes the persistent records of a deleted pod, unless a sandbox
+// re-creating the workload replaced them already.
+func (s *Sandbox) unpersist() {
+	if s.config == nil || !s.config.PersistRecords || s.config.Workload == "" {
+		return
+	}
+	var rec struct {
+		ID string `json:"id"`
+	}
+	if err := persistentStore.PeekSandbox(s.config.Workload, &rec); err != nil || rec.ID != s.id {
+		return
+	}
+	if err := persistentStore.DeleteSandbox(s.config.Workload); err != nil {
+		log.Warnf("failed to remove the persistent records of sandbox %s: %v", s.id, err)
+	}
+}
+
+func (c *Container) persist(rec *ContainerStorage) {
+	sc := c.sandbox.config
+	if sc == nil || !sc.PersistRecords || sc.Workload == "" || c.config.IsInfra || c.config.Workload == "" {
+		return
+	}
+	if err := persistentStore.SaveContainer(sc.Workload, c.config.Workload, rec); err != nil {
+		log.Warnf("failed to keep a persistent record of container %s: %v", c.id, err)
+	}
+}
+
+// persistedContainer loads the persistent record of container workload of sandbox workload sandbox.
+func persistedContainer(sandbox, workload string) (*ContainerStorage, error) {
+	return loadContainerRecord(persistentStore, sandbox, workload)
+}
+
+// takePlacement re-creates the placement of a workload which ran before the
+// node rebooted: the cpu pool of the sandbox, the cpusets and memory of its
+// clients. Within one boot the configs are taken as they are.
+func (sc *SandboxConfig) takePlacement() {
+	if !sc.PersistRecords || sc.Workload == "" {
+		return
+	}
+	rec, err := loadSandboxRecord(persistentStore, sc.Workload)
+	if err != nil {
+		if !errors.Is(err, er.SandboxNotFound) {
+			log.Warnf("persistent record of sandbox %s ignored: %v", sc.Workload, err)
+		}
+		return
+	}
+	if rec.BootID == bootID() {
+		return
+	}
+	log.Infof("sandbox %s takes the placement of %s before the node reboot", sc.ID, sc.Workload)
+	sc.SharedCPUPool = rec.Config.SharedCPUPool
+	sc.EnableVCPUsPinning = rec.Config.EnableVCPUsPinning
+	for _, cc := range sc.ContainerConfigs {
+		sc.takeContainerPlacement(cc)
+	}
+}
+
+// takeContainerPlacement gives cc the placement of its workload before the node
+// reboot, unless micrun-device-plugin allocated its cpus or the saved cpus are
+// taken by a container created since the reboot.
+func (sc *SandboxConfig) takeContainerPlacement(cc *ContainerConfig) {
+	if !sc.PersistRecords || sc.Workload == "" || cc == nil || cc.IsInfra || cc.Workload == "" {
+		return
+	}
+	rec, err := persistedContainer(sc.Workload, cc.Workload)
+	if err != nil {
+		if !errors.Is(err, er.ContainerNotFound) {
+			log.Warnf("persistent record of container %s ignored: %v", cc.Workload, err)
+		}
+		return
+	}
+	if rec.BootID == bootID() {
+		return
+	}
+	if cpus := cc.Annotations[defs.ContainerCPUSet]; cpus != "" {
+		log.Infof("container %s keeps cpus %q of the device plugin, placement of %s before the node reboot ignored",
+			cc.ID, cpus, cc.Workload)
+		return
+	}
+	if saved := rec.Config.CPUSet(); saved != "" {
+		cpus, err := cpuset.Parse(saved)
+		if err != nil {
+			log.Warnf("persistent record of container %s ignored, invalid cpus %q: %v", cc.Workload, saved, err)
+			return
+		}
+		if owner := cpusTakenBy(cpus, cc.ID); owner != "" {
+			log.Warnf("placement of %s before the node reboot ignored, cpus %q are taken by container %s",
+				cc.Workload, saved, owner)
+			return
+		}
+	}
+	cc.takePlacement(&rec.Config)
+	log.Infof("container %s takes cpus %q and %d MiB of %s before the node reboot",
+		cc.ID, cc.CPUSet(), cc.memoryLimitMB(), cc.Workload)
+}
+
+// cpusTakenBy returns the container other than id whose cpuset intersects cpus.
+func cpusTakenBy(cpus cpuset.CPUSet, id string) string {
+	sandboxes, err := liveStore.Sandboxes()
+	if err != nil {
+		log.Warnf("cpus in use are not known: %v", err)
+		return ""
+	}
+	for _, sid := range sandboxes {
+		containers, _ := liveStore.Containers(sid)
+		for _, cid := range containers {
+			if cid == id {
+				continue
+			}
+			rec, err := loadContainerRecord(liveStore, sid, cid)
+			if err != nil || rec.Config.IsInfra {
+				continue
+			}
+			other, err := cpuset.Parse(rec.Config.CPUSet())
+			if err == nil && !other.Intersection(cpus).IsEmpty() {
+				return cid
+			}
+		}
+	}
+	return ""
+}
+
+// takePlacement gives cfg the cpus and memory of saved.
+func (cfg *ContainerConfig) takePlacement(saved *ContainerConfig) {
+	if cpus := saved.CPUSet(); cpus != "" {
+		cfg.SetCPUSet(cpus)
+	}
+	cfg.VCPUNum = saved.VCPUNum
+	cfg.PCPUNum = saved.PCPUNum
+	cfg.MaxVcpuNum = saved.MaxVcpuNum
+	cfg.VCPUBinding = saved.VCPUBinding
+	cfg.MemoryThresholdMB = saved.MemoryThresholdMB
+	cfg.StaticMemory = saved.StaticMemory
+	if mem := saved.memorySpec(); mem != nil {
+		if cfg.Resources == nil {
+			cfg.Resources = &specs.LinuxResources{}
+		}
+		m := *mem
+		cfg.Resources.Memory = &m
+	}
+}
+
+// takeHistory carries the restart count and the last exit of the workload
+// over to the container re-creating it.
+func (c *Container) takeHistory() {
+	sc := c.sandbox.config
+	if sc == nil || !sc.PersistRecords || sc.Workload == "" || c.config.IsInfra || c.config.Workload == "" {
+		return
+	}
+	rec, err := persistedContainer(sc.Workload, c.config.Workload)
+	if err != nil {
+		return
+	}
+	c.state.RestartCount, c.state.LastExit = history(rec, bootID(), time.Now())
+	log.Infof("container %s: restart_count %d, last exit %s", c.id, c.state.RestartCount, c.state.LastExit)
+}
+
+// history is the restart count and the last exit of a container re-creating
+// the one of rec. A client still running in rec did not exit through micrun.
+func history(rec *ContainerStorage, boot string, now time.Time) (int, *ExitReason) {
+	switch rec.State.State {
+	case StateRunning, StatePaused:
+		reason := ExitLost
+		if rec.BootID != boot {
+			reason = ExitNodeReboot
+		}
+		return rec.State.RestartCount + 1, &ExitReason{Reason: reason, At: now}
+	case StateStopped:
+		return rec.State.RestartCount + 1, rec.State.LastExit
+	}
+	// created but never started
+	return rec.State.RestartCount, rec.State.LastExit
+}
@@ -0,0 +1,144 @@
+package micantainer
+
+import (
+	"testing"
+	"time"
+
+	defs "micrun/definitions"
+	"micrun/pkg/store"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestTakePlacement(t *testing.T) {
+	defer func(st, live *store.Store) { persistentStore, liveStore = st, live }(persistentStore, liveStore)
+	persistentStore = store.New(t.TempDir())
+	liveStore = store.New(t.TempDir())
+
+	limit := int64(64 * miB)
+	saved := ContainerConfig{
+		ID:       "rtos-old",
+		Workload: "rtos",
+		VCPUNum:  2,
+		PCPUNum:  2,
+		Resources: &specs.LinuxResources{
+			CPU:    &specs.LinuxCPU{Cpus: "2-3"},
+			Memory: &specs.LinuxMemory{Limit: &limit},
+		},
+	}
+	save := func(boot string) {
+		sb := SandboxStorage{Schema: sandboxSchemaVersion, ID: "pod-old", BootID: boot,
+			Config: SandboxConfig{SharedCPUPool: true}}
+		if err := persistentStore.SaveSandbox("default_pod", &sb); err != nil {
+			t.Fatal(err)
+		}
+		c := ContainerStorage{Schema: containerSchemaVersion, ID: "rtos-old", BootID: boot, Config: saved}
+		if err := persistentStore.SaveContainer("default_pod", "rtos", &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newConfig := func() *SandboxConfig {
+		return &SandboxConfig{
+			ID:             "pod-new",
+			Workload:       "default_pod",
+			PersistRecords: true,
+			ContainerConfigs: map[string]*ContainerConfig{
+				"rtos-new": {ID: "rtos-new", Workload: "rtos", VCPUNum: 1, Resources: &specs.LinuxResources{}},
+			},
+		}
+	}
+
+	// the records of the previous boot are taken over
+	save("previous-boot")
+	sc := newConfig()
+	sc.takePlacement()
+	cc := sc.ContainerConfigs["rtos-new"]
+	if !sc.SharedCPUPool || cc.CPUSet() != "2-3" || cc.memoryLimitMB() != 64 || cc.VCPUNum != 2 {
+		t.Fatalf("placement not taken: pool %v, cpus %q, %d MiB, %d vcpus",
+			sc.SharedCPUPool, cc.CPUSet(), cc.memoryLimitMB(), cc.VCPUNum)
+	}
+
+	// cpus allocated by the device plugin are kept
+	sc = newConfig()
+	sc.ContainerConfigs["rtos-new"].Annotations = map[string]string{defs.ContainerCPUSet: "4"}
+	sc.takePlacement()
+	if cc := sc.ContainerConfigs["rtos-new"]; cc.CPUSet() != "" || cc.VCPUNum != 1 {
+		t.Fatalf("placement taken over the device plugin: %+v", cc)
+	}
+
+	// cpus taken since the reboot are not given twice
+	other := ContainerStorage{Schema: containerSchemaVersion, ID: "other", Config: ContainerConfig{
+		ID: "other", Resources: &specs.LinuxResources{CPU: &specs.LinuxCPU{Cpus: "3"}}}}
+	if err := liveStore.SaveContainer("pod-other", "other", &other); err != nil {
+		t.Fatal(err)
+	}
+	sc = newConfig()
+	sc.takePlacement()
+	if cc := sc.ContainerConfigs["rtos-new"]; cc.CPUSet() != "" || cc.VCPUNum != 1 {
+		t.Fatalf("placement taken on cpus in use: %+v", cc)
+	}
+
+	// within the same boot the config of the new pod wins
+	save(bootID())
+	sc = newConfig()
+	sc.takePlacement()
+	if cc := sc.ContainerConfigs["rtos-new"]; sc.SharedCPUPool || cc.CPUSet() != "" || cc.VCPUNum != 1 {
+		t.Fatalf("placement taken within the boot: %+v", cc)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	now := time.Now()
+	exit := &ExitReason{Reason: ExitForced, Code: 137, At: now.Add(-time.Hour)}
+	rec := func(state StateString, boot string) *ContainerStorage {
+		return &ContainerStorage{BootID: boot, State: ContainerState{State: state, RestartCount: 3, LastExit: exit}}
+	}
+
+	tests := []struct {
+		name   string
+		rec    *ContainerStorage
+		count  int
+		reason string
+	}{
+		{"stopped by the shim", rec(StateStopped, "b1"), 4, ExitForced},
+		{"running at the reboot", rec(StateRunning, "b0"), 4, ExitNodeReboot},
+		{"running when the shim went away", rec(StateRunning, "b1"), 4, ExitLost},
+		{"never started", rec(StateReady, "b1"), 3, ExitForced},
+	}
+	for _, tt := range tests {
+		count, last := history(tt.rec, "b1", now)
+		if count != tt.count || last == nil || last.Reason != tt.reason {
+			t.Errorf("%s: got %d, %v, want %d, %s", tt.name, count, last, tt.count, tt.reason)
+		}
+	}
+}
+
+func TestUnpersist(t *testing.T) {
+	defer func(st *store.Store) { persistentStore = st }(persistentStore)
+	persistentStore = store.New(t.TempDir())
+
+	save := func(id string) {
+		rec := SandboxStorage{Schema: sandboxSchemaVersion, ID: id}
+		if err := persistentStore.SaveSandbox("default_pod", &rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kept := func() bool {
+		var rec SandboxStorage
+		return persistentStore.PeekSandbox("default_pod", &rec) == nil
+	}
+	s := &Sandbox{id: "pod-old", config: &SandboxConfig{ID: "pod-old", Workload: "default_pod", PersistRecords: true}}
+
+	// the pod was re-created before the old one was deleted
+	save("pod-new")
+	s.unpersist()
+	if !kept() {
+		t.Fatal("records of the re-created pod removed")
+	}
+
+	save("pod-old")
+	s.unpersist()
+	if kept() {
+		t.Fatal("records of the deleted pod kept")
+	}
+}
@@ -0,0 +1,106 @@
+package micantainer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	er "micrun/errors"
+	"micrun/pkg/console"
+	"micrun/pkg/libmica"
+	"micrun/pkg/osprofile"
+	ped "micrun/pkg/pedestal"
+)
+
+// readyPollInterval is how often micad or xen is asked whether the client booted.
+const readyPollInterval = 200 * time.Millisecond
+
+// readyTail bounds the console output kept to match the ready pattern, which may span reads.
+const readyTail = 4096
+
+// watchReady starts waiting for the client to boot, it must be called before
+// the boot so that no console output is missed. It returns nil when the client
+// counts as ready once started.
+func (c *Container) watchReady(r osprofile.Readiness) <-chan error {
+	if r.Source == osprofile.ReadyNone {
+		return nil
+	}
+	ch := make(chan error, 1)
+	switch r.Source {
+	case osprofile.ReadyConsole:
+		if c.hub == nil {
+			ch <- fmt.Errorf("console of container %s is not started, its readiness can not be detected", c.id)
+			return ch
+		}
+		sub := c.hub.Subscribe("ready", console.SubscriberBuffer)
+		go func() {
+			defer sub.Close()
+			ch <- c.waitConsoleReady(r, sub)
+		}()
+	case osprofile.ReadyRPMsg:
+		go func() {
+			ch <- c.pollReady(r, "no rpmsg endpoint announced", func() bool {
+				status, err := libmica.Status(c.clientID(), libmica.Filter{})
+				return err == nil && len(status.Services) > 0
+			})
+		}()
+	case osprofile.ReadyXenstore:
+		if HostPedType != ped.Xen {
+			ch <- fmt.Errorf("%w: xenstore readiness of %s on %v", er.NotSupported, c.id, HostPedType)
+			return ch
+		}
+		go func() {
+			ch <- c.pollReady(r, "domain not running", func() bool {
+				state, err := ped.XenStoreReadDomainState(c.clientID())
+				return err == nil && state == "running"
+			})
+		}()
+	}
+	return ch
+}
+
+func (c *Container) waitConsoleReady(r osprofile.Readiness, sub *console.Subscriber) error {
+	timeout := time.NewTimer(r.Timeout)
+	defer timeout.Stop()
+	var tail string
+	for {
+		select {
+		case p, ok := <-sub.C:
+			if !ok {
+				return fmt.Errorf("%w: console of %s ended before %q was printed", er.ClientNotReady, c.id, r.Pattern)
+			}
+			tail += string(p)
+			if r.Pattern.MatchString(tail) {
+				return nil
+			}
+			if len(tail) > readyTail {
+				tail = tail[len(tail)-readyTail:]
+			}
+		case <-timeout.C:
+			last := "no console output"
+			if lines := strings.Split(strings.TrimSpace(tail), "\n"); lines[len(lines)-1] != "" {
+				last = fmt.Sprintf("last console output %q", strings.TrimSpace(lines[len(lines)-1]))
+			}
+			return fmt.Errorf("%w: %s did not print %q within %v, %s", er.ClientNotReady, c.id, r.Pattern, r.Timeout, last)
+		}
+	}
+}
+
+func (c *Container) pollReady(r osprofile.Readiness, what string, ready func() bool) error {
+	deadline := time.Now().Add(r.Timeout)
+	for !ready() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %s within %v of the start of %s", er.ClientNotReady, what, r.Timeout, c.id)
+		}
+		time.Sleep(readyPollInterval)
+	}
+	return nil
+}
+
+// readiness returns the pending readiness of a boot which was not waited for
+// by the start, nil when there is none, and whether a failure fails the start.
+func (c *Container) readiness() (<-chan error, bool) {
+	ready := c.ready
+	c.ready = nil
+	return ready, c.readyFail
+}
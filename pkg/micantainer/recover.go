@@ -0,0 +1,66 @@
+package micantainer
+
+import (
+	"context"
+
+	log "micrun/logger"
+	"micrun/pkg/netns"
+	"micrun/pkg/store"
+)
+
+// LoadSandbox restores sandbox id and its containers from the store for a shim
+// restarted while the clients kept running. The records are reconciled with
+// micad: a client which went down meanwhile is marked stopped, the console of
+// a client still running is reconnected.
+func LoadSandbox(ctx context.Context, id string) (SandboxTraits, error) {
+	unlock, err := store.Default().Lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s, err := loadSandbox(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.recover(ctx)
+	return s, nil
+}
+
+func (s *Sandbox) recover(ctx context.Context) {
+	if n, ok := s.network.(*NetworkConfig); ok && n.HolderPid > 0 {
+		// the holder outlives the shim, it is only known to the shim which created it
+		if path, err := netns.RegisterExisting(s.id, n.HolderPid); err != nil {
+			log.Warnf("network namespace of sandbox %s is gone: %v", s.id, err)
+		} else {
+			n.NetworkID = path
+		}
+	}
+
+	for _, c := range s.containers {
+		c.recover(ctx)
+	}
+
+	if err := s.StoreSandbox(ctx); err != nil {
+		log.Warnf("failed to store recovered sandbox %s: %v", s.id, err)
+	}
+}
+
+// recover reconciles the record of the container with its client in micad.
+func (c *Container) recover(ctx context.Context) {
+	if c.config == nil || c.config.IsInfra {
+		return
+	}
+	if c.state.State != StateRunning && c.state.State != StatePaused {
+		return
+	}
+	if exited, failed := ClientExited(c.clientID()); exited {
+		log.Infof("container %s went down while its shim was gone, crashed: %v", c.id, failed)
+		if err := c.setContainerState(ctx, StateStopped); err != nil {
+			log.Warnf("failed to mark container %s as stopped: %v", c.id, err)
+		}
+		return
+	}
+	log.Infof("container %s is still %s, reconnecting its console", c.id, c.state.State)
+	c.reconnectConsole()
+}
@@ -0,0 +1,130 @@
+package micantainer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	defs "micrun/definitions"
+	log "micrun/logger"
+	"micrun/pkg/libmica"
+)
+
+// RestartMode tells when a client which went down by itself is restarted in place.
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"
+	RestartOnFailure RestartMode = "on-failure"
+	RestartAlways    RestartMode = "always"
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartBackoffMax = 300 * time.Second
+)
+
+// RestartPolicy restarts crashed RTOS clients inside micrun, a watchdog reset
+// does not need to tear the sandbox down as kubelet's CrashLoopBackOff does.
+type RestartPolicy struct {
+	Mode RestartMode
+	// MaxRetries bounds the restarts, 0 means no limit.
+	MaxRetries int
+	// Backoff is the delay before the first restart, doubled up to BackoffMax.
+	Backoff    time.Duration
+	BackoffMax time.Duration
+}
+
+// ParseRestartPolicy reads the restart policy annotations.
+func ParseRestartPolicy(annotations map[string]string) (RestartPolicy, error) {
+	p := RestartPolicy{Mode: RestartNever, Backoff: defaultRestartBackoff, BackoffMax: defaultRestartBackoffMax}
+	if v := annotations[defs.ContainerRestartPolicy]; v != "" {
+		switch mode := RestartMode(v); mode {
+		case RestartNever, RestartOnFailure, RestartAlways:
+			p.Mode = mode
+		default:
+			return p, fmt.Errorf("invalid %s %q: want never, on-failure or always", defs.ContainerRestartPolicy, v)
+		}
+	}
+
+	seconds := func(key string, d *time.Duration) error {
+		v, ok := annotations[key]
+		if !ok || v == "" {
+			return nil
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: want a number of seconds", key, v)
+		}
+		*d = time.Duration(n) * time.Second
+		return nil
+	}
+	if err := seconds(defs.ContainerRestartBackoff, &p.Backoff); err != nil {
+		return p, err
+	}
+	if err := seconds(defs.ContainerRestartBackoffMax, &p.BackoffMax); err != nil {
+		return p, err
+	}
+	if p.BackoffMax < p.Backoff {
+		p.BackoffMax = p.Backoff
+	}
+	if v := annotations[defs.ContainerRestartMaxRetries]; v != "" {
+		n, err := strconv.ParseUint(v, 10, 31)
+		if err != nil {
+			return p, fmt.Errorf("invalid %s %q: want a number of restarts", defs.ContainerRestartMaxRetries, v)
+		}
+		p.MaxRetries = int(n)
+	}
+	return p, nil
+}
+
+// Next tells whether a client which went down after restarts restarts is
+// restarted, and the delay before restarting it.
+func (p RestartPolicy) Next(failed bool, restarts int) (time.Duration, bool) {
+	switch {
+	case p.Mode == RestartNever,
+		p.Mode == RestartOnFailure && !failed,
+		p.MaxRetries > 0 && restarts >= p.MaxRetries:
+		return 0, false
+	}
+	delay := p.Backoff
+	for i := 0; i < restarts && delay < p.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > p.BackoffMax {
+		delay = p.BackoffMax
+	}
+	return delay, true
+}
+
+func (c *Container) restartPolicy() RestartPolicy {
+	if c.config == nil || c.config.IsInfra {
+		return RestartPolicy{Mode: RestartNever}
+	}
+	p, err := ParseRestartPolicy(c.config.Annotations)
+	if err != nil {
+		log.Warnf("container %s is not restarted: %v", c.id, err)
+		return RestartPolicy{Mode: RestartNever}
+	}
+	return p
+}
+
+// restart boots a client which went down by itself again in place, the client
+// keeps its registration, pinning and memory, and the console hub is kept so
+// that stdio and attach sessions continue over the new boot.
+func (c *Container) restart(ctx context.Context) error {
+	if c.state.State != StateRunning {
+		return fmt.Errorf("container %s is %s, only a running container is restarted", c.id, c.state.State)
+	}
+	// a crashed client is stopped first, micad only starts stopped clients
+	if err := libmica.Stop(c.clientID()); err != nil {
+		return err
+	}
+	if err := startClient(ctx, c.sandbox, c, true); err != nil {
+		return err
+	}
+	c.state.RestartCount++
+	log.Infof("container %s restarted in place, %d restarts", c.id, c.state.RestartCount)
+	return c.setContainerState(ctx, StateRunning)
+}
@@ -0,0 +1,54 @@
+package micantainer
+
+import (
+	"testing"
+	"time"
+
+	defs "micrun/definitions"
+)
+
+func TestRestartPolicy(t *testing.T) {
+	p, err := ParseRestartPolicy(nil)
+	if err != nil || p.Mode != RestartNever {
+		t.Fatalf("default policy = %+v, %v", p, err)
+	}
+	if _, ok := p.Next(true, 0); ok {
+		t.Fatal("never should not restart")
+	}
+
+	p, err = ParseRestartPolicy(map[string]string{
+		defs.ContainerRestartPolicy:     "on-failure",
+		defs.ContainerRestartMaxRetries: "3",
+		defs.ContainerRestartBackoff:    "2",
+		defs.ContainerRestartBackoffMax: "5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Next(false, 0); ok {
+		t.Fatal("on-failure should not restart a client which powered off")
+	}
+	for restarts, want := range []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if delay, ok := p.Next(true, restarts); !ok || delay != want {
+			t.Fatalf("restart %d: delay %v, %v, want %v", restarts, delay, ok, want)
+		}
+	}
+	if _, ok := p.Next(true, 3); ok {
+		t.Fatal("max retries exceeded")
+	}
+
+	p, _ = ParseRestartPolicy(map[string]string{defs.ContainerRestartPolicy: "always"})
+	if delay, ok := p.Next(false, 100); !ok || delay != defaultRestartBackoffMax {
+		t.Fatalf("always: delay %v, %v", delay, ok)
+	}
+
+	for _, bad := range []map[string]string{
+		{defs.ContainerRestartPolicy: "sometimes"},
+		{defs.ContainerRestartMaxRetries: "-1"},
+		{defs.ContainerRestartBackoff: "1s"},
+	} {
+		if _, err := ParseRestartPolicy(bad); err == nil {
+			t.Fatalf("ParseRestartPolicy(%v) should fail", bad)
+		}
+	}
+}
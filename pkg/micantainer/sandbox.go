@@ -2,7 +2,6 @@ package micantainer
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	defs "micrun/definitions"
@@ -10,11 +9,12 @@ import (
 	log "micrun/logger"
 	"micrun/pkg/cpuset"
 	"micrun/pkg/libmica"
-	"micrun/pkg/utils"
-	"os"
-	"path/filepath"
+	"micrun/pkg/osprofile"
+	"micrun/pkg/store"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -27,10 +27,13 @@ const (
 
 // Define the structure that matches what we store
 type SandboxStorage struct {
+	Schema  int           `json:"schema"`
 	ID      string        `json:"id"`
 	State   SandboxState  `json:"state"`
 	Config  SandboxConfig `json:"config"`
 	Network NetworkConfig `json:"network"`
+	// BootID is the boot of the node the record was saved in.
+	BootID string `json:"boot_id,omitempty"`
 	// Containers map[string]*Container `json:"containers"`
 }
 
@@ -217,6 +220,7 @@ func (s *Sandbox) Delete(ctx context.Context) error {
 	if err := s.removeNetwork(); err != nil {
 		log.Warnf("failed to remove network for sandbox %s: %v", s.id, err)
 	}
+	s.unpersist()
 
 	return s.cleanSandboxStorage()
 
@@ -232,6 +236,7 @@ func (s *Sandbox) CreateContainer(ctx context.Context, config ContainerConfig) (
 		log.Errorf("container %s already exists", id)
 		return nil, er.AlreadyExists
 	}
+	s.config.takeContainerPlacement(&config)
 	s.config.ContainerConfigs[id] = &config
 	if s.config.InfraOnly && !config.IsInfra {
 		s.config.InfraOnly = false
@@ -398,7 +403,7 @@ func (s *Sandbox) KillContainer(ctx context.Context, id string) (ContainerTraits
 		return nil, er.ContainerNotFound
 	}
 
-	if libmica.ClientNotExist(c.id) {
+	if libmica.ClientNotExist(c.clientID()) {
 		return c, nil
 	}
 
@@ -433,6 +438,7 @@ func (s *Sandbox) StatusContainer(id string) (ContainerStatus, error) {
 		cs.Spec = nil
 		cs.State = c.state
 		cs.ID = c.id
+		cs.Client = c.clientID()
 		cs.Rootfs = rootfs
 		cs.Pid = c.GetPid()
 		cs.Annotations = c.config.Annotations
@@ -515,6 +521,98 @@ func (s *Sandbox) WinResize(ctx context.Context, containerID string, height, wid
 	return c.winresize(height, width)
 }
 
+// ConsoleControls returns the control character and signal translation table of a container.
+func (s *Sandbox) ConsoleControls(containerID string) (*osprofile.Controls, error) {
+	c, ok := s.containers[containerID]
+	if c == nil || !ok {
+		return nil, er.ContainerNotFound
+	}
+	return c.consoleControls()
+}
+
+// SignalContainer applies the non stopping action of a signal, as given by ConsoleControls.
+func (s *Sandbox) SignalContainer(ctx context.Context, containerID string, signal syscall.Signal) error {
+	c, ok := s.containers[containerID]
+	if c == nil || !ok {
+		return er.ContainerNotFound
+	}
+	return c.Signal(ctx, signal)
+}
+
+// ContainerReadiness returns the readiness of a container, the start does not
+// wait for the boot so that the caller can wait without holding its locks. It
+// is nil when it was already taken or there is no readiness detection, the
+// flag tells whether the start fails on it, see ContainerReadyTimeoutAction.
+func (s *Sandbox) ContainerReadiness(containerID string) (<-chan error, bool) {
+	c, ok := s.containers[containerID]
+	if c == nil || !ok {
+		return nil, false
+	}
+	return c.readiness()
+}
+
+// ContainerRestartCount returns the restart count kept in the record of a
+// container, which carries the count of the workload over a node reboot.
+func (s *Sandbox) ContainerRestartCount(containerID string) int {
+	c, ok := s.containers[containerID]
+	if c == nil || !ok {
+		return 0
+	}
+	return c.state.RestartCount
+}
+
+// ContainerExited delivers the exit status printed on the console of a container,
+// see ContainerExitSuccess. It is nil when the container has no exit patterns.
+func (s *Sandbox) ContainerExited(containerID string) <-chan int {
+	c, ok := s.containers[containerID]
+	if c == nil || !ok {
+		return nil
+	}
+	return c.exited
+}
+
+// RestartContainer applies the restart policy of a container whose client went down by itself.
+func (s *Sandbox) RestartContainer(ctx context.Context, containerID string) error {
+	if s.state.State != StateRunning {
+		return er.SandboxDown
+	}
+
+	c, ok := s.containers[containerID]
+	if c == nil || !ok {
+		return er.ContainerNotFound
+	}
+	if err := c.restart(ctx); err != nil {
+		return err
+	}
+	return s.checkVCPUsPinning(ctx)
+}
+
+// RecordContainerExit records why the client of container id went down, see ExitReason.
+func (s *Sandbox) RecordContainerExit(id, reason string, code int) error {
+	c, ok := s.containers[id]
+	if c == nil || !ok {
+		return er.ContainerNotFound
+	}
+	c.state.LastExit = &ExitReason{Reason: reason, Code: code, At: time.Now()}
+	return c.SaveState()
+}
+
+// ExecContainer prepares args to run in the shell of the client OS. The sandbox
+// and the container are only looked at here, the returned function runs the
+// command on the console captured at this point.
+func (s *Sandbox) ExecContainer(ctx context.Context, containerID string, args []string) (ExecFunc, error) {
+	if s.state.State != StateRunning {
+		return nil, er.SandboxDown
+	}
+
+	c, ok := s.containers[containerID]
+	if c == nil || !ok {
+		return nil, er.ContainerNotFound
+	}
+
+	return c.exec(args)
+}
+
 func (s *Sandbox) PauseContainer(ctx context.Context, id string) error {
 
 	c, ok := s.containers[id]
@@ -589,16 +687,13 @@ func (s *Sandbox) setSandboxState(state StateString) error {
 
 // store sandbox information to disk
 func (s *Sandbox) StoreSandbox(ctx context.Context) error {
-	target, err := s.newSandboxStoragePath()
-	if err != nil {
-		return err
-	}
-
 	// Create serializable representation of sandbox
 	serializable := SandboxStorage{
+		Schema: sandboxSchemaVersion,
 		ID:     s.id,
 		State:  s.state,
 		Config: *s.config,
+		BootID: bootID(),
 	}
 
 	// NOTICE: remove unnecessary runtime reflection, make codes clean and faster
@@ -616,35 +711,16 @@ func (s *Sandbox) StoreSandbox(ctx context.Context) error {
 		}
 	}
 
-	if err := utils.SaveStructToJSON(target, serializable); err != nil {
+	if err := store.Default().SaveSandbox(s.id, &serializable); err != nil {
 		return err
 	}
+	s.persist(&serializable)
 	return nil
 }
 
-func (s *Sandbox) sandboxStoragePath() string {
-	return filepath.Join(defs.SandboxDataDir, s.id)
-}
-
-func (s *Sandbox) newSandboxStoragePath() (string, error) {
-	dir := s.sandboxStoragePath()
-	if err := os.MkdirAll(dir, defs.DirMode); err != nil {
-		return "", err
-	}
-	stateFile := filepath.Join(dir, defs.SandboxStateFile)
-	return stateFile, nil
-}
-
+// cleanSandboxStorage removes the records of the sandbox and of its containers.
 func (s *Sandbox) cleanSandboxStorage() error {
-	if s.id == "" {
-		return er.EmptySandboxID
-	}
-	dir := s.sandboxStoragePath()
-	if err := os.RemoveAll(dir); err != nil {
-		return err
-	}
-	return nil
-
+	return store.Default().DeleteSandbox(s.id)
 }
 
 func (s *Sandbox) addContainer(c *Container) error {
@@ -683,6 +759,7 @@ func (s *Sandbox) stopClients(ctx context.Context) error {
 
 // setup sandbox
 func CreateSandbox(ctx context.Context, cfg *SandboxConfig) (*Sandbox, error) {
+	cfg.takePlacement()
 	s, err := createSandboxFromConfig(ctx, cfg)
 	if err != nil {
 		return nil, err
@@ -718,6 +795,9 @@ func newSandbox(ctx context.Context, config SandboxConfig) (sb *Sandbox, retErr
 	}
 
 	if err := s.restore(); err != nil {
+		if errors.Is(err, store.ErrNewerSchema) {
+			return nil, err
+		}
 		log.Debugf("failed to restore sandbox %s: %v", s.id, err)
 	}
 	return s, nil
@@ -787,6 +867,9 @@ func (s *Sandbox) restore() error {
 
 	if err != nil {
 		log.Warnf("failed to restore sandbox state: %v", err)
+		if errors.Is(err, store.ErrNewerSchema) {
+			return err
+		}
 		return nil
 	}
 
@@ -814,32 +897,15 @@ func (s *Sandbox) restore() error {
 
 // restoreSandbox loads an existing sandbox from storage, by sandbox id
 func restoreSandbox(ctx context.Context, id string) (*SandboxStorage, error) {
-	// Load sandbox configuration from storage
-	sandboxDir := filepath.Join(defs.SandboxDataDir, id)
-	configPath := filepath.Join(sandboxDir, defs.SandboxStateFile)
-
-	raw, err := utils.RestoreStructFromJSON(configPath)
+	storage, err := loadSandboxRecord(store.Default(), id)
 	if err != nil {
-		if os.IsNotExist(err) {
-			log.Debugf("not found sandbox state file: %s, sandbox may have been already cleaned up", configPath)
-			return nil, er.SandboxNotFound
+		if err == er.SandboxNotFound {
+			log.Debugf("not found record of sandbox %s, sandbox may have been already cleaned up", id)
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to load sandbox state from %s: %w", configPath, err)
-	}
-
-	// Convert to JSON and then unmarshal into our struct
-	jsonBytes, err := json.Marshal(raw)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal raw data: %w", err)
+		return nil, fmt.Errorf("failed to load sandbox state of %s: %w", id, err)
 	}
-
-	var storage SandboxStorage
-	if err := json.Unmarshal(jsonBytes, &storage); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal sandbox storage: %w", err)
-	}
-
-	// Return just the state part as requested by the function signature
-	return &storage, nil
+	return storage, nil
 }
 
 // sandbox is not ready for being operated
@@ -904,13 +970,14 @@ func (s *Sandbox) initContainers(ctx context.Context) error {
 // or each container uses its own cpuset.
 // Without pinning we skip affinity
 // updates entirely and let the pedestal schedule VCPUs freely.
-// NOTICE: we do not "bind a vcpu" to a pcpu, instead we just set "vcpu set" to a pcpu set if pinning
+// NOTICE: we do not "bind a vcpu" to a pcpu, instead we just set "vcpu set" to a pcpu set if pinning,
+// unless the container enables vcpu_pcpu_binding, see Container.setVcpuAffinity.
 func (s *Sandbox) checkVCPUsPinning(ctx context.Context) error {
 	if s.config == nil {
 		return fmt.Errorf("no sandbox config found")
 	}
 
-	if !s.config.EnableVCPUsPinning {
+	if !s.config.EnableVCPUsPinning && !s.vcpuBindingRequested() {
 		return nil
 	}
 
@@ -1065,20 +1132,43 @@ func (s *Sandbox) loadContainersToSandbox(ctx context.Context) error {
 
 }
 
+// vcpuBindingRequested reports whether any container asks for 1:1 vcpu binding,
+// which implies pinning even if the sandbox does not enable it.
+func (s *Sandbox) vcpuBindingRequested() bool {
+	for _, cc := range s.config.ContainerConfigs {
+		if cc != nil && cc.VCPUBinding {
+			return true
+		}
+	}
+	return false
+}
+
 // update cpu affinity for sandbox vcpu
 // repin vcpus in vcpuList to the cpupool
 func (s *Sandbox) pinVCPU(cpuSet cpuset.CPUSet) error {
 	var result *multierror.Error
 
 	if s.config.SharedCPUPool {
-		// Shared CPU pool mode: pin all containers to the same union CPU set
+		// Shared CPU pool mode: pin all containers to the same union CPU set,
+		// but a container binding its vcpus 1:1 keeps its own cpuset.
 		pcpuList := cpuSet.ToSlice()
 		for cid, c := range s.containers {
-			log.Infof("try to pin container %s vcpu affinity to shared cpuset %v", cid, pcpuList)
-			if err := c.setVcpuAffinity(cpuSet); err != nil {
+			set := cpuSet
+			if c.config != nil && c.config.VCPUBinding {
+				own, err := cpuset.Parse(c.config.CPUSet())
+				if err != nil {
+					result = multierror.Append(result, fmt.Errorf("failed to parse cpuset for container %s: %v", cid, err))
+					continue
+				}
+				set = own
+				log.Infof("try to bind container %s vcpus to its own cpuset %v", cid, set.ToSlice())
+			} else {
+				log.Infof("try to pin container %s vcpu affinity to shared cpuset %v", cid, pcpuList)
+			}
+			if err := c.setVcpuAffinity(set); err != nil {
 				result = multierror.Append(result, err)
 			} else {
-				s.resManager.ContainerCpuSets[cid] = cpuSet
+				s.resManager.ContainerCpuSets[cid] = set
 			}
 		}
 
@@ -20,6 +20,14 @@ type SandboxConfig struct {
 	StaticResourceMgmt bool
 	HugePageSupport    bool
 	InfraOnly          bool
+	// IRQAffinitySteering keeps host irqs away from the cpuset of started clients.
+	IRQAffinitySteering bool
+	// ConsoleLogSize is the on-disk retention of each container console log in bytes.
+	ConsoleLogSize int64
+	// PersistRecords keeps a copy of the records below PersistentDataDir, see persist.go.
+	PersistRecords bool
+	// Workload names the sandbox the same across re-creations, e.g. <namespace>_<pod>.
+	Workload string
 }
 
 func (sc *SandboxConfig) valid() bool {
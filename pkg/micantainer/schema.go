@@ -0,0 +1,85 @@
+package micantainer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"micrun/pkg/store"
+)
+
+// Schema versions of the records, bump them with a migration whenever a
+// persisted field is renamed, moved or changes meaning.
+//
+//	1: records of micrun before the store, found at their legacy paths by an
+//	   upgrade, see store.NewUpgrading; they have no boot id
+//	2: records of the store
+const (
+	sandboxSchemaVersion   = 2
+	containerSchemaVersion = 2
+)
+
+var sandboxSchema = &store.Schema{
+	Kind:    "sandbox",
+	Version: sandboxSchemaVersion,
+	Migrations: map[int]store.Migration{
+		1: stampBootID,
+	},
+}
+
+var containerSchema = &store.Schema{
+	Kind:    "container",
+	Version: containerSchemaVersion,
+	Migrations: map[int]store.Migration{
+		1: stampBootID,
+	},
+}
+
+// stampBootID gives a record of micrun before the store the running boot,
+// the only boot its records below /run can be of.
+func stampBootID(rec map[string]any) error {
+	if _, ok := rec["boot_id"]; !ok {
+		if boot := bootID(); boot != "" {
+			rec["boot_id"] = boot
+		}
+	}
+	return nil
+}
+
+// loadSandboxRecord loads the record of sandbox id from st, upgraded to the current schema.
+func loadSandboxRecord(st *store.Store, id string) (*SandboxStorage, error) {
+	var raw json.RawMessage
+	if err := st.LoadSandbox(id, &raw); err != nil {
+		return nil, err
+	}
+	var rec SandboxStorage
+	if err := decodeRecord(sandboxSchema, raw, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// loadContainerRecord loads the record of container id of sandbox sandboxID
+// from st, upgraded to the current schema.
+func loadContainerRecord(st *store.Store, sandboxID, id string) (*ContainerStorage, error) {
+	var raw json.RawMessage
+	if err := st.LoadContainer(sandboxID, id, &raw); err != nil {
+		return nil, err
+	}
+	var rec ContainerStorage
+	if err := decodeRecord(containerSchema, raw, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// decodeRecord upgrades raw to the current version of sc and decodes it into rec.
+func decodeRecord(sc *store.Schema, raw []byte, rec any) error {
+	raw, err := sc.Upgrade(raw)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, rec); err != nil {
+		return fmt.Errorf("corrupted %s record: %w", sc.Kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package micantainer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"micrun/pkg/store"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestContainerRecordSchema(t *testing.T) {
+	for _, name := range []string{"container-v1.json", "container-v2.json"} {
+		var rec ContainerStorage
+		if err := decodeRecord(containerSchema, readFixture(t, name), &rec); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rec.Schema != containerSchemaVersion {
+			t.Errorf("%s: schema %d, want %d", name, rec.Schema, containerSchemaVersion)
+		}
+		if rec.ID != "rtos" || rec.SandboxID != "pod" || rec.State.State != StateRunning {
+			t.Errorf("%s: got %+v", name, rec)
+		}
+		if rec.Config.PCPUNum != 2 || rec.Config.VCPUNum != 1 || rec.Config.OS != "zephyr" {
+			t.Errorf("%s: config not restored: %+v", name, rec.Config)
+		}
+	}
+
+	var rec ContainerStorage
+	err := decodeRecord(containerSchema, readFixture(t, "container-v3.json"), &rec)
+	if !errors.Is(err, store.ErrNewerSchema) {
+		t.Fatalf("newer record: got %v, want ErrNewerSchema", err)
+	}
+}
+
+func TestSandboxRecordSchema(t *testing.T) {
+	for _, name := range []string{"sandbox-v1.json", "sandbox-v2.json"} {
+		var rec SandboxStorage
+		if err := decodeRecord(sandboxSchema, readFixture(t, name), &rec); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rec.Schema != sandboxSchemaVersion || rec.ID != "pod" || rec.State.State != StateRunning {
+			t.Errorf("%s: got %+v", name, rec)
+		}
+		cc, ok := rec.Config.ContainerConfigs["rtos"]
+		if !ok || cc.PCPUNum != 2 {
+			t.Errorf("%s: container config not restored: %+v", name, cc)
+		}
+	}
+
+	var rec SandboxStorage
+	err := decodeRecord(sandboxSchema, []byte(`{"schema": 99, "id": "pod"}`), &rec)
+	if !errors.Is(err, store.ErrNewerSchema) {
+		t.Fatalf("newer record: got %v, want ErrNewerSchema", err)
+	}
+	if err := decodeRecord(sandboxSchema, []byte(`{"schema": "2"}`), &rec); err == nil {
+		t.Fatal("invalid schema version accepted")
+	}
+}
+
+func TestLegacyRecords(t *testing.T) {
+	// records of micrun before the store at their paths, e.g. on an upgrade
+	// with a pod running
+	legacyRoot := t.TempDir()
+	root := filepath.Join(legacyRoot, "sandbox")
+	for path, fixture := range map[string]string{
+		filepath.Join(root, "pod", "state.json"):               "sandbox-v1.json",
+		filepath.Join(legacyRoot, "pod", "rtos", "state.json"): "container-v1.json",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, readFixture(t, fixture), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := store.NewUpgrading(root, legacyRoot)
+
+	sb, err := loadSandboxRecord(st, "pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.Schema != sandboxSchemaVersion || sb.BootID != bootID() || sb.Config.ContainerConfigs["rtos"].PCPUNum != 2 {
+		t.Fatalf("sandbox record not upgraded: %+v", sb)
+	}
+	c, err := loadContainerRecord(st, "pod", "rtos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Schema != containerSchemaVersion || c.BootID != bootID() || c.Config.PCPUNum != 2 || c.State.State != StateRunning {
+		t.Fatalf("container record not upgraded: %+v", c)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pod", "containers", "rtos.json")); err != nil {
+		t.Fatalf("container record not moved: %v", err)
+	}
+}
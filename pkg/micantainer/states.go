@@ -22,6 +22,36 @@ const (
 // ContainerState represents the state of a container.
 type ContainerState struct {
 	State StateString
+	// RestartCount counts the restarts in place done by the restart policy, and
+	// the re-creations of the container found in the persistent records.
+	RestartCount int `json:"restart_count,omitempty"`
+	// LastExit tells why the client went down last.
+	LastExit *ExitReason `json:"last_exit,omitempty"`
+}
+
+// Reasons of ExitReason, the shim reports how it stopped the client.
+const (
+	ExitGraceful = "graceful"
+	ExitForced   = "forced"
+	ExitExited   = "exited"
+	// the client was running when the node rebooted
+	ExitNodeReboot = "node-reboot"
+	// the client was running when its shim went away
+	ExitLost = "lost"
+)
+
+// ExitReason records the end of a run of the client.
+type ExitReason struct {
+	Reason string    `json:"reason"`
+	Code   int       `json:"code"`
+	At     time.Time `json:"at"`
+}
+
+func (e *ExitReason) String() string {
+	if e == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%s (%d) at %s", e.Reason, e.Code, e.At.Format(time.RFC3339))
 }
 
 // ContainerStatus represents the status of a container.
@@ -30,6 +60,7 @@ type ContainerStatus struct {
 	CreatedAt   time.Time
 	State       ContainerState
 	ID          string
+	Client      string // name of the client at micad
 	Rootfs      string
 	Pid         int // The shim pid.
 	Annotations map[string]string
@@ -22,6 +22,10 @@ type MemoryStats struct {
 	Cache uint64            `json:"cache"`
 	Usage MemoryEntry       `json:"usage"`
 	Stats map[string]uint64 `json:"stats"`
+	// Static reports the client memory is allocated once from hugepage backed regions.
+	Static bool `json:"static,omitempty"`
+	// HugePageSize is the page size backing static memory, e.g. "2MB".
+	HugePageSize string `json:"hugepage_size,omitempty"`
 }
 
 // MemoryEntry holds detailed memory usage data.
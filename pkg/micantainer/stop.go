@@ -0,0 +1,111 @@
+package micantainer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	er "micrun/errors"
+	log "micrun/logger"
+	"micrun/pkg/libmica"
+	"micrun/pkg/osprofile"
+	ped "micrun/pkg/pedestal"
+)
+
+// clientDownPollInterval is how often the client is checked while it powers off.
+const clientDownPollInterval = 200 * time.Millisecond
+
+// shutdown asks the client os to power off without waiting for it: command is
+// typed into the client shell when the OS profile has one, a xen guest gets a
+// shutdown request otherwise. Other clients can not be asked, er.NotSupported.
+func (c *Container) shutdown(command string) error {
+	if command != "" && c.hub != nil {
+		log.Debugf("shutdown container %s with %q", c.id, command)
+		return c.hub.Inject([]byte(command + "\n"))
+	}
+	log.Debugf("shutdown container %s from the host", c.id)
+	return libmica.Shutdown(c.clientID())
+}
+
+// shutdownGracefully is the first phase of a stop: the client is asked to power
+// off and given the grace period of ContainerStopTimeout to do so.
+// It reports whether the client went down by itself, the caller destroys it anyway.
+func (c *Container) shutdownGracefully(ctx context.Context) bool {
+	grace, err := osprofile.StopTimeout(c.config.Annotations)
+	if err != nil {
+		log.Warnf("container %s: %v, use %v", c.id, err, osprofile.DefaultStopTimeout)
+		grace = osprofile.DefaultStopTimeout
+	}
+	command := osprofile.Lookup(c.os()).ShutdownCommand
+	if err := c.shutdown(command); errors.Is(err, er.NotSupported) {
+		log.Debugf("container %s can not power off by itself, destroy it: %v", c.id, err)
+		return false
+	} else if err != nil {
+		log.Warnf("failed to shutdown container %s, destroy it: %v", c.id, err)
+		return false
+	}
+	if !WaitClientDown(ctx, c.clientID(), grace) {
+		log.Infof("container %s still running after %v, destroy it", c.id, grace)
+		return false
+	}
+	log.Infof("container %s powered off", c.id)
+	return true
+}
+
+// WaitClientDown polls micad until the client is stopped or gone, it returns
+// false when the client is still running after timeout.
+func WaitClientDown(ctx context.Context, id string, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(clientDownPollInterval)
+	defer ticker.Stop()
+	for {
+		if clientDown(id) {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return clientDown(id)
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// ClientExited tells whether the client went down, and whether it crashed
+// rather than powered off. A client which disappeared from micad crashed.
+func ClientExited(id string) (exited, failed bool) {
+	if libmica.ClientNotExist(id) {
+		return true, true
+	}
+	status, err := libmica.Status(id, libmica.Filter{})
+	if err != nil {
+		return false, false
+	}
+	if status.IsFailed() {
+		return true, true
+	}
+	return status.IsDown() && !domainAlive(id), false
+}
+
+func clientDown(id string) bool {
+	if libmica.ClientNotExist(id) {
+		return true
+	}
+	status, err := libmica.Status(id, libmica.Filter{})
+	return err == nil && status.IsDown() && !domainAlive(id)
+}
+
+// domainAlive tells whether the xen domain of client id exists and is neither
+// shut down, crashed nor dying. micad reports a domain restored from a
+// checkpoint as stopped, it registered the client but never started it.
+func domainAlive(id string) bool {
+	if HostPedType != ped.Xen {
+		return false
+	}
+	// the state is "running" or the xl list flags, e.g. "-b----" for an idle domain
+	state, err := ped.XenStoreReadDomainState(id)
+	return err == nil && !strings.ContainsAny(state, "scd")
+}
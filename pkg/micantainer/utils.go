@@ -8,6 +8,7 @@ import (
 	log "micrun/logger"
 	"micrun/pkg/cpuset"
 	"micrun/pkg/libmica"
+	"micrun/pkg/osprofile"
 	"micrun/pkg/pedestal"
 	"micrun/pkg/utils"
 	"os/exec"
@@ -15,22 +16,49 @@ import (
 	"time"
 )
 
-func startClient(ctx context.Context, sandbox SandboxTraits, c *Container) error {
+// startClient boots the client os, restart boots a client which was stopped
+// in place: it is still registered with its pinning and memory.
+func startClient(ctx context.Context, sandbox SandboxTraits, c *Container, restart bool) error {
 	if _, err := c.ensureClientPresence(); err != nil {
 		return err
 	}
 
+	fresh := c.startConsole()
+	readiness, err := osprofile.Lookup(c.os()).Readiness(c.config.Annotations)
+	if err != nil {
+		return err
+	}
+	exits, err := osprofile.ParseExitPatterns(c.config.Annotations)
+	if err != nil {
+		return err
+	}
+	// subscribe before the boot, the banner must not be missed
+	ready := c.watchReady(readiness)
+	if fresh {
+		// the watcher of a hub kept over a restart in place goes on
+		c.exited = c.watchExitPatterns(exits)
+	}
+
 	start := time.Now()
-	if err := libmica.Start(c.id); err != nil {
+	boot := libmica.Start
+	if restart {
+		boot = libmica.Restart
+	}
+	if err := boot(c.clientID()); err != nil {
 		log.Errorf("startClient: Start failed: %v", err)
 		return err
 	}
+	c.connectConsole()
 
 	if err := c.setupMemory(); err != nil {
 		return err
 	}
+	c.steerIRQs()
 	log.Infof("startClient: Start OK in %s", time.Since(start))
 
+	// the boot is waited for by the caller, which must not hold its locks meanwhile
+	c.ready = ready
+	c.readyFail = readiness.Fail
 	return nil
 }
 
@@ -52,9 +80,20 @@ func createMicaClientConf(container *Container) (libmica.MicaClientConf, error)
 	}
 	// memoryMB (initial) should prefer the configured limit, falling back to the minimum (reservation) when unset.
 	memMB := int(config.containerMaxMemMB())
+	memThreshold := int(config.MemoryThresholdMB)
+	if config.StaticMemory {
+		// memory == maxmem: allocated once from superpages, never ballooned
+		memMB = int(config.staticMemoryMB())
+		memThreshold = memMB
+		log.Infof("client %s uses %d MiB static memory backed by %s pages", container.id, memMB, config.hugePageSize())
+	}
 	if err := ensureFirmwarePath(config.ImageAbsPath); err != nil {
 		return libmica.MicaClientConf{}, fmt.Errorf("firmware validation failed: %w", err)
 	}
+	iomem := config.Passthrough.IOMemConf()
+	if len(iomem) >= libmica.MaxConfigStrLen {
+		return libmica.MicaClientConf{}, fmt.Errorf("passthrough iomem of %s exceeds %d bytes: %s", container.id, libmica.MaxConfigStrLen, iomem)
+	}
 
 	// Memory limit is already expressed in MiB
 	conf.InitWithOpts(libmica.MicaClientConfCreateOptions{
@@ -64,8 +103,9 @@ func createMicaClientConf(container *Container) (libmica.MicaClientConf, error)
 		VCPUs:           vcpus,
 		MaxVCPUs:        int(config.MaxVcpuNum),
 		MemoryMB:        memMB,
-		MemoryThreshold: int(config.MemoryThresholdMB),
-		Name:            container.id,
+		MemoryThreshold: memThreshold,
+		IOMem:           iomem,
+		Name:            container.clientID(),
 		Path:            config.ImageAbsPath,
 		Ped:             pedType.String(),
 		PedCfg:          config.PedestalConf,
@@ -165,6 +205,13 @@ func calculateSandboxMemory(s *Sandbox) uint64 {
 			continue
 		}
 
+		if cc.StaticMemory {
+			staticMiB := uint64(cc.staticMemoryMB())
+			log.Debugf("sandbox static memory + %d MiB (%s)", staticMiB, cc.hugePageSize())
+			memorySandbox += staticMiB
+			continue
+		}
+
 		if m := cc.Resources.Memory; m != nil {
 			// OCI memory limit is in bytes; convert to MiB for sandbox accounting
 			if m.Limit != nil && *m.Limit > 0 {
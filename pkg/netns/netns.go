@@ -1,10 +1,12 @@
 package netns
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -14,6 +16,10 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// HolderEnv is set in the environment of holder processes to the id they
+// hold the namespace for, so that holders outliving their shim can be found.
+const HolderEnv = "MICRUN_NETNS_HOLDER"
+
 type holder struct {
 	cmd  *exec.Cmd
 	pid  int
@@ -47,6 +53,7 @@ func Create(id string) (int, string, error) {
 	if err != nil {
 		return 0, "", err
 	}
+	cmd.Env = append(os.Environ(), HolderEnv+"="+id)
 
 	if err := cmd.Start(); err != nil {
 		return 0, "", fmt.Errorf("netns: failed to start holder for %s: %w", id, err)
@@ -160,6 +167,50 @@ func PID(id string) (int, bool) {
 	return h.pid, true
 }
 
+// Holders returns the holder processes running on the host by pid, with the
+// id each one holds the namespace for. Holders of every shim are returned.
+func Holders() (map[int]string, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("netns: failed to list processes: %w", err)
+	}
+	found := make(map[int]string)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if id, ok := holderOf(pid); ok {
+			found[pid] = id
+		}
+	}
+	return found, nil
+}
+
+// Terminate stops holder pid of id, unless pid was reused by another process.
+func Terminate(id string, pid int) error {
+	if cur, ok := holderOf(pid); !ok || cur != id {
+		return nil
+	}
+	return terminateByPID(pid)
+}
+
+// holderOf reads the id held by process pid from its environment.
+func holderOf(pid int) (string, bool) {
+	// processes exit while being read, and kernel threads have no environment
+	environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return "", false
+	}
+	marker := []byte(HolderEnv + "=")
+	for _, kv := range bytes.Split(environ, []byte{0}) {
+		if id, ok := bytes.CutPrefix(kv, marker); ok && len(id) > 0 {
+			return string(id), true
+		}
+	}
+	return "", false
+}
+
 // TODO: need a proper holdercmd, current startHolderCmd is a workaround for debug
 // we need:
 // without running pause image, micrun serve as a placeholder for the netns holder process
@@ -1,6 +1,8 @@
 package oci
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
@@ -11,8 +13,13 @@ import (
 	"strings"
 
 	defs "micrun/definitions"
+	er "micrun/errors"
 	log "micrun/logger"
+	"micrun/pkg/cpuset"
+	"micrun/pkg/libmica"
 	cntr "micrun/pkg/micantainer"
+	"micrun/pkg/osprofile"
+	"micrun/pkg/passthrough"
 	"micrun/pkg/pedestal"
 	"micrun/pkg/utils"
 
@@ -302,8 +309,10 @@ func ParseContainerCfg(id, bundle string, ocispec specs.Spec, ct cntr.ContainerT
 		OS:           osName,
 		PCPUNum:      1,
 		Resources:    &specs.LinuxResources{},
+		Annotations:  ocispec.Annotations,
 	}
 	config.IsInfra = isInfra
+	config.Workload = workloadName(getAnnotation, id, ctrAnnotations.ContainerName)
 
 	if err := config.ParseOCIResources(&ocispec); err != nil {
 		return nil, err
@@ -319,6 +328,28 @@ func ParseContainerCfg(id, bundle string, ocispec specs.Spec, ct cntr.ContainerT
 		}
 	}
 
+	if err := applyCPUSet(config, getAnnotation); err != nil {
+		return nil, err
+	}
+	if err := applyVCPUBinding(config, getAnnotation); err != nil {
+		return nil, err
+	}
+	if err := applyAdopt(config, getAnnotation); err != nil {
+		return nil, err
+	}
+	if err := applyStaticMemory(config, getAnnotation, runtimeConfig); err != nil {
+		return nil, err
+	}
+	if err := applyPassthrough(config, ocispec, getAnnotation, runtimeConfig); err != nil {
+		return nil, err
+	}
+	if err := validateConsoleAnnotations(config.OS, ocispec.Annotations); err != nil {
+		return nil, err
+	}
+	if _, err := cntr.ParseRestartPolicy(ocispec.Annotations); err != nil {
+		return nil, err
+	}
+
 	// Validate resource limits against system constraints
 	applyContainerRuntimeDefaults(config, ocispec.Annotations, runtimeConfig)
 	if err := cntr.ValidateResourceLimits(config); err != nil {
@@ -351,7 +382,7 @@ func SandboxConfig(ocispec *specs.Spec, rc RuntimeConfig, bundle, sbContainerID
 	// }
 
 	staticResMngt := rc.StaticResourceManagement
-	hugePage := pedestal.HugePageSupport(staticResMngt)
+	hugePage := pedestal.HugePageSupport(rc.HugePageSupport) || containerConfig.StaticMemory
 
 	// update container resource for openamp-based client is out of plan
 
@@ -381,6 +412,14 @@ func SandboxConfig(ocispec *specs.Spec, rc RuntimeConfig, bundle, sbContainerID
 		EnableVCPUsPinning: false,
 		SharedCPUPool:      rc.SharedCPUPool,
 		InfraOnly:          containerConfig.IsInfra,
+
+		IRQAffinitySteering: rc.IRQAffinitySteering,
+		ConsoleLogSize:      rc.ConsoleLogSize,
+		PersistRecords:      rc.PersistRecords,
+		Workload: workloadName(func(key string) (string, bool) {
+			v, ok := ocispec.Annotations[key]
+			return v, ok && v != ""
+		}, sbContainerID, ctrAnnotations.SandboxNamespace, ctrAnnotations.SandboxName),
 	}
 
 	applySandboxAnnotations(*ocispec, &sandboxConfig)
@@ -394,6 +433,207 @@ func SandboxConfig(ocispec *specs.Spec, rc RuntimeConfig, bundle, sbContainerID
 	return sandboxConfig, nil
 }
 
+// validateConsoleAnnotations rejects exec, console translation, readiness and
+// exit pattern annotations at create, rather than when they are first used.
+func validateConsoleAnnotations(os string, annotations map[string]string) error {
+	profile := osprofile.Lookup(os)
+	if _, err := profile.ExecShell(annotations); err != nil {
+		return err
+	}
+	if _, err := profile.Controls(annotations); err != nil {
+		return err
+	}
+	if _, err := profile.Readiness(annotations); err != nil {
+		return err
+	}
+	_, err := osprofile.ParseExitPatterns(annotations)
+	return err
+}
+
+// workloadName names a sandbox or container the same across re-creations: a
+// pod re-created by kubelet after a node reboot gets new ids but keeps the
+// names in keys. It is id when the names are not all there, e.g. under ctr.
+func workloadName(getAnnotation func(string) (string, bool), id string, keys ...string) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name, ok := getAnnotation(key)
+		if !ok || strings.ContainsRune(name, '/') {
+			return id
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "_")
+}
+
+// applyCPUSet takes the pCPUs allocated by micrun-device-plugin over the OCI cpuset.
+func applyCPUSet(config *cntr.ContainerConfig, getAnnotation func(string) (string, bool)) error {
+	value, ok := getAnnotation(defs.ContainerCPUSet)
+	if !ok || config.IsInfra {
+		return nil
+	}
+	set, err := cpuset.Parse(value)
+	if err != nil || set.IsEmpty() {
+		return fmt.Errorf("invalid %s %q: %v", defs.ContainerCPUSet, value, err)
+	}
+	if ociSet := config.CPUSet(); ociSet != "" && ociSet != set.String() {
+		log.Debugf("cpuset %s of %s is replaced by allocated pcpus %s", ociSet, config.ID, set)
+	}
+	config.SetCPUSet(set.String())
+	return nil
+}
+
+// applyAdopt names the running client the container takes over instead of booting one.
+func applyAdopt(config *cntr.ContainerConfig, getAnnotation func(string) (string, bool)) error {
+	value, ok := getAnnotation(defs.ContainerAdopt)
+	if !ok || config.IsInfra {
+		return nil
+	}
+	name := strings.TrimSpace(value)
+	if name == "" || strings.ContainsAny(name, "/ ") || len(name) > libmica.MaxNameLen {
+		return fmt.Errorf("invalid %s %q", defs.ContainerAdopt, value)
+	}
+	if h, ok := getAnnotation(defs.FirmwareHash); ok {
+		if b, err := hex.DecodeString(strings.TrimSpace(h)); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid %s %q, want a sha-256 in hex", defs.FirmwareHash, h)
+		}
+	}
+	config.Client = name
+	return nil
+}
+
+// applyVCPUBinding enables 1:1 vcpu binding when requested by annotation.
+// The vcpu number is forced to the cpuset size, each vcpu gets its own pcpu.
+// Only Xen places single vcpus, the binding is rejected on other pedestals and
+// without a cpuset, a container asking for it never runs unpinned.
+func applyVCPUBinding(config *cntr.ContainerConfig, getAnnotation func(string) (string, bool)) error {
+	value, ok := getAnnotation(defs.VCPUBinding)
+	if !ok || config.IsInfra {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %v", defs.VCPUBinding, value, err)
+	}
+	if !enabled {
+		return nil
+	}
+	if config.PedestalType != pedestal.Xen {
+		return fmt.Errorf("%s needs the %s pedestal, container %s runs on %s",
+			defs.VCPUBinding, pedestal.Xen, config.ID, config.PedestalType)
+	}
+
+	set, err := cpuset.Parse(config.CPUSet())
+	if err != nil {
+		return fmt.Errorf("%s of container %s: invalid cpuset %q: %v", defs.VCPUBinding, config.ID, config.CPUSet(), err)
+	}
+	if set.IsEmpty() {
+		return fmt.Errorf("%s requires a cpuset, container %s has none", defs.VCPUBinding, config.ID)
+	}
+	config.VCPUBinding = true
+	config.VCPUNum = uint32(set.Size())
+	config.PCPUNum = set.Size()
+	return nil
+}
+
+// applyStaticMemory switches the client to hugepage backed static memory when
+// hugepage limits, the runtime config or the annotation ask for it and the host supports it.
+// A container asking for it by hugepage limits or annotation is rejected on hosts
+// that cannot honour it, the runtime config default just falls back to dynamic memory.
+func applyStaticMemory(config *cntr.ContainerConfig, getAnnotation func(string) (string, bool), runtimeConfig *RuntimeConfig) error {
+	if config.IsInfra {
+		return nil
+	}
+	requested := runtimeConfig != nil && runtimeConfig.HugePageSupport
+	explicit := config.HasHugepageLimits()
+	if explicit {
+		requested = true
+	}
+	if value, ok := getAnnotation(defs.RuntimePrefix + "hugepage_enable"); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			requested, explicit = b, b
+		} else {
+			log.Debugf("invalid bool for %s: %s", defs.RuntimePrefix+"hugepage_enable", value)
+		}
+	}
+	config.StaticMemory = pedestal.HugePageSupport(requested)
+	if requested && !config.StaticMemory {
+		if explicit {
+			return fmt.Errorf("%w: hugepage backed static memory of %s on pedestal %s",
+				er.NotSupported, config.ID, pedestal.GetHostPed())
+		}
+		log.Warnf("hugepage backed static memory is not supported on this host, %s uses dynamic memory", config.ID)
+	}
+	return nil
+}
+
+// applyPassthrough collects the host devices requested by OCI devices and the
+// iomem/irqs/dtdev annotations, and checks them against the host allowlist.
+// OCI devices that are not allowlisted are ordinary Linux devices and are ignored,
+// while annotated resources outside of the allowlist are rejected.
+func applyPassthrough(config *cntr.ContainerConfig, ocispec specs.Spec, getAnnotation func(string) (string, bool), runtimeConfig *RuntimeConfig) error {
+	if config.IsInfra {
+		return nil
+	}
+	iomem, _ := getAnnotation(defs.ContainerIOMem)
+	irqs, _ := getAnnotation(defs.ContainerIRQs)
+	dtdev, _ := getAnnotation(defs.ContainerDTDev)
+	annotated, err := passthrough.Parse(iomem, irqs, dtdev)
+	if err != nil {
+		return fmt.Errorf("invalid passthrough annotations: %w", err)
+	}
+	// Devices allocated by micrun-device-plugin.
+	for key, value := range ocispec.Annotations {
+		if !strings.HasPrefix(key, defs.ContainerDevicePrefix) {
+			continue
+		}
+		allocated, err := passthrough.Decode(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		annotated = annotated.Merge(allocated)
+	}
+
+	var devices []specs.LinuxDevice
+	var rules []specs.LinuxDeviceCgroup
+	if ocispec.Linux != nil {
+		devices = ocispec.Linux.Devices
+		if ocispec.Linux.Resources != nil {
+			rules = ocispec.Linux.Resources.Devices
+		}
+	}
+	if annotated.Empty() && len(devices) == 0 && len(rules) == 0 {
+		return nil
+	}
+
+	rc := runtimeConfig
+	if rc == nil {
+		rc = NewRuntimeConfig()
+	}
+	allowlist, err := passthrough.LoadAllowlist(rc.DeviceAllowlist)
+	if err != nil {
+		if annotated.Empty() {
+			log.Debugf("no device allowlist, skip device passthrough: %v", err)
+			return nil
+		}
+		return err
+	}
+	if err := allowlist.Permits(annotated); err != nil {
+		return err
+	}
+
+	config.Passthrough = allowlist.Resolve(devices, rules).Merge(annotated)
+	// The create message of micad only carries the iomem list, on every pedestal:
+	// irqs and device tree nodes would be claimed but never reach the client.
+	if len(config.Passthrough.IRQs) > 0 || len(config.Passthrough.DTDevs) > 0 {
+		return fmt.Errorf("%w: irq and dtdev passthrough of %s on pedestal %s, only iomem is: %s",
+			er.NotSupported, config.ID, config.PedestalType, config.Passthrough.Encode())
+	}
+	if !config.Passthrough.Empty() {
+		log.Debugf("devices passed through to %s: %s", config.ID, config.Passthrough.Encode())
+	}
+	return nil
+}
+
 // formatCPULimit formats CPU limit information into human readable string
 func formatCPULimit(config *cntr.ContainerConfig) string {
 	if config == nil {
@@ -477,6 +717,9 @@ func applyContainerRuntimeDefaults(config *cntr.ContainerConfig, annotations map
 	}
 
 	config.MaxVcpuNum = resolveMaxVcpu(annotations, runtimeCfg)
+	if config.MaxVcpuNum < config.VCPUNum {
+		config.MaxVcpuNum = config.VCPUNum
+	}
 	config.MemoryThresholdMB = calculateClientMemThreshold(config, runtimeCfg)
 }
 
@@ -586,7 +829,7 @@ func applySandboxAnnotations(ocispec specs.Spec, cfg *cntr.SandboxConfig) {
 
 		case defs.RuntimePrefix + "hugepage_enable":
 			if b, err := strconv.ParseBool(value); err == nil {
-				cfg.HugePageSupport = b
+				cfg.HugePageSupport = pedestal.HugePageSupport(b)
 			} else {
 				log.Debugf("invalid bool for %s: %s", key, value)
 			}
@@ -1,10 +1,15 @@
 package oci
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	defs "micrun/definitions"
+	er "micrun/errors"
 	cntr "micrun/pkg/micantainer"
+	"micrun/pkg/pedestal"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
@@ -57,3 +62,175 @@ func TestApplyContainerRuntimeDefaultsMemoryFallback(t *testing.T) {
 		t.Fatalf("MaxVcpuNum = %d, want default %d", cfg.MaxVcpuNum, defaultMaxContainerVCPUs)
 	}
 }
+
+func TestApplyVCPUBinding(t *testing.T) {
+	annotations := map[string]string{defs.VCPUBinding: "true"}
+	getAnnotation := func(key string) (string, bool) {
+		v, ok := annotations[key]
+		return v, ok
+	}
+
+	cfg := &cntr.ContainerConfig{
+		VCPUNum:      1,
+		PedestalType: pedestal.Xen,
+		Resources: &specs.LinuxResources{
+			CPU: &specs.LinuxCPU{Cpus: "2-3,5"},
+		},
+	}
+	if err := applyVCPUBinding(cfg, getAnnotation); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.VCPUBinding {
+		t.Fatal("VCPUBinding should be enabled")
+	}
+	if cfg.VCPUNum != 3 {
+		t.Fatalf("VCPUNum = %d, want 3", cfg.VCPUNum)
+	}
+
+	noCpuset := &cntr.ContainerConfig{VCPUNum: 1, PedestalType: pedestal.Xen, Resources: &specs.LinuxResources{}}
+	if err := applyVCPUBinding(noCpuset, getAnnotation); err == nil {
+		t.Fatal("binding without cpuset should be rejected")
+	}
+
+	annotations[defs.VCPUBinding] = "yes please"
+	if err := applyVCPUBinding(&cntr.ContainerConfig{PedestalType: pedestal.Xen}, getAnnotation); err == nil {
+		t.Fatal("invalid binding annotation should be rejected")
+	}
+	annotations[defs.VCPUBinding] = "true"
+
+	openamp := &cntr.ContainerConfig{
+		PedestalType: pedestal.OpenAMP,
+		Resources:    &specs.LinuxResources{CPU: &specs.LinuxCPU{Cpus: "2-3"}},
+	}
+	if err := applyVCPUBinding(openamp, getAnnotation); err == nil {
+		t.Fatal("binding on a pedestal without per-vcpu placement should be rejected")
+	}
+}
+
+func TestApplyCPUSet(t *testing.T) {
+	annotations := map[string]string{defs.ContainerCPUSet: "5,3,4"}
+	getAnnotation := func(key string) (string, bool) {
+		v, ok := annotations[key]
+		return v, ok
+	}
+
+	cfg := &cntr.ContainerConfig{
+		Resources: &specs.LinuxResources{
+			CPU: &specs.LinuxCPU{Cpus: "0-7"},
+		},
+	}
+	if err := applyCPUSet(cfg, getAnnotation); err != nil {
+		t.Fatalf("applyCPUSet: %v", err)
+	}
+	if got := cfg.CPUSet(); got != "3-5" {
+		t.Fatalf("CPUSet() = %q, want 3-5", got)
+	}
+
+	annotations[defs.ContainerCPUSet] = "a-b"
+	if err := applyCPUSet(&cntr.ContainerConfig{}, getAnnotation); err == nil {
+		t.Fatal("invalid cpuset annotation should be rejected")
+	}
+}
+
+func TestApplyStaticMemory(t *testing.T) {
+	if pedestal.GetHostPed() == pedestal.Xen {
+		t.Skip("static memory is honoured on xen hosts")
+	}
+	annotations := map[string]string{}
+	getAnnotation := func(key string) (string, bool) {
+		v, ok := annotations[key]
+		return v, ok
+	}
+
+	// the node-wide default falls back to dynamic memory
+	cfg := &cntr.ContainerConfig{Resources: &specs.LinuxResources{}}
+	if err := applyStaticMemory(cfg, getAnnotation, &RuntimeConfig{HugePageSupport: true}); err != nil || cfg.StaticMemory {
+		t.Fatalf("runtime config default = %v, static %v, want a fallback", err, cfg.StaticMemory)
+	}
+
+	annotations[defs.RuntimePrefix+"hugepage_enable"] = "true"
+	if err := applyStaticMemory(cfg, getAnnotation, nil); !errors.Is(err, er.NotSupported) {
+		t.Fatalf("static memory asked for by annotation: got %v, want NotSupported", err)
+	}
+
+	delete(annotations, defs.RuntimePrefix+"hugepage_enable")
+	cfg.Resources.HugepageLimits = []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 64 << 20}}
+	if err := applyStaticMemory(cfg, getAnnotation, nil); !errors.Is(err, er.NotSupported) {
+		t.Fatalf("hugepage limits: got %v, want NotSupported", err)
+	}
+}
+
+func TestApplyPassthrough(t *testing.T) {
+	allowlist := filepath.Join(t.TempDir(), "devices.allow")
+	if err := os.WriteFile(allowlist, []byte("can0 iomem=fe200,1 irqs=45\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rc := &RuntimeConfig{DeviceAllowlist: allowlist}
+	annotations := map[string]string{defs.ContainerIOMem: "fe200,1"}
+	getAnnotation := func(key string) (string, bool) {
+		v, ok := annotations[key]
+		return v, ok
+	}
+
+	cfg := &cntr.ContainerConfig{ID: "rtos", PedestalType: pedestal.OpenAMP}
+	if err := applyPassthrough(cfg, specs.Spec{}, getAnnotation, rc); err != nil {
+		t.Fatalf("iomem passthrough: %v", err)
+	}
+	if got := cfg.Passthrough.IOMemConf(); got != "fe200,1" {
+		t.Fatalf("iomem = %q, want fe200,1", got)
+	}
+
+	// micad is told the iomem list only, irqs would be claimed and dropped
+	annotations[defs.ContainerIRQs] = "45"
+	for _, ped := range []pedestal.PedType{pedestal.OpenAMP, pedestal.Xen} {
+		cfg := &cntr.ContainerConfig{ID: "rtos", PedestalType: ped}
+		if err := applyPassthrough(cfg, specs.Spec{}, getAnnotation, rc); !errors.Is(err, er.NotSupported) {
+			t.Errorf("irq passthrough on %s: got %v, want NotSupported", ped, err)
+		}
+	}
+}
+
+func TestApplyAdopt(t *testing.T) {
+	annotations := map[string]string{defs.ContainerAdopt: "rtos-boot"}
+	getAnnotation := func(key string) (string, bool) {
+		v, ok := annotations[key]
+		return v, ok
+	}
+
+	cfg := &cntr.ContainerConfig{ID: "rtos"}
+	if err := applyAdopt(cfg, getAnnotation); err != nil || cfg.Client != "rtos-boot" {
+		t.Fatalf("applyAdopt: client %q, %v", cfg.Client, err)
+	}
+
+	for _, bad := range []map[string]string{
+		{defs.ContainerAdopt: ""},
+		{defs.ContainerAdopt: "../rtos"},
+		{defs.ContainerAdopt: "rtos", defs.FirmwareHash: "ab01"},
+	} {
+		annotations = bad
+		if err := applyAdopt(&cntr.ContainerConfig{}, getAnnotation); err == nil {
+			t.Errorf("applyAdopt accepted %v", bad)
+		}
+	}
+}
+
+func TestWorkloadName(t *testing.T) {
+	annotations := map[string]string{
+		"io.kubernetes.cri.sandbox-namespace": "edge",
+		"io.kubernetes.cri.sandbox-name":      "rtos-pod",
+	}
+	getAnnotation := func(key string) (string, bool) {
+		v, ok := annotations[key]
+		return v, ok
+	}
+	keys := []string{"io.kubernetes.cri.sandbox-namespace", "io.kubernetes.cri.sandbox-name"}
+
+	if got := workloadName(getAnnotation, "0a1b", keys...); got != "edge_rtos-pod" {
+		t.Fatalf("workloadName = %q, want edge_rtos-pod", got)
+	}
+	// a container run by ctr has no cri names, its id is kept across re-creations
+	delete(annotations, "io.kubernetes.cri.sandbox-name")
+	if got := workloadName(getAnnotation, "0a1b", keys...); got != "0a1b" {
+		t.Fatalf("workloadName without names = %q, want the id", got)
+	}
+}
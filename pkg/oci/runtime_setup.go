@@ -5,13 +5,16 @@ import (
 	"fmt"
 	defs "micrun/definitions"
 	log "micrun/logger"
+	"micrun/pkg/console"
 	"micrun/pkg/pedestal"
 	"micrun/pkg/utils"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	units "github.com/docker/go-units"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
@@ -31,11 +34,17 @@ const (
 	KeyMaxMemory        = "container_maxmem"      // default max memory for container
 	KeyDefaultFirmware  = "firmware_path"         // default firmware path when annotation not set
 	KeySharedCPUPool    = "shared_cpu_pool"       // default=false, shared CPU pool for Xen cpupool management
+	KeyIRQSteering      = "irq_affinity_steering" // default=false, move host irqs away from client cpus
+	KeyDeviceAllowlist  = "device_allowlist"      // default=<MicrunConfDir>/devices.allow, devices allowed to pass through
+	KeyConsoleLogSize   = "console_log_size"      // default=1MB, on-disk console history kept per container
+	KeyGCInterval       = "gc_interval"           // default=0, disabled; period of the orphan check of each shim
+	KeyPersistRecords   = "persist_records"       // default=false, keep records below /var/lib/micrun across node reboots
 )
 
 // final fallbacks:
 const defaultMaxContainerVCPUs = 8
 const defaultContainerInitMemMiB = 32
+const defaultDeviceAllowlist = "devices.allow"
 
 var (
 	HostPedType       = pedestal.GetHostPed()
@@ -56,6 +65,11 @@ var (
 		KeyMinMemory,
 		KeyDefaultFirmware,
 		KeySharedCPUPool,
+		KeyIRQSteering,
+		KeyDeviceAllowlist,
+		KeyConsoleLogSize,
+		KeyGCInterval,
+		KeyPersistRecords,
 	}
 )
 
@@ -83,6 +97,17 @@ type RuntimeConfig struct {
 	MiniVCPUNum         uint32
 	DefaultFirmwarePath string
 	ExclusiveDom0CPU    bool
+	// IRQAffinitySteering moves movable host irqs off the cpus pinned to clients (non-Xen only)
+	IRQAffinitySteering bool
+	// DeviceAllowlist is the file listing host devices that may be passed through to clients
+	DeviceAllowlist string
+	// ConsoleLogSize bounds the console log kept under the container state dir, in bytes
+	ConsoleLogSize int64
+	// GCInterval is the period the shim reports orphans of other containers at, 0 disables it
+	GCInterval time.Duration
+	// PersistRecords keeps a copy of the records that survives a node reboot, the
+	// placement of the clients is re-created from it when their pods come back
+	PersistRecords bool
 }
 
 // NewRuntimeConfig returns a default RuntimeConfig.
@@ -98,6 +123,8 @@ func NewRuntimeConfig() *RuntimeConfig {
 		PauseImage:               defs.PauseImage,
 		MinContainerMemMB:        32,
 		MaxContainerVCPUs:        defaultMaxContainerVCPUs,
+		DeviceAllowlist:          filepath.Join(defs.MicrunConfDir, defaultDeviceAllowlist),
+		ConsoleLogSize:           console.DefaultRetention,
 	}
 	return &cfg
 }
@@ -143,6 +170,11 @@ func (r *RuntimeConfig) convertRawConfig(raw map[string]string) {
 	r.SetSharedCPUPool(raw[KeySharedCPUPool])
 	r.SetStateDir(raw[KeyStateDir])
 	r.SetDefaultFirmwarePath(raw[KeyDefaultFirmware])
+	r.SetIRQAffinitySteering(raw[KeyIRQSteering])
+	r.SetDeviceAllowlist(raw[KeyDeviceAllowlist])
+	r.SetConsoleLogSize(raw[KeyConsoleLogSize])
+	r.SetGCInterval(raw[KeyGCInterval])
+	r.SetPersistRecords(raw[KeyPersistRecords])
 }
 
 func (r *RuntimeConfig) SetDebug(debugStr string) {
@@ -277,6 +309,66 @@ func (r *RuntimeConfig) SetSharedCPUPool(sharedCPUPoolStr string) {
 	r.SharedCPUPool = sharedCPUPool
 }
 
+func (r *RuntimeConfig) SetPersistRecords(flag string) {
+	if strings.TrimSpace(flag) == "" {
+		return
+	}
+	enabled, err := strconv.ParseBool(flag)
+	if err != nil {
+		log.Debugf("failed to parse persist_records %q into bool", flag)
+		return
+	}
+	r.PersistRecords = enabled
+}
+
+func (r *RuntimeConfig) SetIRQAffinitySteering(flag string) {
+	if strings.TrimSpace(flag) == "" {
+		return
+	}
+	enabled, err := strconv.ParseBool(flag)
+	if err != nil {
+		log.Debugf("failed to parse irq_affinity_steering %q into bool", flag)
+		return
+	}
+	r.IRQAffinitySteering = enabled
+}
+
+func (r *RuntimeConfig) SetDeviceAllowlist(path string) {
+	trimmed := strings.TrimSpace(path)
+	if trimmed == "" {
+		return
+	}
+	r.DeviceAllowlist = trimmed
+}
+
+// SetConsoleLogSize accepts a byte size such as "512KB" or "4MB".
+func (r *RuntimeConfig) SetConsoleLogSize(size string) {
+	trimmed := strings.TrimSpace(size)
+	if trimmed == "" {
+		return
+	}
+	bytes, err := units.RAMInBytes(trimmed)
+	if err != nil || bytes <= 0 {
+		log.Debugf("failed to parse console_log_size %q", size)
+		return
+	}
+	r.ConsoleLogSize = bytes
+}
+
+// SetGCInterval accepts a duration such as "10m".
+func (r *RuntimeConfig) SetGCInterval(interval string) {
+	trimmed := strings.TrimSpace(interval)
+	if trimmed == "" {
+		return
+	}
+	d, err := time.ParseDuration(trimmed)
+	if err != nil || d < 0 {
+		log.Debugf("failed to parse gc_interval %q", interval)
+		return
+	}
+	r.GCInterval = d
+}
+
 // ParseRuntimeConfigFromAnno parses runtime configuration from annotations.
 // Annotations hold highest priority for values.
 func (cfg *RuntimeConfig) ParseRuntimeConfigFromAnno(annotations map[string]string) *RuntimeConfig {
@@ -0,0 +1,356 @@
+package osprofile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	defs "micrun/definitions"
+
+	"golang.org/x/sys/unix"
+)
+
+// KeyKind is what happens to a key typed on the container stdin.
+type KeyKind int
+
+const (
+	// KeyForward writes the key to the client console.
+	KeyForward KeyKind = iota
+	// KeyDrop discards the key.
+	KeyDrop
+	// KeyDetach ends the input of the stdio session, the client keeps running.
+	KeyDetach
+	// KeySignal sends a signal to the container from the host side, see Controls.Signals.
+	KeySignal
+)
+
+// KeyAction is the translation of a control key or an escape sequence.
+type KeyAction struct {
+	Kind   KeyKind
+	Signal syscall.Signal
+}
+
+// DefaultStopTimeout is the grace period of a graceful shutdown before the client is destroyed.
+const DefaultStopTimeout = 10 * time.Second
+
+// SignalKind is what a POSIX signal sent to the container does to the RTOS.
+type SignalKind int
+
+const (
+	// SignalIgnore accepts the signal without effect.
+	SignalIgnore SignalKind = iota
+	// SignalStop stops the client from the host side, as SIGKILL does.
+	SignalStop
+	// SignalInput types control bytes into the client console, e.g. ^C to interrupt the shell command.
+	SignalInput
+	// SignalCommand runs a command in the client shell, e.g. a reboot or a stats dump.
+	SignalCommand
+	// SignalShutdown asks the client to power off, with the shutdown command of its
+	// shell or else from the host side, and destroys it when it is still running
+	// after the grace period.
+	SignalShutdown
+	// SignalCrashDump captures the memory image of the client, which keeps running.
+	SignalCrashDump
+)
+
+// SignalAction is what a POSIX signal sent to the container means for the RTOS.
+// A zero SignalAction ignores the signal.
+type SignalAction struct {
+	Kind SignalKind
+	// Input is written to the console for SignalInput.
+	Input []byte
+	// Command is the command line of SignalCommand and SignalShutdown, a
+	// SignalShutdown without command is requested to micad.
+	Command string
+	// Grace bounds SignalShutdown.
+	Grace time.Duration
+}
+
+// Console returns what the action writes to the client console, nil when it
+// does not go through the console.
+func (a SignalAction) Console() []byte {
+	switch a.Kind {
+	case SignalInput:
+		return a.Input
+	case SignalCommand, SignalShutdown:
+		if a.Command != "" {
+			return []byte(a.Command + "\n")
+		}
+	}
+	return nil
+}
+
+// Controls is the control character and signal translation table of a client.
+type Controls struct {
+	// Keys translates single control bytes, bytes not listed are forwarded.
+	Keys map[byte]KeyAction
+	// Escapes translates sequences typed at the beginning of a line, like "~." of ssh.
+	Escapes map[string]KeyAction
+	// Signals translates signals sent to the container, SIGKILL always stops the client.
+	// Signals not listed are not supported by the client, except SIGSTOP and
+	// SIGCONT which pause and resume it.
+	Signals map[syscall.Signal]SignalAction
+}
+
+// defaultControls forward ^C to the client shell, ^\ stays a way out of a stuck client.
+func defaultControls(p Profile, grace time.Duration) Controls {
+	c := Controls{
+		Keys:    map[byte]KeyAction{0x1c: {Kind: KeySignal, Signal: syscall.SIGKILL}},
+		Escapes: map[string]KeyAction{"~.": {Kind: KeyDetach}},
+		Signals: map[syscall.Signal]SignalAction{
+			syscall.SIGTERM: {Kind: SignalShutdown, Command: p.ShutdownCommand, Grace: grace},
+			syscall.SIGHUP:  {},
+			syscall.SIGQUIT: {Kind: SignalCrashDump},
+		},
+	}
+	if p.Shell != nil {
+		c.Signals[syscall.SIGINT] = SignalAction{Kind: SignalInput, Input: []byte{0x03}}
+	} else {
+		// nothing runs in the client to be interrupted
+		c.Keys[0x03] = KeyAction{Kind: KeySignal, Signal: syscall.SIGKILL}
+		c.Signals[syscall.SIGINT] = SignalAction{Kind: SignalStop}
+	}
+	if p.RebootCommand != "" {
+		c.Signals[syscall.SIGHUP] = SignalAction{Kind: SignalCommand, Command: p.RebootCommand}
+	}
+	if p.StatsCommand != "" {
+		c.Signals[syscall.SIGUSR1] = SignalAction{Kind: SignalCommand, Command: p.StatsCommand}
+	}
+	return c
+}
+
+// Controls returns the translation table of the profile with the annotations
+// ContainerConsoleKeys, ContainerSignals and ContainerStopTimeout merged over it.
+func (p Profile) Controls(annotations map[string]string) (*Controls, error) {
+	grace, err := StopTimeout(annotations)
+	if err != nil {
+		return nil, err
+	}
+	c := defaultControls(p, grace)
+	if v := annotations[defs.ContainerConsoleKeys]; v != "" {
+		if err := c.parseKeys(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", defs.ContainerConsoleKeys, v, err)
+		}
+	}
+	if v := annotations[defs.ContainerSignals]; v != "" {
+		if err := c.parseSignals(p, grace, v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", defs.ContainerSignals, v, err)
+		}
+	}
+	return &c, nil
+}
+
+// StopTimeout returns the grace period given by ContainerStopTimeout.
+func StopTimeout(annotations map[string]string) (time.Duration, error) {
+	v, ok := annotations[defs.ContainerStopTimeout]
+	if !ok || v == "" {
+		return DefaultStopTimeout, nil
+	}
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid %s %q: want a number of seconds", defs.ContainerStopTimeout, v)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Signal returns the action of sig, ok is false when the table does not list it.
+func (c *Controls) Signal(sig syscall.Signal) (SignalAction, bool) {
+	if sig == syscall.SIGKILL {
+		return SignalAction{Kind: SignalStop}, true
+	}
+	a, ok := c.Signals[sig]
+	return a, ok
+}
+
+// parseKeys parses "^C=forward,^\=SIGKILL,~.=detach".
+func (c *Controls) parseKeys(v string) error {
+	for _, entry := range strings.Split(v, ",") {
+		key, action, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return fmt.Errorf("entry %q is not <key>=<action>", entry)
+		}
+		act, err := parseKeyAction(action)
+		if err != nil {
+			return err
+		}
+		if b, ok := parseControlKey(key); ok {
+			c.Keys[b] = act
+		} else if len(key) > 1 {
+			c.Escapes[key] = act
+		} else {
+			return fmt.Errorf("%q is neither a control key nor an escape sequence", key)
+		}
+	}
+	return nil
+}
+
+// parseSignals parses "SIGINT=^C,SIGTERM=shutdown,SIGHUP=ignore,SIGUSR2=cmd:<command>",
+// the keywords shutdown, reboot and stats use the commands of the profile.
+func (c *Controls) parseSignals(p Profile, grace time.Duration, v string) error {
+	for _, entry := range strings.Split(v, ",") {
+		name, action, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return fmt.Errorf("entry %q is not <signal>=<action>", entry)
+		}
+		sig, err := parseSignal(name)
+		if err != nil {
+			return err
+		}
+		if sig == syscall.SIGKILL {
+			return fmt.Errorf("SIGKILL always stops the client")
+		}
+		a, err := parseSignalAction(p, grace, action)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.Signals[sig] = a
+	}
+	return nil
+}
+
+func parseSignalAction(p Profile, grace time.Duration, v string) (SignalAction, error) {
+	command := func(cmd, what string) (string, error) {
+		if cmd == "" {
+			return "", fmt.Errorf("client os %q has no %s command", p.Name, what)
+		}
+		return cmd, nil
+	}
+	switch v {
+	case "stop":
+		return SignalAction{Kind: SignalStop}, nil
+	case "ignore":
+		return SignalAction{}, nil
+	case "crashdump":
+		return SignalAction{Kind: SignalCrashDump}, nil
+	case "shutdown":
+		return SignalAction{Kind: SignalShutdown, Command: p.ShutdownCommand, Grace: grace}, nil
+	case "reboot":
+		cmd, err := command(p.RebootCommand, v)
+		return SignalAction{Kind: SignalCommand, Command: cmd}, err
+	case "stats":
+		cmd, err := command(p.StatsCommand, v)
+		return SignalAction{Kind: SignalCommand, Command: cmd}, err
+	}
+	if cmd, ok := strings.CutPrefix(v, "cmd:"); ok && cmd != "" {
+		return SignalAction{Kind: SignalCommand, Command: cmd}, nil
+	}
+	if b, ok := parseControlKey(v); ok {
+		return SignalAction{Kind: SignalInput, Input: []byte{b}}, nil
+	}
+	return SignalAction{}, fmt.Errorf("unknown action %q", v)
+}
+
+func parseKeyAction(v string) (KeyAction, error) {
+	switch v {
+	case "forward":
+		return KeyAction{Kind: KeyForward}, nil
+	case "drop":
+		return KeyAction{Kind: KeyDrop}, nil
+	case "detach":
+		return KeyAction{Kind: KeyDetach}, nil
+	}
+	sig, err := parseSignal(v)
+	if err != nil {
+		return KeyAction{}, fmt.Errorf("unknown key action %q", v)
+	}
+	return KeyAction{Kind: KeySignal, Signal: sig}, nil
+}
+
+// parseControlKey parses "^C", "^\", "^?" or "0x03".
+func parseControlKey(v string) (byte, bool) {
+	if len(v) == 2 && v[0] == '^' {
+		switch c := v[1]; {
+		case c == '?':
+			return 0x7f, true
+		case c >= '@' && c <= '_':
+			return c - '@', true
+		case c >= 'a' && c <= 'z':
+			return c - 'a' + 1, true
+		}
+		return 0, false
+	}
+	if strings.HasPrefix(v, "0x") {
+		if n, err := strconv.ParseUint(v[2:], 16, 8); err == nil && (n < 0x20 || n == 0x7f) {
+			return byte(n), true
+		}
+	}
+	return 0, false
+}
+
+func parseSignal(v string) (syscall.Signal, error) {
+	name := strings.ToUpper(v)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("unknown signal %q", v)
+	}
+	return sig, nil
+}
+
+// InputFilter applies the key translation to a stdin stream.
+type InputFilter struct {
+	controls  *Controls
+	lineStart bool
+	// pending holds the beginning of a possible escape sequence.
+	pending []byte
+}
+
+// NewInputFilter returns a filter of the input of one stdio session.
+func (c *Controls) NewInputFilter() *InputFilter {
+	return &InputFilter{controls: c, lineStart: true}
+}
+
+// Filter returns the bytes of p to forward to the client, up to the first key
+// which is not forwarded. act is that key's action when hit is true, the rest
+// of p is then discarded.
+func (f *InputFilter) Filter(p []byte) (out []byte, act KeyAction, hit bool) {
+	for _, b := range p {
+		if f.lineStart || len(f.pending) > 0 {
+			seq := string(append(f.pending, b))
+			if a, ok := f.controls.Escapes[seq]; ok {
+				f.pending = f.pending[:0]
+				if a.Kind == KeyForward {
+					out = append(out, seq...)
+					f.lineStart = false
+					continue
+				}
+				if a.Kind == KeyDrop {
+					f.lineStart = false
+					continue
+				}
+				return out, a, true
+			}
+			if f.escapePrefix(seq) {
+				f.pending = append(f.pending, b)
+				f.lineStart = false
+				continue
+			}
+			// not an escape, the held bytes are plain input
+			out = append(out, f.pending...)
+			f.pending = f.pending[:0]
+		}
+
+		if a, ok := f.controls.Keys[b]; ok && a.Kind != KeyForward {
+			if a.Kind == KeyDrop {
+				continue
+			}
+			return out, a, true
+		}
+		out = append(out, b)
+		f.lineStart = b == '\r' || b == '\n'
+	}
+	return out, KeyAction{}, false
+}
+
+func (f *InputFilter) escapePrefix(seq string) bool {
+	for e := range f.controls.Escapes {
+		if len(seq) < len(e) && strings.HasPrefix(e, seq) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package osprofile
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	defs "micrun/definitions"
+)
+
+// DefaultFailureCode is the exit status of a failure match without exit code.
+const DefaultFailureCode = 1
+
+// ExitPatterns turns batch firmware into jobs: test images print their verdict
+// on the console and idle forever, a matching line ends the container.
+type ExitPatterns struct {
+	Success *regexp.Regexp
+	Failure *regexp.Regexp
+	// CodeGroup is the name or number of the capture group holding the exit
+	// status, "code" by default. A match without it exits 0 on success and
+	// DefaultFailureCode on failure.
+	CodeGroup string
+}
+
+// ParseExitPatterns returns the exit patterns of the annotations, nil when the
+// container has none.
+func ParseExitPatterns(annotations map[string]string) (*ExitPatterns, error) {
+	e := ExitPatterns{CodeGroup: "code"}
+	for key, re := range map[string]**regexp.Regexp{
+		defs.ContainerExitSuccess: &e.Success,
+		defs.ContainerExitFailure: &e.Failure,
+	} {
+		v := annotations[key]
+		if v == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", key, v, err)
+		}
+		*re = pattern
+	}
+	if e.Success == nil && e.Failure == nil {
+		return nil, nil
+	}
+	if v := annotations[defs.ContainerExitCodeGroup]; v != "" {
+		e.CodeGroup = v
+	}
+	return &e, nil
+}
+
+// Match returns the exit status told by line, ok is false when the line matches no pattern.
+func (e *ExitPatterns) Match(line string) (code int, ok bool) {
+	if e.Failure != nil {
+		if m := e.Failure.FindStringSubmatch(line); m != nil {
+			if code, ok := e.code(e.Failure, m); ok {
+				return code, true
+			}
+			return DefaultFailureCode, true
+		}
+	}
+	if e.Success != nil {
+		if m := e.Success.FindStringSubmatch(line); m != nil {
+			if code, ok := e.code(e.Success, m); ok {
+				return code, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func (e *ExitPatterns) code(re *regexp.Regexp, m []string) (int, bool) {
+	i := re.SubexpIndex(e.CodeGroup)
+	if i < 0 {
+		n, err := strconv.Atoi(e.CodeGroup)
+		if err != nil || n <= 0 || n >= len(m) {
+			return 0, false
+		}
+		i = n
+	}
+	code, err := strconv.Atoi(m[i])
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
@@ -0,0 +1,138 @@
+// Package osprofile describes how micrun drives the console of each client OS.
+//
+// RTOS clients have no processes and no POSIX signals, the only handle micrun
+// has on a running client is its console. A profile collects the per-OS
+// conventions of that console, e.g. the shell prompt and how errors are reported.
+package osprofile
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	defs "micrun/definitions"
+	"micrun/pkg/console"
+)
+
+// DefaultExecTimeout bounds a single exec command.
+const DefaultExecTimeout = 30 * time.Second
+
+// Profile holds the console conventions of a client OS.
+type Profile struct {
+	Name string
+	// Shell is nil when the OS has no interactive shell.
+	Shell *console.Shell
+	// ShutdownCommand powers the client off gracefully, the action of SIGTERM.
+	ShutdownCommand string
+	// RebootCommand restarts the client, the action of SIGHUP.
+	RebootCommand string
+	// StatsCommand prints the thread and memory statistics, the action of SIGUSR1.
+	StatsCommand string
+	// ReadyPattern matches the boot banner printed once the client booted.
+	ReadyPattern *regexp.Regexp
+	// ResizeCommand tells the client shell its terminal size, "{rows}" and "{cols}"
+	// are replaced by the size. Empty when the client is not told.
+	ResizeCommand string
+}
+
+var commandNotFound = console.ShellError{Pattern: regexp.MustCompile(`command not found`), Code: 127}
+
+var profiles = map[string]Profile{
+	"zephyr": {
+		Name: "zephyr",
+		Shell: &console.Shell{
+			Prompt: regexp.MustCompile(`uart:~\$ ?$`),
+			Errors: []console.ShellError{
+				commandNotFound,
+				{Pattern: regexp.MustCompile(`wrong parameter count`), Code: 2},
+			},
+		},
+		RebootCommand: "kernel reboot cold",
+		StatsCommand:  "kernel threads",
+		ReadyPattern:  regexp.MustCompile(`\*\*\* Booting Zephyr OS`),
+	},
+	"uniproton": {
+		Name: "uniproton",
+		Shell: &console.Shell{
+			Prompt: regexp.MustCompile(`(UniProton|OHOS) # ?$`),
+			Errors: []console.ShellError{commandNotFound},
+		},
+	},
+	"liteos": {
+		Name: "liteos",
+		Shell: &console.Shell{
+			Prompt: regexp.MustCompile(`OHOS # ?$`),
+			Errors: []console.ShellError{commandNotFound},
+		},
+		StatsCommand: "task",
+	},
+	"linux": {
+		Name: "linux",
+		Shell: &console.Shell{
+			Prompt:          regexp.MustCompile(`[#$] ?$`),
+			SentinelCommand: "echo",
+			StatusCommand:   "echo $?",
+		},
+		ShutdownCommand: "poweroff",
+		RebootCommand:   "reboot",
+		StatsCommand:    "top -b -n 1",
+	},
+}
+
+// Lookup returns the profile of os, an unknown OS gets a profile without shell.
+func Lookup(os string) Profile {
+	if p, ok := profiles[os]; ok {
+		return p
+	}
+	return Profile{Name: os}
+}
+
+// ExecShell returns the shell of the profile with the exec annotations applied,
+// it returns nil when the client has no shell to run commands in.
+func (p Profile) ExecShell(annotations map[string]string) (*console.Shell, error) {
+	sh := console.Shell{Timeout: DefaultExecTimeout}
+	if p.Shell != nil {
+		sh = *p.Shell
+		sh.Timeout = DefaultExecTimeout
+	}
+
+	if v, ok := annotations[defs.ContainerExecPrompt]; ok && v != "" {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", defs.ContainerExecPrompt, v, err)
+		}
+		sh.Prompt = re
+	}
+	if v, ok := annotations[defs.ContainerExecSentinel]; ok {
+		sh.SentinelCommand = v
+	}
+	if v, ok := annotations[defs.ContainerExecTimeout]; ok && v != "" {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid %s %q: want a positive number of seconds", defs.ContainerExecTimeout, v)
+		}
+		sh.Timeout = time.Duration(seconds) * time.Second
+	}
+
+	if sh.Prompt == nil && sh.SentinelCommand == "" {
+		return nil, nil
+	}
+	return &sh, nil
+}
+
+// GuestResize returns the command line telling the client shell its terminal
+// size, or "" when the size is only applied to the host PTY. The annotation
+// overrides the resize command of the profile. Typing into the shell on every
+// resize disturbs the client, so no profile has one by default.
+func (p Profile) GuestResize(annotations map[string]string, rows, cols uint16) string {
+	cmd := p.ResizeCommand
+	if v, ok := annotations[defs.ContainerResizeCommand]; ok {
+		cmd = v
+	}
+	if cmd == "" {
+		return ""
+	}
+	return strings.NewReplacer("{rows}", strconv.Itoa(int(rows)), "{cols}", strconv.Itoa(int(cols))).Replace(cmd) + "\n"
+}
@@ -0,0 +1,258 @@
+package osprofile
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	defs "micrun/definitions"
+)
+
+func TestExecShell(t *testing.T) {
+	sh, err := Lookup("zephyr").ExecShell(nil)
+	if err != nil || sh == nil {
+		t.Fatalf("zephyr shell = %v, %v", sh, err)
+	}
+	if !sh.Prompt.MatchString("uart:~$ ") || sh.Timeout != DefaultExecTimeout {
+		t.Fatalf("unexpected zephyr shell %+v", sh)
+	}
+
+	// annotations override the profile without changing it
+	sh, err = Lookup("zephyr").ExecShell(map[string]string{
+		defs.ContainerExecPrompt:   `nsh> $`,
+		defs.ContainerExecSentinel: "echo",
+		defs.ContainerExecTimeout:  "5",
+	})
+	if err != nil || !sh.Prompt.MatchString("nsh> ") || sh.SentinelCommand != "echo" || sh.Timeout != 5*time.Second {
+		t.Fatalf("overridden shell = %+v, %v", sh, err)
+	}
+	if p := Lookup("zephyr").Shell; p.SentinelCommand != "" || p.Timeout != 0 {
+		t.Fatalf("profile was modified: %+v", p)
+	}
+
+	// a client without known shell can still be given one
+	if sh, err := Lookup("baremetal").ExecShell(nil); sh != nil || err != nil {
+		t.Fatalf("baremetal shell = %+v, %v", sh, err)
+	}
+	if sh, err := Lookup("baremetal").ExecShell(map[string]string{defs.ContainerExecPrompt: `> $`}); sh == nil || err != nil {
+		t.Fatalf("annotated baremetal shell = %+v, %v", sh, err)
+	}
+
+	for _, bad := range []map[string]string{
+		{defs.ContainerExecPrompt: `(`},
+		{defs.ContainerExecTimeout: "0"},
+		{defs.ContainerExecTimeout: "10s"},
+	} {
+		if _, err := Lookup("linux").ExecShell(bad); err == nil {
+			t.Fatalf("ExecShell(%v) should fail", bad)
+		}
+	}
+}
+
+func TestGuestResize(t *testing.T) {
+	annotations := map[string]string{defs.ContainerResizeCommand: "stty rows {rows} cols {cols}"}
+	oses := []string{"baremetal"}
+	for os := range profiles {
+		oses = append(oses, os)
+	}
+	for _, os := range oses {
+		// nothing is typed into the client unless asked for
+		if cmd := Lookup(os).GuestResize(nil, 40, 132); cmd != "" {
+			t.Fatalf("%s resize = %q", os, cmd)
+		}
+		if cmd := Lookup(os).GuestResize(annotations, 40, 132); cmd != "stty rows 40 cols 132\n" {
+			t.Fatalf("%s annotated resize = %q", os, cmd)
+		}
+	}
+
+	// the annotation overrides the resize command of a profile, or disables it
+	p := Profile{Name: "zephyr", ResizeCommand: "resize"}
+	if cmd := p.GuestResize(nil, 40, 132); cmd != "resize\n" {
+		t.Fatalf("profile resize = %q", cmd)
+	}
+	if cmd := p.GuestResize(annotations, 40, 132); cmd != "stty rows 40 cols 132\n" {
+		t.Fatalf("overridden resize = %q", cmd)
+	}
+	if cmd := p.GuestResize(map[string]string{defs.ContainerResizeCommand: ""}, 40, 132); cmd != "" {
+		t.Fatalf("disabled resize = %q", cmd)
+	}
+}
+
+func TestControls(t *testing.T) {
+	c, err := Lookup("zephyr").Controls(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := c.Signal(syscall.SIGINT); !ok || string(a.Input) != "\x03" {
+		t.Fatalf("zephyr SIGINT = %+v, %v", a, ok)
+	}
+	if a, ok := c.Signal(syscall.SIGHUP); !ok || a.Kind != SignalCommand || string(a.Console()) != "kernel reboot cold\n" {
+		t.Fatalf("zephyr SIGHUP = %+v, %v", a, ok)
+	}
+	// zephyr has no shutdown command, SIGTERM powers the client off from the host
+	if a, ok := c.Signal(syscall.SIGTERM); !ok || a.Kind != SignalShutdown || a.Console() != nil || a.Grace != DefaultStopTimeout {
+		t.Fatalf("zephyr SIGTERM = %+v, %v", a, ok)
+	}
+	if a, ok := c.Signal(syscall.SIGQUIT); !ok || a.Kind != SignalCrashDump {
+		t.Fatalf("zephyr SIGQUIT = %+v, %v", a, ok)
+	}
+	if _, ok := c.Signal(syscall.SIGUSR2); ok {
+		t.Fatal("zephyr SIGUSR2 should not be supported")
+	}
+
+	c, _ = Lookup("linux").Controls(map[string]string{defs.ContainerStopTimeout: "3"})
+	if a, _ := c.Signal(syscall.SIGTERM); a.Kind != SignalShutdown || a.Command != "poweroff" || a.Grace != 3*time.Second {
+		t.Fatalf("linux SIGTERM = %+v", a)
+	}
+	// without shell ^C has nothing to interrupt
+	c, _ = Lookup("baremetal").Controls(nil)
+	if a, ok := c.Signal(syscall.SIGINT); !ok || a.Kind != SignalStop || c.Keys[0x03].Kind != KeySignal {
+		t.Fatalf("baremetal SIGINT = %+v, keys %v", a, c.Keys)
+	}
+
+	c, err = Lookup("zephyr").Controls(map[string]string{
+		defs.ContainerConsoleKeys: `^C=SIGINT,^\=drop,~k=SIGKILL,0x04=detach`,
+		defs.ContainerSignals:     "SIGINT=^C,TERM=ignore,SIGUSR1=stop,SIGUSR2=cmd:kernel uptime",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Keys[0x03] != (KeyAction{Kind: KeySignal, Signal: syscall.SIGINT}) || c.Keys[0x1c].Kind != KeyDrop ||
+		c.Keys[0x04].Kind != KeyDetach || c.Escapes["~k"].Signal != syscall.SIGKILL || c.Escapes["~."].Kind != KeyDetach {
+		t.Fatalf("keys %v, escapes %v", c.Keys, c.Escapes)
+	}
+	if a, _ := c.Signal(syscall.SIGTERM); a.Kind != SignalIgnore {
+		t.Fatalf("SIGTERM = %+v", a)
+	}
+	if a, _ := c.Signal(syscall.SIGUSR1); a.Kind != SignalStop {
+		t.Fatalf("SIGUSR1 = %+v", a)
+	}
+	if a, _ := c.Signal(syscall.SIGUSR2); string(a.Console()) != "kernel uptime\n" {
+		t.Fatalf("SIGUSR2 = %+v", a)
+	}
+	if a, _ := c.Signal(syscall.SIGKILL); a.Kind != SignalStop {
+		t.Fatalf("SIGKILL = %+v", a)
+	}
+
+	for _, bad := range []map[string]string{
+		{defs.ContainerConsoleKeys: "^C"},
+		{defs.ContainerConsoleKeys: "^C=explode"},
+		{defs.ContainerConsoleKeys: "x=drop"},
+		{defs.ContainerSignals: "SIGKILL=ignore"},
+		{defs.ContainerSignals: "SIGNOPE=stop"},
+		{defs.ContainerSignals: "SIGTERM=^"},
+		{defs.ContainerSignals: "SIGUSR2=cmd:"},
+		{defs.ContainerStopTimeout: "soon"},
+	} {
+		if _, err := Lookup("zephyr").Controls(bad); err == nil {
+			t.Fatalf("Controls(%v) should fail", bad)
+		}
+	}
+}
+
+func TestInputFilter(t *testing.T) {
+	c, _ := Lookup("zephyr").Controls(nil)
+	f := c.NewInputFilter()
+
+	// ^C is forwarded to the shell
+	if out, _, hit := f.Filter([]byte("sleep 10\r\x03")); hit || string(out) != "sleep 10\r\x03" {
+		t.Fatalf("Filter() = %q, %v", out, hit)
+	}
+	// ~ in the middle of a line is plain input
+	if out, _, hit := f.Filter([]byte("ls ~.\r")); hit || string(out) != "ls ~.\r" {
+		t.Fatalf("Filter() = %q, %v", out, hit)
+	}
+	// ~ at line start is held until the escape is known, across reads
+	if out, _, hit := f.Filter([]byte("~")); hit || len(out) != 0 {
+		t.Fatalf("Filter() = %q, %v", out, hit)
+	}
+	if out, _, hit := f.Filter([]byte("x\r")); hit || string(out) != "~x\r" {
+		t.Fatalf("Filter() = %q, %v", out, hit)
+	}
+	if out, act, hit := f.Filter([]byte("~.ignored")); !hit || act.Kind != KeyDetach || len(out) != 0 {
+		t.Fatalf("Filter() = %q, %+v, %v", out, act, hit)
+	}
+	if out, act, hit := c.NewInputFilter().Filter([]byte("abc\x1cdef")); !hit || act.Signal != syscall.SIGKILL || string(out) != "abc" {
+		t.Fatalf("Filter() = %q, %+v, %v", out, act, hit)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	r, err := Lookup("zephyr").Readiness(nil)
+	if err != nil || r.Source != ReadyNone {
+		t.Fatalf("default readiness = %+v, %v", r, err)
+	}
+
+	r, err = Lookup("zephyr").Readiness(map[string]string{defs.ContainerReady: "console"})
+	if err != nil || !r.Pattern.MatchString("*** Booting Zephyr OS build v3.5.0 ***") || !r.Fail || r.Timeout != DefaultReadyTimeout {
+		t.Fatalf("zephyr readiness = %+v, %v", r, err)
+	}
+	// uniproton has no banner, its shell prompt tells it booted
+	r, err = Lookup("uniproton").Readiness(map[string]string{defs.ContainerReady: "console"})
+	if err != nil || !r.Pattern.MatchString("UniProton # ") {
+		t.Fatalf("uniproton readiness = %+v, %v", r, err)
+	}
+
+	r, err = Lookup("baremetal").Readiness(map[string]string{
+		defs.ContainerReady:              "console",
+		defs.ContainerReadyPattern:       `app started`,
+		defs.ContainerReadyTimeout:       "5",
+		defs.ContainerReadyTimeoutAction: "mark",
+	})
+	if err != nil || !r.Pattern.MatchString("[0.01] app started") || r.Fail || r.Timeout != 5*time.Second {
+		t.Fatalf("annotated readiness = %+v, %v", r, err)
+	}
+
+	for _, bad := range []map[string]string{
+		{defs.ContainerReady: "ping"},
+		{defs.ContainerReady: "console", defs.ContainerReadyPattern: "("},
+		{defs.ContainerReady: "rpmsg", defs.ContainerReadyTimeout: "0"},
+		{defs.ContainerReady: "xenstore", defs.ContainerReadyTimeoutAction: "retry"},
+	} {
+		if _, err := Lookup("zephyr").Readiness(bad); err == nil {
+			t.Fatalf("Readiness(%v) should fail", bad)
+		}
+	}
+	// without banner nor shell a pattern is required
+	if _, err := Lookup("baremetal").Readiness(map[string]string{defs.ContainerReady: "console"}); err == nil {
+		t.Fatal("baremetal console readiness needs a pattern")
+	}
+}
+
+func TestExitPatterns(t *testing.T) {
+	if e, err := ParseExitPatterns(nil); e != nil || err != nil {
+		t.Fatalf("no exit patterns = %+v, %v", e, err)
+	}
+
+	e, err := ParseExitPatterns(map[string]string{
+		defs.ContainerExitSuccess: `PROJECT EXECUTION SUCCESSFUL`,
+		defs.ContainerExitFailure: `PROJECT EXECUTION FAILED|exit status (?P<code>[0-9]+)`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range map[string]int{
+		"PROJECT EXECUTION SUCCESSFUL": 0,
+		"PROJECT EXECUTION FAILED":     DefaultFailureCode,
+		"test: exit status 3":          3,
+	} {
+		if code, ok := e.Match(line); !ok || code != want {
+			t.Fatalf("Match(%q) = %d, %v, want %d", line, code, ok, want)
+		}
+	}
+	if _, ok := e.Match("Running TESTSUITE kernel_common"); ok {
+		t.Fatal("a plain line should not match")
+	}
+
+	e, _ = ParseExitPatterns(map[string]string{
+		defs.ContainerExitSuccess:   `done \(([0-9]+)\)`,
+		defs.ContainerExitCodeGroup: "1",
+	})
+	if code, ok := e.Match("done (4)"); !ok || code != 4 {
+		t.Fatalf("numbered group = %d, %v", code, ok)
+	}
+
+	if _, err := ParseExitPatterns(map[string]string{defs.ContainerExitFailure: "("}); err == nil {
+		t.Fatal("invalid regex should fail")
+	}
+}
@@ -0,0 +1,84 @@
+package osprofile
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	defs "micrun/definitions"
+)
+
+// DefaultReadyTimeout bounds the boot of a client whose readiness is detected.
+const DefaultReadyTimeout = 30 * time.Second
+
+// ReadySource is how a booted client is detected.
+type ReadySource string
+
+const (
+	// ReadyNone: the client counts as ready once micad started it.
+	ReadyNone ReadySource = ""
+	// ReadyConsole waits for the console output to match a pattern, e.g. the boot banner.
+	ReadyConsole ReadySource = "console"
+	// ReadyRPMsg waits for the client to announce its rpmsg endpoints to micad.
+	ReadyRPMsg ReadySource = "rpmsg"
+	// ReadyXenstore waits for xen to report the domain running.
+	ReadyXenstore ReadySource = "xenstore"
+)
+
+// Readiness tells how to detect that a client booted.
+type Readiness struct {
+	Source ReadySource
+	// Pattern matches the console output of a booted client, for ReadyConsole.
+	Pattern *regexp.Regexp
+	Timeout time.Duration
+	// Fail fails the start of a client which is not ready in time, otherwise the
+	// start returns at once and the task exits as failed after the timeout.
+	Fail bool
+}
+
+// Readiness returns the readiness detection requested by the annotations, the
+// console pattern defaults to the boot banner of the OS, or to its shell prompt.
+func (p Profile) Readiness(annotations map[string]string) (Readiness, error) {
+	r := Readiness{Timeout: DefaultReadyTimeout, Fail: true}
+	switch source := ReadySource(annotations[defs.ContainerReady]); source {
+	case ReadyNone:
+		return r, nil
+	case ReadyConsole, ReadyRPMsg, ReadyXenstore:
+		r.Source = source
+	default:
+		return r, fmt.Errorf("invalid %s %q: want console, rpmsg or xenstore", defs.ContainerReady, source)
+	}
+
+	if r.Source == ReadyConsole {
+		r.Pattern = p.ReadyPattern
+		if r.Pattern == nil && p.Shell != nil {
+			r.Pattern = p.Shell.Prompt
+		}
+		if v := annotations[defs.ContainerReadyPattern]; v != "" {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return r, fmt.Errorf("invalid %s %q: %w", defs.ContainerReadyPattern, v, err)
+			}
+			r.Pattern = re
+		}
+		if r.Pattern == nil {
+			return r, fmt.Errorf("client os %q has no boot banner, %s is required", p.Name, defs.ContainerReadyPattern)
+		}
+	}
+	if v := annotations[defs.ContainerReadyTimeout]; v != "" {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds <= 0 {
+			return r, fmt.Errorf("invalid %s %q: want a positive number of seconds", defs.ContainerReadyTimeout, v)
+		}
+		r.Timeout = time.Duration(seconds) * time.Second
+	}
+	switch v := annotations[defs.ContainerReadyTimeoutAction]; v {
+	case "", "fail":
+	case "mark":
+		r.Fail = false
+	default:
+		return r, fmt.Errorf("invalid %s %q: want fail or mark", defs.ContainerReadyTimeoutAction, v)
+	}
+	return r, nil
+}
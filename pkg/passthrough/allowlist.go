@@ -0,0 +1,138 @@
+package passthrough
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "micrun/logger"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Allowlist maps a host device name to the resources it owns. It is loaded from
+// the file named by device_allowlist in micrun.conf, one device per line:
+//
+//	# name   resources
+//	can0     iomem=fe200,1  irqs=42  dtdev=/soc/can@fe200000
+//	gpio1    iomem=fe20d,1  irqs=45
+//
+// The name matches the base name of OCI device paths, e.g. /dev/can0, and the
+// kernel name of the device numbers in OCI device rules.
+type Allowlist map[string]Assignment
+
+// sysDevRoot is where the kernel names device numbers, replaced by tests.
+var sysDevRoot = "/sys/dev"
+
+// LoadAllowlist parses an allowlist file.
+func LoadAllowlist(path string) (Allowlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open device allowlist: %w", err)
+	}
+	defer f.Close()
+
+	al := make(Allowlist)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		a, err := parseFields(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		al[fields[0]] = a
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read device allowlist: %w", err)
+	}
+	return al, nil
+}
+
+// Resolve maps OCI devices to allowlisted host devices. linux.devices entries are
+// matched by name, allowed linux.resources.devices rules by device number. Devices
+// unknown to the allowlist are Linux devices (e.g. /dev/null) and are skipped.
+func (al Allowlist) Resolve(devices []specs.LinuxDevice, rules []specs.LinuxDeviceCgroup) Assignment {
+	var out Assignment
+	for _, dev := range devices {
+		if a, ok := al[filepath.Base(dev.Path)]; ok {
+			out = out.Merge(a)
+		}
+	}
+	for _, rule := range rules {
+		if !rule.Allow || rule.Major == nil || rule.Minor == nil {
+			continue
+		}
+		name, err := deviceName(rule.Type, *rule.Major, *rule.Minor)
+		if err != nil {
+			log.Debugf("device %d:%d is unknown to the kernel: %v", *rule.Major, *rule.Minor, err)
+			continue
+		}
+		if a, ok := al[name]; ok {
+			out = out.Merge(a)
+		}
+	}
+	return out
+}
+
+// Permits checks that every resource of a is owned by an allowlisted device.
+func (al Allowlist) Permits(a Assignment) error {
+	var allowed Assignment
+	for _, entry := range al {
+		allowed = allowed.Merge(entry)
+	}
+	for _, r := range a.IOMem {
+		ok := false
+		for _, x := range allowed.IOMem {
+			if r.within(x) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("iomem %s is not in the device allowlist", r)
+		}
+	}
+	for _, irq := range a.IRQs {
+		if _, hit := (Assignment{IRQs: []uint32{irq}}).Conflict(allowed); !hit {
+			return fmt.Errorf("irq %d is not in the device allowlist", irq)
+		}
+	}
+	for _, dev := range a.DTDevs {
+		if _, hit := (Assignment{DTDevs: []string{dev}}).Conflict(allowed); !hit {
+			return fmt.Errorf("dtdev %s is not in the device allowlist", dev)
+		}
+	}
+	return nil
+}
+
+// deviceName is the kernel name of a device number, read from the DEVNAME of its
+// uevent, so that it does not depend on where the device node is created.
+func deviceName(typ string, major, minor int64) (string, error) {
+	class := "char"
+	if typ == "b" {
+		class = "block"
+	}
+	f, err := os.Open(filepath.Join(sysDevRoot, class, fmt.Sprintf("%d:%d", major, minor), "uevent"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "DEVNAME="); ok {
+			return filepath.Base(name), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no DEVNAME in uevent")
+}
@@ -0,0 +1,224 @@
+// Package passthrough maps host devices (io memory regions, interrupts and
+// device tree nodes) to RTOS clients.
+//
+// Devices come from OCI linux.devices / linux.resources.devices entries matched
+// against the host allowlist, or from the container iomem/irqs/dtdev annotations.
+// Every assignment must be covered by the allowlist, and a device can only be
+// claimed by one client on the host at a time.
+package passthrough
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IOMemRange is a range of io memory in page frames, as used by xl iomem.
+type IOMemRange struct {
+	StartPFN uint64 `json:"start_pfn"`
+	Pages    uint64 `json:"pages"`
+}
+
+// String renders the range in xl format: "<hex start pfn>,<hex pages>".
+func (r IOMemRange) String() string {
+	return fmt.Sprintf("%x,%x", r.StartPFN, r.Pages)
+}
+
+func (r IOMemRange) end() uint64 {
+	return r.StartPFN + r.Pages
+}
+
+func (r IOMemRange) overlaps(o IOMemRange) bool {
+	return r.StartPFN < o.end() && o.StartPFN < r.end()
+}
+
+func (r IOMemRange) within(o IOMemRange) bool {
+	return r.StartPFN >= o.StartPFN && r.end() <= o.end()
+}
+
+// Assignment is the set of host resources handed to a single client.
+type Assignment struct {
+	IOMem  []IOMemRange `json:"iomem,omitempty"`
+	IRQs   []uint32     `json:"irqs,omitempty"`
+	DTDevs []string     `json:"dtdev,omitempty"`
+}
+
+// Empty reports whether nothing is passed through.
+func (a Assignment) Empty() bool {
+	return len(a.IOMem) == 0 && len(a.IRQs) == 0 && len(a.DTDevs) == 0
+}
+
+// Merge returns the union of both assignments, without duplicates.
+func (a Assignment) Merge(o Assignment) Assignment {
+	out := Assignment{}
+	seenMem := map[IOMemRange]bool{}
+	for _, r := range append(append([]IOMemRange{}, a.IOMem...), o.IOMem...) {
+		if !seenMem[r] {
+			seenMem[r] = true
+			out.IOMem = append(out.IOMem, r)
+		}
+	}
+	seenIRQ := map[uint32]bool{}
+	for _, irq := range append(append([]uint32{}, a.IRQs...), o.IRQs...) {
+		if !seenIRQ[irq] {
+			seenIRQ[irq] = true
+			out.IRQs = append(out.IRQs, irq)
+		}
+	}
+	seenDev := map[string]bool{}
+	for _, dev := range append(append([]string{}, a.DTDevs...), o.DTDevs...) {
+		if !seenDev[dev] {
+			seenDev[dev] = true
+			out.DTDevs = append(out.DTDevs, dev)
+		}
+	}
+	sort.Slice(out.IOMem, func(i, j int) bool { return out.IOMem[i].StartPFN < out.IOMem[j].StartPFN })
+	sort.Slice(out.IRQs, func(i, j int) bool { return out.IRQs[i] < out.IRQs[j] })
+	sort.Strings(out.DTDevs)
+	return out
+}
+
+// Conflict returns a description of the first resource used by both assignments.
+func (a Assignment) Conflict(o Assignment) (string, bool) {
+	for _, r := range a.IOMem {
+		for _, x := range o.IOMem {
+			if r.overlaps(x) {
+				return "iomem " + r.String(), true
+			}
+		}
+	}
+	for _, irq := range a.IRQs {
+		for _, x := range o.IRQs {
+			if irq == x {
+				return "irq " + strconv.FormatUint(uint64(irq), 10), true
+			}
+		}
+	}
+	for _, dev := range a.DTDevs {
+		for _, x := range o.DTDevs {
+			if dev == x {
+				return "dtdev " + dev, true
+			}
+		}
+	}
+	return "", false
+}
+
+// IOMemConf renders the io memory of the assignment for the iomem field of the
+// micad create message, the iomem list of the client domain: "fe200,1;fe20d,1".
+// The create message has no field for irqs and device tree nodes.
+func (a Assignment) IOMemConf() string {
+	ranges := make([]string, 0, len(a.IOMem))
+	for _, r := range a.IOMem {
+		ranges = append(ranges, r.String())
+	}
+	return strings.Join(ranges, ";")
+}
+
+// Encode renders the assignment in the format of the allowlist and of the
+// annotations written by micrun-device-plugin:
+// "iomem=fe200,1;fe20d,1 irqs=42,45 dtdev=/soc/can@fe200000", sections are omitted when empty.
+func (a Assignment) Encode() string {
+	var sections []string
+	if len(a.IOMem) > 0 {
+		sections = append(sections, "iomem="+a.IOMemConf())
+	}
+	if len(a.IRQs) > 0 {
+		irqs := make([]string, 0, len(a.IRQs))
+		for _, irq := range a.IRQs {
+			irqs = append(irqs, strconv.FormatUint(uint64(irq), 10))
+		}
+		sections = append(sections, "irqs="+strings.Join(irqs, ","))
+	}
+	if len(a.DTDevs) > 0 {
+		sections = append(sections, "dtdev="+strings.Join(a.DTDevs, ","))
+	}
+	return strings.Join(sections, " ")
+}
+
+// Decode is the reverse of Encode.
+func Decode(s string) (Assignment, error) {
+	return parseFields(strings.Fields(s))
+}
+
+// Parse builds an assignment from the iomem, irqs and dtdev annotation values.
+func Parse(iomem, irqs, dtdev string) (Assignment, error) {
+	var a Assignment
+	for _, field := range splitList(iomem, ";") {
+		r, err := parseIOMem(field)
+		if err != nil {
+			return Assignment{}, err
+		}
+		a.IOMem = append(a.IOMem, r)
+	}
+	for _, field := range splitList(irqs, ",") {
+		irq, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return Assignment{}, fmt.Errorf("invalid irq %q: %w", field, err)
+		}
+		a.IRQs = append(a.IRQs, uint32(irq))
+	}
+	for _, field := range splitList(dtdev, ",") {
+		if !strings.HasPrefix(field, "/") {
+			return Assignment{}, fmt.Errorf("invalid dtdev %q: must be an absolute device tree path", field)
+		}
+		a.DTDevs = append(a.DTDevs, field)
+	}
+	return a.Merge(Assignment{}), nil
+}
+
+// parseFields parses "iomem=.. irqs=.. dtdev=.." fields.
+func parseFields(fields []string) (Assignment, error) {
+	var iomem, irqs, dtdev string
+	for _, kv := range fields {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return Assignment{}, fmt.Errorf("invalid field %q", kv)
+		}
+		switch key {
+		case "iomem":
+			iomem = value
+		case "irqs":
+			irqs = value
+		case "dtdev":
+			dtdev = value
+		default:
+			return Assignment{}, fmt.Errorf("unknown resource %q", key)
+		}
+	}
+	return Parse(iomem, irqs, dtdev)
+}
+
+// parseIOMem parses "<hex start pfn>,<hex pages>", "0x" prefixes are accepted.
+func parseIOMem(field string) (IOMemRange, error) {
+	start, pages, ok := strings.Cut(field, ",")
+	if !ok {
+		return IOMemRange{}, fmt.Errorf("invalid iomem %q: want <start_pfn>,<pages>", field)
+	}
+	s, err := parseHex(start)
+	if err != nil {
+		return IOMemRange{}, fmt.Errorf("invalid iomem start %q: %w", start, err)
+	}
+	n, err := parseHex(pages)
+	if err != nil || n == 0 {
+		return IOMemRange{}, fmt.Errorf("invalid iomem page number %q", pages)
+	}
+	return IOMemRange{StartPFN: s, Pages: n}, nil
+}
+
+func parseHex(v string) (uint64, error) {
+	v = strings.TrimSpace(v)
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "0x"), "0X")
+	return strconv.ParseUint(v, 16, 64)
+}
+
+func splitList(v, sep string) []string {
+	var out []string
+	for _, field := range strings.Split(v, sep) {
+		if field = strings.TrimSpace(field); field != "" {
+			out = append(out, field)
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package passthrough
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func writeAllowlist(t *testing.T, content string) Allowlist {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "devices.allow")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	al, err := LoadAllowlist(path)
+	if err != nil {
+		t.Fatalf("LoadAllowlist: %v", err)
+	}
+	return al
+}
+
+func TestParseAndEncode(t *testing.T) {
+	a, err := Parse("0xfe20d,1; fe200,2", "45,42,42", "/soc/can@fe200000")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := "iomem=fe200,2;fe20d,1 irqs=42,45 dtdev=/soc/can@fe200000"
+	if got := a.Encode(); got != want {
+		t.Fatalf("Encode() = %q, want %q", got, want)
+	}
+	decoded, err := Decode(want)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := decoded.Encode(); got != want {
+		t.Fatalf("Decode(Encode()) = %q, want %q", got, want)
+	}
+	if got := a.IOMemConf(); got != "fe200,2;fe20d,1" {
+		t.Fatalf("IOMemConf() = %q", got)
+	}
+
+	for _, bad := range [][3]string{
+		{"fe200", "", ""},
+		{"fe200,0", "", ""},
+		{"", "irq", ""},
+		{"", "", "soc/can"},
+	} {
+		if _, err := Parse(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("Parse(%q, %q, %q) should fail", bad[0], bad[1], bad[2])
+		}
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	al := writeAllowlist(t, `
+# name   resources
+can0     iomem=fe200,4  irqs=42  dtdev=/soc/can@fe200000
+gpio1    iomem=fe20d,1  irqs=45
+`)
+
+	resolved := al.Resolve([]specs.LinuxDevice{{Path: "/dev/can0"}, {Path: "/dev/null"}}, nil)
+	if got := resolved.Encode(); got != "iomem=fe200,4 irqs=42 dtdev=/soc/can@fe200000" {
+		t.Fatalf("Resolve() = %q", got)
+	}
+
+	sysDevRoot = t.TempDir()
+	t.Cleanup(func() { sysDevRoot = "/sys/dev" })
+	uevent := filepath.Join(sysDevRoot, "char", "240:1", "uevent")
+	if err := os.MkdirAll(filepath.Dir(uevent), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(uevent, []byte("MAJOR=240\nMINOR=1\nDEVNAME=gpio1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	major, minor, unknown := int64(240), int64(1), int64(2)
+	resolved = al.Resolve(nil, []specs.LinuxDeviceCgroup{
+		{Allow: true, Type: "c", Major: &major, Minor: &minor},
+		{Allow: true, Type: "c", Major: &major, Minor: &unknown},
+	})
+	if got := resolved.Encode(); got != "iomem=fe20d,1 irqs=45" {
+		t.Fatalf("Resolve() of device rules = %q", got)
+	}
+
+	ok, _ := Parse("fe201,2", "45", "")
+	if err := al.Permits(ok); err != nil {
+		t.Fatalf("Permits() rejected a subset of the allowlist: %v", err)
+	}
+	for _, bad := range []Assignment{
+		{IOMem: []IOMemRange{{StartPFN: 0xfe203, Pages: 2}}},
+		{IRQs: []uint32{7}},
+		{DTDevs: []string{"/soc/timer"}},
+	} {
+		if err := al.Permits(bad); err == nil {
+			t.Errorf("Permits(%s) should fail", bad.Encode())
+		}
+	}
+}
+
+func TestRegistryRejectsOverlap(t *testing.T) {
+	state := filepath.Join(t.TempDir(), claimsFileName)
+	r := NewRegistry(state)
+
+	first, _ := Parse("fe200,4", "42", "")
+	second, _ := Parse("fe203,1", "", "")
+	third, _ := Parse("fe20d,1", "45", "")
+
+	if err := r.Claim("pod-a", first); err != nil {
+		t.Fatalf("Claim pod-a: %v", err)
+	}
+	err := r.Claim("pod-b", second)
+	if err == nil || !strings.Contains(err.Error(), "pod-a") {
+		t.Fatalf("Claim pod-b should conflict with pod-a, got %v", err)
+	}
+	if err := r.Claim("pod-b", third); err != nil {
+		t.Fatalf("Claim pod-b: %v", err)
+	}
+
+	if err := r.Release("pod-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Claim("pod-c", second); err != nil {
+		t.Fatalf("Claim after release: %v", err)
+	}
+	if err := r.Release("pod-b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release("pod-c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(state); !os.IsNotExist(err) {
+		t.Fatalf("claims file should be removed when empty, stat err = %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package passthrough
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	defs "micrun/definitions"
+	"micrun/pkg/store"
+)
+
+const claimsFileName = "passthrough.json"
+
+// Registry records which client owns which host device. Shims of different pods
+// share it through a state file guarded by flock(2).
+type Registry struct {
+	stateFile string
+}
+
+var defaultRegistry = NewRegistry(filepath.Join(defs.MicrunStateDir, claimsFileName))
+
+// NewRegistry returns a registry persisted in stateFile.
+func NewRegistry(stateFile string) *Registry {
+	return &Registry{stateFile: stateFile}
+}
+
+// Claim reserves a for owner on the host registry.
+func Claim(owner string, a Assignment) error {
+	return defaultRegistry.Claim(owner, a)
+}
+
+// Release frees the devices of owner on the host registry.
+func Release(owner string) error {
+	return defaultRegistry.Release(owner)
+}
+
+// Claim reserves a for owner, failing if any resource is already claimed by another owner.
+// Claiming again for the same owner replaces its previous assignment.
+func (r *Registry) Claim(owner string, a Assignment) error {
+	if owner == "" {
+		return fmt.Errorf("passthrough: empty owner")
+	}
+	if a.Empty() {
+		return nil
+	}
+	return r.withClaims(func(claims map[string]Assignment) error {
+		for other, claimed := range claims {
+			if other == owner {
+				continue
+			}
+			if what, ok := a.Conflict(claimed); ok {
+				return fmt.Errorf("passthrough: %s is already assigned to %s", what, other)
+			}
+		}
+		claims[owner] = a
+		return nil
+	})
+}
+
+// Release drops the claim of owner, it is a no-op for unknown owners.
+func (r *Registry) Release(owner string) error {
+	if _, err := os.Stat(r.stateFile); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return r.withClaims(func(claims map[string]Assignment) error {
+		delete(claims, owner)
+		return nil
+	})
+}
+
+// withClaims runs fn on the claims under an exclusive lock and persists the result.
+// The state file is removed once nothing is claimed anymore.
+func (r *Registry) withClaims(fn func(claims map[string]Assignment) error) error {
+	claims := make(map[string]Assignment)
+	return store.UpdateRecord(r.stateFile, &claims, func() (bool, error) {
+		if claims == nil {
+			claims = make(map[string]Assignment)
+		}
+		if err := fn(claims); err != nil {
+			return false, err
+		}
+		return len(claims) > 0, nil
+	})
+}
@@ -0,0 +1,60 @@
+package pedestal
+
+import (
+	"errors"
+	"testing"
+
+	er "micrun/errors"
+)
+
+func TestCheckVCPUBinding(t *testing.T) {
+	cpus := []int{2, 3}
+	tests := []struct {
+		name    string
+		entries []VCPUEntry
+		wantErr bool
+	}{
+		{
+			name: "bound one by one",
+			entries: []VCPUEntry{
+				{VCPUID: 0, HardAffinity: "2", SoftAffinity: "2"},
+				{VCPUID: 1, HardAffinity: "3", SoftAffinity: "3"},
+			},
+		},
+		{
+			name: "floating in the whole set",
+			entries: []VCPUEntry{
+				{VCPUID: 0, HardAffinity: "2-3", SoftAffinity: "all"},
+				{VCPUID: 1, HardAffinity: "2-3", SoftAffinity: "all"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "soft affinity differs",
+			entries: []VCPUEntry{
+				{VCPUID: 0, HardAffinity: "2", SoftAffinity: "all"},
+				{VCPUID: 1, HardAffinity: "3", SoftAffinity: "3"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "vcpu number mismatch",
+			entries: []VCPUEntry{
+				{VCPUID: 0, HardAffinity: "2", SoftAffinity: "2"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVCPUBinding(tt.entries, cpus)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkVCPUBinding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, er.ContainerVCPUNotPined) {
+				t.Fatalf("error should wrap ContainerVCPUNotPined, got %v", err)
+			}
+		})
+	}
+}
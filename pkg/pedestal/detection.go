@@ -97,18 +97,33 @@ func detectACRN() bool {
 	return false
 }
 
-const hpsupport = false
-
-// for xen, if ballooning driver was enable, hugepage is not supported
-func HugePageSupport(dynamicMem bool) bool {
-	if dynamicMem || GetHostPed() != Xen {
+// HugePageSupport reports whether a static memory layout can be used for clients
+// when it is requested.
+// Only xen can honour it: a domain created with memory == maxmem is populated at
+// build time, which xen does with superpages where the host memory allows it.
+// The xl config of the domain is written by micad, its create message carries
+// no superpage setting, so the superpages are not guaranteed.
+// If ballooning driver was enable, hugepage is not supported: the balloon
+// driver breaks the contiguous allocation up at runtime.
+// Other pedestals would need a reserved-memory carve-out, which micad can not
+// be asked for, static memory is not supported there.
+func HugePageSupport(requested bool) bool {
+	if !requested {
 		return false
 	}
 
-	if ConflictKoLoaded, err := utils.KoLoaded(balloonDriverName); err != nil && hpsupport {
-		return !ConflictKoLoaded
+	switch GetHostPed() {
+	case Xen:
+		loaded, err := utils.KoLoaded(balloonDriverName)
+		if err != nil {
+			log.Debugf("failed to check %s, hugepage is not supported: %v", balloonDriverName, err)
+			return false
+		}
+		if loaded {
+			log.Warnf("%s is loaded, hugepage backed static memory is not supported", balloonDriverName)
+		}
+		return !loaded
+	default:
+		return false
 	}
-
-	// default: not support
-	return false
 }
@@ -194,6 +194,13 @@ const (
 	memset      xlSubCmd = "mem-set"
 	memmax      xlSubCmd = "mem-max"
 	schedcredit xlSubCmd = "sched-credit2"
+	dumpcore    xlSubCmd = "dump-core"
+	shutdown    xlSubCmd = "shutdown"
+	reboot      xlSubCmd = "reboot"
+	list        xlSubCmd = "list"
+	destroy     xlSubCmd = "destroy"
+	save        xlSubCmd = "save"
+	restore     xlSubCmd = "restore"
 )
 
 func newxl(subcmd xlSubCmd, args ...string) *exec.Cmd {
@@ -591,6 +598,132 @@ func Resume(id string) error {
 	return nil
 }
 
+// Shutdown asks the guest of domain id to power off, it does not wait for the domain to go away
+func Shutdown(id string) error {
+	var stderr bytes.Buffer
+	cmd := newxl(shutdown, id)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("xl failed to shutdown %s: %v: %s", id, err, strings.TrimSpace(stderr.String()))
+	}
+	log.Debugf("shutdown %s requested", id)
+	return nil
+}
+
+// Reboot restarts the guest of domain id, the domain keeps its cpupool, pinning and memory
+func Reboot(id string) error {
+	var stderr bytes.Buffer
+	cmd := newxl(reboot, id)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("xl failed to reboot %s: %v: %s", id, err, strings.TrimSpace(stderr.String()))
+	}
+	log.Debugf("reboot %s successfully", id)
+	return nil
+}
+
+// DumpCore writes the memory image of domain id to path
+func DumpCore(id, path string) error {
+	var stderr bytes.Buffer
+	cmd := newxl(dumpcore, id, path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("xl failed to dump core of %s: %v: %s", id, err, strings.TrimSpace(stderr.String()))
+	}
+	log.Debugf("dump core of %s to %s successfully", id, path)
+	return nil
+}
+
+// Domains returns the names of the guest domains, Domain-0 is left out
+func Domains() ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := newxl(list)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("xl list failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseXlDomains(stdout.String()), nil
+}
+
+func parseXlDomains(out string) []string {
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] == "Name" || fields[1] == "0" {
+			continue
+		}
+		names = append(names, fields[0])
+	}
+	return names
+}
+
+// DomainMemMB returns the memory of domain id in MiB, as shown by xl list
+func DomainMemMB(id string) (uint32, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := newxl(list, id)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("xl list %s failed: %v: %s", id, err, strings.TrimSpace(stderr.String()))
+	}
+	scanner := bufio.NewScanner(strings.NewReader(stdout.String()))
+	for scanner.Scan() {
+		// Name ID Mem VCPUs State Time(s)
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != id {
+			continue
+		}
+		mem, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("xl list returned invalid memory %q for %s: %w", fields[2], id, err)
+		}
+		return uint32(mem), nil
+	}
+	return 0, fmt.Errorf("domain %s not found in xl list output", id)
+}
+
+// Destroy kills domain id at once, the guest is given no chance to shut down
+func Destroy(id string) error {
+	var stderr bytes.Buffer
+	cmd := newxl(destroy, id)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("xl failed to destroy %s: %v: %s", id, err, strings.TrimSpace(stderr.String()))
+	}
+	log.Debugf("destroy %s successfully", id)
+	return nil
+}
+
+// Save writes the state of domain id to path, the domain keeps running when keep is set
+func Save(id, path string, keep bool) error {
+	var args []string
+	if keep {
+		args = append(args, "-c")
+	}
+	var stderr bytes.Buffer
+	cmd := newxl(save, append(args, id, path)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("xl failed to save %s to %s: %v: %s", id, path, err, strings.TrimSpace(stderr.String()))
+	}
+	log.Debugf("save %s to %s successfully", id, path)
+	return nil
+}
+
+// Restore re-creates the domain saved to path, under its saved name and config
+func Restore(path string) error {
+	var stderr bytes.Buffer
+	cmd := newxl(restore, path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("xl failed to restore %s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	log.Debugf("restore %s successfully", path)
+	return nil
+}
+
 func Pause(id string) error {
 	cmd := newxl(pause, id)
 	if err := cmd.Run(); err != nil {
@@ -615,6 +748,54 @@ func PinVCPU(clientID, cpus string) error {
 	return nil
 }
 
+// BindVCPUs pins vCPU N of the client to the Nth cpu of cpus, with the same cpu as
+// soft affinity, and verifies the placement by reading back xl vcpu-list.
+// Unlike PinVCPU, no vCPU is allowed to float inside the set.
+func BindVCPUs(clientID string, cpus []int) error {
+	if len(cpus) == 0 {
+		return fmt.Errorf("no cpu to bind vcpus of %s", clientID)
+	}
+	for vcpu, cpu := range cpus {
+		pcpu := strconv.Itoa(cpu)
+		cmd := newxl(vcpupin, clientID, strconv.Itoa(vcpu), pcpu, pcpu)
+		log.Debugf("run %s to bind vcpu %d of %s to pcpu %d", cmd.String(), vcpu, clientID, cpu)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("xl failed to bind vcpu %d of %s to cpu %d: %v: %s", vcpu, clientID, cpu, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return VerifyVCPUBinding(clientID, cpus)
+}
+
+// VerifyVCPUBinding checks that vCPU N of the client is bound to the Nth cpu of cpus only.
+func VerifyVCPUBinding(clientID string, cpus []int) error {
+	info, err := xlvcpu()
+	if err != nil {
+		return err
+	}
+	return checkVCPUBinding(info.DomainVCPUMap[clientID], cpus)
+}
+
+func checkVCPUBinding(entries []VCPUEntry, cpus []int) error {
+	if len(entries) != len(cpus) {
+		return fmt.Errorf("%w: found %d vcpus, want %d", er.ContainerVCPUNotPined, len(entries), len(cpus))
+	}
+	for _, e := range entries {
+		if e.VCPUID < 0 || e.VCPUID >= len(cpus) {
+			return fmt.Errorf("%w: unexpected vcpu %d", er.ContainerVCPUNotPined, e.VCPUID)
+		}
+		want := cpuset.NewCPUSet(cpus[e.VCPUID])
+		hard, err := cpuset.Parse(strings.TrimSpace(e.HardAffinity))
+		if err != nil || !hard.Equals(want) {
+			return fmt.Errorf("%w: vcpu %d hard affinity is %q, want %s", er.ContainerVCPUNotPined, e.VCPUID, e.HardAffinity, want.String())
+		}
+		soft, err := cpuset.Parse(strings.TrimSpace(e.SoftAffinity))
+		if err != nil || !soft.Equals(want) {
+			return fmt.Errorf("%w: vcpu %d soft affinity is %q, want %s", er.ContainerVCPUNotPined, e.VCPUID, e.SoftAffinity, want.String())
+		}
+	}
+	return nil
+}
+
 func MemLowThreshold() uint32 {
 	return 2
 }
@@ -0,0 +1,38 @@
+package shim
+
+import (
+	log "micrun/logger"
+
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	runcoptions "github.com/containerd/containerd/api/types/runc/options"
+	"github.com/containerd/containerd/runtime/linux/runctypes"
+	"github.com/containerd/typeurl/v2"
+)
+
+// checkpointOptions tells whether the container exits after the checkpoint and
+// where the checkpoint is written. ctr passes the runc options of the v1 shims
+// to runtimes other than runc, both versions are understood.
+func checkpointOptions(r *taskAPI.CheckpointTaskRequest) (exit bool, dir string) {
+	dir = r.Path
+	if r.Options == nil {
+		return false, dir
+	}
+	v, err := typeurl.UnmarshalAny(r.Options)
+	if err != nil {
+		log.Warnf("checkpoint options of %s ignored: %v", r.ID, err)
+		return false, dir
+	}
+	var imagePath string
+	switch opts := v.(type) {
+	case *runcoptions.CheckpointOptions:
+		exit, imagePath = opts.Exit, opts.ImagePath
+	case *runctypes.CheckpointOptions:
+		exit, imagePath = opts.Exit, opts.ImagePath
+	default:
+		log.Debugf("checkpoint options %T of %s ignored", v, r.ID)
+	}
+	if imagePath != "" {
+		dir = imagePath
+	}
+	return exit, dir
+}
@@ -135,11 +135,14 @@ func setupContainer(ctx context.Context, s *shimService, containerType cntr.Cont
 func createSandboxContainer(ctx context.Context, s *shimService, containerType cntr.ContainerType,
 	r *taskAPI.CreateTaskRequest, ociSpec *specs.Spec, runtimeConfig *oci.RuntimeConfig,
 	bundlePath, rootfsPath string, disableOutput bool, rootfs *cntr.RootFs) (err error) {
+	// Create does not hold the lock over the whole creation, it is taken for the shared state only
+	s.mu.Lock()
 	if s.sandbox != nil {
+		s.mu.Unlock()
 		return fmt.Errorf("cannot create an existing sandbox: %s", s.sandbox.SandboxID())
 	}
-
 	s.config = runtimeConfig
+	s.mu.Unlock()
 
 	if containerType != cntr.PodSandbox {
 		log.Debug("rootfs mounted for single container, showing rootfs contents:")
@@ -165,19 +168,25 @@ func createSandboxContainer(ctx context.Context, s *shimService, containerType c
 	}
 
 	var sandbox cntr.SandboxTraits
-	sandbox, err = createSandbox(ctx, ociSpec, runtimeConfig, *rootfs, r.ID, bundlePath, disableOutput)
+	sandbox, err = createSandbox(ctx, ociSpec, runtimeConfig, *rootfs, r.ID, bundlePath, r.Checkpoint, disableOutput)
 	if err != nil {
 		return err
 	}
 
+	s.mu.Lock()
 	s.sandbox = sandbox
+	s.mu.Unlock()
+	s.startOrphanCheck()
 	return nil
 }
 
 func createPodContainer(ctx context.Context, s *shimService, r *taskAPI.CreateTaskRequest,
 	ociSpec *specs.Spec, bundlePath, rootfsPath string,
 	disableOutput bool, rootfs *cntr.RootFs) (err error) {
-	if s.sandbox == nil {
+	s.mu.Lock()
+	sandbox, runtimeConfig := s.sandbox, s.config
+	s.mu.Unlock()
+	if sandbox == nil {
 		return fmt.Errorf("cannot start the pod container, since the sandbox is not created")
 	}
 
@@ -196,7 +205,7 @@ func createPodContainer(ctx context.Context, s *shimService, r *taskAPI.CreateTa
 
 	log.Debug("rootfs mounted for pod container, showing rootfs contents: ")
 
-	return createPodContainerInSandbox(ctx, s.sandbox, *ociSpec, *rootfs, r.ID, bundlePath, s.config, disableOutput)
+	return createPodContainerInSandbox(ctx, sandbox, *ociSpec, *rootfs, r.ID, bundlePath, r.Checkpoint, runtimeConfig, disableOutput)
 }
 
 // mountRootfs mounts the container's root filesystem.
@@ -216,7 +225,7 @@ func mountRootfs(rootfsPath string, rootfs []*types.Mount) error {
 // TODO: if ped=xen, cpupool is great to use
 func createPodContainerInSandbox(ctx context.Context, sandbox cntr.SandboxTraits,
 	ocispec specs.Spec, rootfs cntr.RootFs,
-	containerID, bundlePath string, runtimeConfig *oci.RuntimeConfig, disableOutput bool) error {
+	containerID, bundlePath, checkpoint string, runtimeConfig *oci.RuntimeConfig, disableOutput bool) error {
 
 	var defaultFirmware string
 	if sandbox != nil {
@@ -231,6 +240,7 @@ func createPodContainerInSandbox(ctx context.Context, sandbox cntr.SandboxTraits
 	}
 
 	containerConfig.Rootfs = rootfs
+	containerConfig.Checkpoint = checkpoint
 
 	if err := validateFirmwareForContainer(containerConfig); err != nil {
 		return fmt.Errorf("firmware validation failed for container %s: %w", containerID, err)
@@ -247,7 +257,7 @@ func createPodContainerInSandbox(ctx context.Context, sandbox cntr.SandboxTraits
 // createSandbox initializes and creates a new sandbox instance.
 func createSandbox(ctx context.Context, ocispec *specs.Spec,
 	runtimeConfig *oci.RuntimeConfig, rootfs cntr.RootFs,
-	containerId, bundle string, disableOutput bool) (_ cntr.SandboxTraits, err error) {
+	containerId, bundle, checkpoint string, disableOutput bool) (_ cntr.SandboxTraits, err error) {
 
 	sandboxConfig, err := oci.SandboxConfig(ocispec, *runtimeConfig, bundle, containerId, disableOutput)
 	if err != nil {
@@ -264,6 +274,10 @@ func createSandbox(ctx context.Context, ocispec *specs.Spec,
 		}
 		sandboxConfig.ContainerConfigs[containerId].Rootfs = rootfs
 	}
+	if cc, ok := sandboxConfig.ContainerConfigs[containerId]; ok {
+		// the client is restored from the checkpoint at start instead of booted
+		cc.Checkpoint = checkpoint
+	}
 
 	if err := setupNetNS(sandboxConfig.ID, &sandboxConfig.NetworkConfig); err != nil {
 		return nil, err
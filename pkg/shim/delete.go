@@ -23,7 +23,9 @@ func deleteContainer(ctx context.Context, s *shimService, c *shimContainer) erro
 			log.Debugf("Sandbox already deleted, skipping StopContainer/DeleteContainer for %s", c.id)
 		} else {
 			if c.status != task.Status_STOPPED {
-				if _, err := s.sandbox.StopContainer(ctx, c.id, false); err != nil && err == er.ContainerNotFound {
+				// Kill already gave the client its grace period, a graceful stop would
+				// wait for it again with s.mu held.
+				if _, err := s.sandbox.StopContainer(ctx, c.id, true); err != nil && err == er.ContainerNotFound {
 					log.Debugf("Container %s not found in real sandbox, already deleted.", c.id)
 				} else if err != nil {
 					return err
@@ -37,6 +39,12 @@ func deleteContainer(ctx context.Context, s *shimService, c *shimContainer) erro
 		}
 	}
 
+	// Stop the container IO: flush and close log files, tear down logging binaries.
+	if c.ttyio != nil {
+		c.ttyio.close()
+		c.ttyio = nil
+	}
+
 	if c.mounted {
 		innerRootfs := filepath.Join(c.bundle, "rootfs")
 		if err := mount.UnmountAll(innerRootfs, 0); err != nil {
@@ -45,6 +53,9 @@ func deleteContainer(ctx context.Context, s *shimService, c *shimContainer) erro
 		c.mounted = false
 	}
 
+	if !c.cType.CanBeSandbox() {
+		c.deleteTask()
+	}
 	delete(s.containers, c.id)
 
 	return nil
@@ -0,0 +1,176 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+
+	er "micrun/errors"
+	log "micrun/logger"
+
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/typeurl/v2"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// execNotSupportedCode is reported when the client OS has no shell, as a shell does for non executable commands.
+const execNotSupportedCode = 126
+
+// execProcess is an exec emulated by running a command in the client shell.
+// The RTOS has no process for it, the shim pid stands in.
+type execProcess struct {
+	id       string
+	spec     *specs.Process
+	stdin    string
+	stdout   string
+	stderr   string
+	terminal bool
+	ttyio    *ttyIO
+	status   task.Status
+	exit     uint32
+	exitTime time.Time
+	cancel   context.CancelFunc
+	exited   chan struct{}
+	// signal is the signal which canceled the command, if any.
+	signal syscall.Signal
+}
+
+func newExecProcess(r *taskAPI.ExecProcessRequest) (*execProcess, error) {
+	if r.Spec == nil {
+		return nil, errdefs.ToGRPCf(errdefs.ErrInvalidArgument, "exec %s has no process spec", r.ExecID)
+	}
+	v, err := typeurl.UnmarshalAny(r.Spec)
+	if err != nil {
+		return nil, errdefs.ToGRPCf(errdefs.ErrInvalidArgument, "invalid process spec of exec %s: %v", r.ExecID, err)
+	}
+	spec, ok := v.(*specs.Process)
+	if !ok || len(spec.Args) == 0 {
+		return nil, errdefs.ToGRPCf(errdefs.ErrInvalidArgument, "exec %s has no command", r.ExecID)
+	}
+
+	return &execProcess{
+		id:       r.ExecID,
+		spec:     spec,
+		stdin:    r.Stdin,
+		stdout:   r.Stdout,
+		stderr:   r.Stderr,
+		terminal: r.Terminal,
+		status:   task.Status_CREATED,
+		exited:   make(chan struct{}),
+	}, nil
+}
+
+// startExec opens the exec stdio and runs the command in background, the exit
+// is reported like the one of a container task. Called with s.mu held.
+func startExec(ctx context.Context, s *shimService, c *shimContainer, e *execProcess) error {
+	if e.status != task.Status_CREATED {
+		return errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "exec %s of container %s already started", e.id, c.id)
+	}
+	if c.status != task.Status_RUNNING {
+		return errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "container %s is not running", c.id)
+	}
+	sandbox := s.sandbox
+	if sandbox == nil {
+		return er.SandboxNotFound
+	}
+	// the sandbox is only touched here under s.mu, the command itself runs
+	// on the console of the client without the lock. A failure is reported
+	// as the exit of the exec, like a failure of the command.
+	run, err := sandbox.ExecContainer(ctx, c.id, e.spec.Args)
+	if err != nil {
+		run = func(context.Context, io.Writer) (int, error) { return -1, err }
+	}
+
+	stdout, stderr := io.Discard, io.Discard
+	if e.stdin != "" || e.stdout != "" || e.stderr != "" {
+		ioCtx := ctx
+		if _, ok := namespaces.Namespace(ctx); !ok && s.namespace != "" {
+			ioCtx = namespaces.WithNamespace(ctx, s.namespace)
+		}
+		tty, err := newTtyIO(ioCtx, c.id, e.stdin, e.stdout, e.stderr, e.terminal)
+		if err != nil {
+			return err
+		}
+		e.ttyio = tty
+		if w := tty.io.Stdout(); w != nil {
+			stdout, stderr = w, w
+		}
+		if w := tty.io.Stderr(); w != nil && !e.terminal {
+			stderr = w
+		}
+	}
+
+	// the exec outlives the Start request
+	execCtx, cancel := context.WithCancel(s.ctx)
+	e.cancel = cancel
+	e.status = task.Status_RUNNING
+
+	go func() {
+		defer cancel()
+		code, err := run(execCtx, stdout)
+		ts := time.Now()
+		s.mu.Lock()
+		if err != nil {
+			switch {
+			case e.signal != 0 && errors.Is(err, context.Canceled):
+				code = 128 + int(e.signal)
+			case errors.Is(err, er.NotSupported):
+				code = execNotSupportedCode
+			default:
+				code = exitCode
+			}
+			log.Warnf("exec %s in container %s failed: %v", e.id, c.id, err)
+			fmt.Fprintf(stderr, "micrun: %v\n", err)
+		}
+		e.status = task.Status_STOPPED
+		e.exit = uint32(code)
+		e.exitTime = ts
+		if e.ttyio != nil {
+			e.ttyio.close()
+			e.ttyio = nil
+		}
+		close(e.exited)
+		s.mu.Unlock()
+
+		s.ec <- exitEvent{
+			ts:     ts,
+			cid:    c.id,
+			execid: e.id,
+			pid:    shimPid,
+			status: code,
+		}
+	}()
+
+	return nil
+}
+
+// kill cancels the command, the shell can not deliver signals so any of them ends the exec.
+// Called with s.mu held.
+func (e *execProcess) kill(sig syscall.Signal) {
+	if e.status != task.Status_RUNNING || e.cancel == nil {
+		return
+	}
+	e.signal = sig
+	e.cancel()
+}
+
+// cancelExecs stops the commands still running in the client shell.
+func (c *shimContainer) cancelExecs() {
+	for _, e := range c.execs {
+		e.kill(syscall.SIGKILL)
+	}
+}
+
+func (c *shimContainer) getExec(id string) (*execProcess, error) {
+	e, ok := c.execs[id]
+	if !ok || e == nil {
+		return nil, errdefs.ToGRPCf(errdefs.ErrNotFound, "exec %s of container %s not found", id, c.id)
+	}
+	return e, nil
+}
@@ -0,0 +1,41 @@
+package shim
+
+import (
+	"time"
+
+	log "micrun/logger"
+	"micrun/pkg/gc"
+)
+
+// startOrphanCheck reports the orphans of the host to the shim log every
+// gc_interval, removing them is left to `micrun gc`. It runs until the shim exits.
+func (s *shimService) startOrphanCheck() {
+	if s.config == nil || s.config.GCInterval <= 0 {
+		return
+	}
+	s.gcOnce.Do(func() {
+		go s.checkOrphans(s.config.GCInterval)
+	})
+}
+
+func (s *shimService) checkOrphans(interval time.Duration) {
+	address := containerdAddress()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		host, err := gc.Scan(s.ctx, address)
+		if err != nil {
+			log.Debugf("orphan check of sandbox %s skipped: %v", s.id, err)
+			continue
+		}
+		for _, o := range host.Orphans(time.Now(), gc.DefaultMinAge) {
+			log.Warnf("orphan %s found on the host, run `micrun gc` to remove it", o)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	log "micrun/logger"
+	cntr "micrun/pkg/micantainer"
 
 	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
 )
@@ -28,3 +29,67 @@ func requestContainerKill(ctx context.Context, s *shimService, c *shimContainer,
 		c.ioExit()
 	}
 }
+
+// stopOutcome tells whether a stopped client powered off by itself or was destroyed.
+type stopOutcome int
+
+const (
+	// stopNone: the container was not stopped through Kill.
+	stopNone stopOutcome = iota
+	stopGraceful
+	stopForced
+	// stopExited: the client went down by itself and the restart policy gave up.
+	stopExited
+)
+
+func (o stopOutcome) String() string {
+	switch o {
+	case stopGraceful:
+		return "graceful"
+	case stopForced:
+		return "forced"
+	case stopExited:
+		return "exited"
+	}
+	return "none"
+}
+
+// exitReason is the reason of the exit kept in the container record.
+func (o stopOutcome) exitReason() string {
+	switch o {
+	case stopGraceful:
+		return cntr.ExitGraceful
+	case stopForced:
+		return cntr.ExitForced
+	}
+	return cntr.ExitExited
+}
+
+// exitStatus is reported in the TaskExit event: a destroyed client exits as a
+// process killed by SIGKILL, a client which powered off exits successfully.
+func (c *shimContainer) exitStatus() int {
+	switch c.stopOutcome {
+	case stopForced:
+		return 128 + int(syscall.SIGKILL)
+	case stopExited:
+		return c.clientExit
+	}
+	return 0
+}
+
+// finishShutdown is the second phase of a graceful stop: the container is
+// destroyed once the client powered off, or when the grace period elapsed.
+func finishShutdown(s *shimService, c *shimContainer, grace time.Duration) {
+	log.Debugf("container %s shutting down, destroyed after %v at the latest", c.id, grace)
+	graceful := cntr.WaitClientDown(s.ctx, s.clientOf(c.id), grace)
+
+	s.mu.Lock()
+	if graceful && c.stopOutcome == stopNone {
+		c.stopOutcome = stopGraceful
+	}
+	c.shuttingDown = false
+	s.mu.Unlock()
+
+	// Kill returns early when the container was killed meanwhile
+	requestContainerKill(s.ctx, s, c, syscall.SIGKILL, "shutdown")
+}
@@ -0,0 +1,57 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	defs "micrun/definitions"
+	log "micrun/logger"
+	"micrun/pkg/gc"
+
+	containersapi "github.com/containerd/containerd/api/services/containers/v1"
+	"github.com/containerd/containerd/namespaces"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// labelTimeout bounds the update of a container label in containerd.
+const labelTimeout = 5 * time.Second
+
+// containerdAddress is where containerd serves its grpc api, containerd tells the shim.
+func containerdAddress() string {
+	if address := os.Getenv("GRPC_ADDRESS"); address != "" {
+		return address
+	}
+	return gc.DefaultAddress
+}
+
+// labelRestartCount publishes the restart count of container id as a label of
+// its containerd container, so that it shows up in the container info.
+func (s *shimService) labelRestartCount(id string, count int) {
+	if err := s.setContainerLabel(id, defs.ContainerRestartCount, strconv.Itoa(count)); err != nil {
+		log.Debugf("restart count of container %s not published: %v", id, err)
+	}
+}
+
+func (s *shimService) setContainerLabel(id, key, value string) error {
+	ctx, cancel := context.WithTimeout(s.ctx, labelTimeout)
+	defer cancel()
+	address := containerdAddress()
+	conn, err := grpc.DialContext(ctx, "unix://"+address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("failed to connect to containerd at %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	_, err = containersapi.NewContainersClient(conn).Update(namespaces.WithNamespace(ctx, s.namespace),
+		&containersapi.UpdateContainerRequest{
+			Container:  &containersapi.Container{ID: id, Labels: map[string]string{key: value}},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels." + key}},
+		})
+	return err
+}
@@ -0,0 +1,140 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	defs "micrun/definitions"
+	log "micrun/logger"
+)
+
+// reopenCheckInterval limits how often logFile checks whether its path was moved away.
+const reopenCheckInterval = time.Second
+
+// logFile is the append-only console log behind file:// IO.
+// With maxSize set it rotates by size: path -> path.1 -> ... -> path.<maxFiles>.
+// When the file is renamed or removed externally (e.g. logrotate), it reopens the path.
+type logFile struct {
+	mu        sync.Mutex
+	path      string
+	maxSize   int64
+	maxFiles  int
+	f         *os.File
+	size      int64
+	lastCheck time.Time
+	closed    bool
+}
+
+func openLogFile(path string, maxSize int64, maxFiles int) (*logFile, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("log file path %q must be absolute", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), defs.DirMode); err != nil {
+		return nil, fmt.Errorf("failed to create log dir: %w", err)
+	}
+	if maxSize > 0 && maxFiles < 1 {
+		maxFiles = 1
+	}
+	l := &logFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *logFile) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defs.FileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = st.Size()
+	l.lastCheck = time.Now()
+	return nil
+}
+
+func (l *logFile) reopen() error {
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+	return l.open()
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, os.ErrClosed
+	}
+
+	if time.Since(l.lastCheck) >= reopenCheckInterval {
+		l.lastCheck = time.Now()
+		if l.moved() {
+			log.Debugf("log file %s was moved, reopening", l.path)
+			if err := l.reopen(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// moved reports whether the open file is no longer the one at l.path.
+func (l *logFile) moved() bool {
+	cur, err := l.f.Stat()
+	if err != nil {
+		return true
+	}
+	onDisk, err := os.Stat(l.path)
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(cur, onDisk)
+}
+
+func (l *logFile) rotate() error {
+	l.f.Close()
+	l.f = nil
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", l.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", l.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("failed to rotate %s: %v", from, err)
+		}
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("failed to rotate %s: %v", l.path, err)
+	}
+	return l.open()
+}
+
+// Close is idempotent, later writes fail with os.ErrClosed.
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,119 @@
+package shim
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestFileIORotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "console.log")
+	uri, err := url.Parse("file://" + path + "?max_size=8&max_files=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fio, err := newFileIO(context.Background(), &stdioInfo{}, uri)
+	if err != nil {
+		t.Fatalf("newFileIO: %v", err)
+	}
+	if fio.Stdin() != nil {
+		t.Fatal("stdin should be nil without stdin option")
+	}
+
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := fio.Stdout().Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fio.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fio.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if _, err := fio.Stdout().Write([]byte("x")); err == nil {
+		t.Fatal("write after close should fail")
+	}
+
+	want := map[string]string{
+		path:        "dddddd\n",
+		path + ".1": "cccccc\n",
+		path + ".2": "bbbbbb\n",
+	}
+	for p, content := range want {
+		if got := readFile(t, p); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("only max_files rotated logs should be kept, stat err = %v", err)
+	}
+}
+
+func TestLogFileReopensMovedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	l, err := openLogFile(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if _, err := l.Write([]byte("old\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	l.lastCheck = time.Time{}
+	if _, err := l.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "new\n" {
+		t.Fatalf("reopened log = %q, want new line only", got)
+	}
+	if got := readFile(t, path+".moved"); got != "old\n" {
+		t.Fatalf("moved log = %q", got)
+	}
+}
+
+func TestFileIOStdinFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	if err := os.WriteFile(input, []byte("help\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri, err := url.Parse("file://" + filepath.Join(dir, "out.log") + "?stdin=" + url.QueryEscape(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fio, err := newFileIO(context.Background(), &stdioInfo{}, uri)
+	if err != nil {
+		t.Fatalf("newFileIO: %v", err)
+	}
+	defer fio.Close()
+	got, err := io.ReadAll(fio.Stdin())
+	if err != nil || string(got) != "help\n" {
+		t.Fatalf("stdin = %q, %v", got, err)
+	}
+
+	for _, bad := range []string{"?max_size=abc", "?max_files=0"} {
+		uri, _ := url.Parse("file://" + filepath.Join(dir, "bad.log") + bad)
+		if _, err := newFileIO(context.Background(), &stdioInfo{}, uri); err == nil {
+			t.Errorf("newFileIO(%s) should fail", bad)
+		}
+	}
+}
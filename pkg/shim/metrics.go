@@ -253,6 +253,15 @@ func statsToMetricsV1(stats *cntr.ContainerStats) *cgroupsv1.Metrics {
 		TotalPgMajFault: memStats.Stats["pgmajfault"],
 	}
 
+	// static memory clients are fully backed by hugepages
+	if memStats.Static {
+		m.Hugetlb = []*cgroupsv1.HugetlbStat{{
+			Usage:    memStats.Usage.Usage,
+			Max:      memStats.Usage.Limit,
+			Pagesize: memStats.HugePageSize,
+		}}
+	}
+
 	return m
 }
 
@@ -268,6 +277,13 @@ func statsToMetricsV2(stats *cntr.ContainerStats) *cgroupsv2.Metrics {
 	if stats != nil && stats.ResourceStats != nil {
 		m.CPU = setMetricsCPUStats(&stats.ResourceStats.CPUStats)
 		m.Memory = setMetricsMemStats(&stats.ResourceStats.MemoryStats)
+		if ms := stats.ResourceStats.MemoryStats; ms.Static {
+			m.Hugetlb = []*cgroupsv2.HugeTlbStat{{
+				Current:  ms.Usage.Usage,
+				Max:      ms.Usage.Limit,
+				Pagesize: ms.HugePageSize,
+			}}
+		}
 	}
 
 	return m
@@ -0,0 +1,72 @@
+package shim
+
+import (
+	"syscall"
+
+	log "micrun/logger"
+)
+
+// awaitReady waits for the boot of a container whose start fails when it is not
+// ready in time. The lock is released meanwhile so that Kill and State are
+// served during the boot, a failed container is killed. Called with s.mu held.
+func awaitReady(s *shimService, c *shimContainer) error {
+	ready := c.ready
+	if ready == nil {
+		return nil
+	}
+	c.ready = nil
+	s.mu.Unlock()
+	err := <-ready
+	s.mu.Lock()
+	if err == nil {
+		log.Infof("container %s is ready", c.id)
+		return nil
+	}
+	log.Errorf("container %s failed: %v", c.id, err)
+	if c.restartable() {
+		c.clientGone(true)
+		// the kill takes the lock
+		go requestContainerKill(s.ctx, s, c, syscall.SIGKILL, "not-ready")
+	}
+	return err
+}
+
+// watchReadiness marks the task as failed when its client did not boot in
+// time, for containers whose start returned without waiting for the boot.
+func watchReadiness(s *shimService, c *shimContainer, ready <-chan error, exitIOch chan struct{}) {
+	select {
+	case err := <-ready:
+		if err == nil {
+			log.Infof("container %s is ready", c.id)
+			return
+		}
+		s.mu.Lock()
+		if !c.restartable() {
+			s.mu.Unlock()
+			return
+		}
+		c.clientGone(true)
+		s.mu.Unlock()
+		log.Errorf("container %s failed: %v", c.id, err)
+		requestContainerKill(s.ctx, s, c, syscall.SIGKILL, "not-ready")
+	case <-exitIOch:
+	}
+}
+
+// watchExitStatus ends the task with the exit status printed on the client
+// console, which turns test firmware idling after its verdict into a batch job.
+func watchExitStatus(s *shimService, c *shimContainer, exited <-chan int, exitIOch chan struct{}) {
+	select {
+	case code := <-exited:
+		s.mu.Lock()
+		if !c.restartable() {
+			s.mu.Unlock()
+			return
+		}
+		c.stopOutcome = stopExited
+		c.clientExit = code
+		s.mu.Unlock()
+		requestContainerKill(s.ctx, s, c, syscall.SIGKILL, "exit-pattern")
+	case <-exitIOch:
+	}
+}
@@ -0,0 +1,185 @@
+package shim
+
+import (
+	"time"
+
+	er "micrun/errors"
+	log "micrun/logger"
+	cntr "micrun/pkg/micantainer"
+	oci "micrun/pkg/oci"
+	"micrun/pkg/store"
+
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/containerd/api/types/task"
+)
+
+// taskRecord keeps what containerd told the shim about a task, which the
+// container records do not know, so that a restarted shim serves it again.
+type taskRecord struct {
+	ID       string             `json:"id"`
+	Bundle   string             `json:"bundle"`
+	Type     cntr.ContainerType `json:"type"`
+	Stdin    string             `json:"stdin,omitempty"`
+	Stdout   string             `json:"stdout,omitempty"`
+	Stderr   string             `json:"stderr,omitempty"`
+	Terminal bool               `json:"terminal,omitempty"`
+	Pid      uint32             `json:"pid"`
+	Mounted  bool               `json:"mounted,omitempty"`
+	// Exited is set once the exit of the task was reported.
+	Exited   bool      `json:"exited,omitempty"`
+	Exit     uint32    `json:"exit,omitempty"`
+	ExitedAt time.Time `json:"exited_at,omitempty"`
+}
+
+// saveTask persists the task record of the container, best effort. Called
+// with s.mu held or before the container is shared.
+func (c *shimContainer) saveTask() {
+	if c.s == nil || c.s.sandbox == nil {
+		return
+	}
+	rec := taskRecord{
+		ID:       c.id,
+		Bundle:   c.bundle,
+		Type:     c.cType,
+		Stdin:    c.stdin,
+		Stdout:   c.stdout,
+		Stderr:   c.stderr,
+		Terminal: c.terminal,
+		Pid:      c.pid,
+		Mounted:  c.mounted,
+		Exited:   c.status == task.Status_STOPPED,
+		Exit:     c.exit,
+		ExitedAt: c.exitTime,
+	}
+	if err := store.Default().SaveTask(c.s.sandbox.SandboxID(), c.id, &rec); err != nil {
+		log.Warnf("task %s is not recoverable after a shim restart: %v", c.id, err)
+	}
+}
+
+// deleteTask removes the task record of the container, best effort.
+func (c *shimContainer) deleteTask() {
+	if c.s == nil || c.s.sandbox == nil {
+		return
+	}
+	if err := store.Default().DeleteTask(c.s.sandbox.SandboxID(), c.id); err != nil {
+		log.Warnf("failed to remove task record of %s: %v", c.id, err)
+	}
+}
+
+// loadSandbox loads the records of a sandbox, replaced in tests.
+var loadSandbox = cntr.LoadSandbox
+
+// recoverOnServe recovers the sandbox when the shim serves the task API.
+// containerd starts a new shim when the previous one died, its clients may
+// still run. The short-lived `start` and `delete` actions of the shim binary
+// must leave the console PTY and the records to the serving shim.
+func (s *shimService) recoverOnServe(action string) {
+	if action != "" {
+		log.Debugf("shim %s of sandbox %s does not recover it", action, s.id)
+		return
+	}
+	if err := s.recover(); err != nil {
+		log.Errorf("failed to recover sandbox %s: %v", s.id, err)
+	}
+}
+
+// recover rebuilds the service of a shim restarted by containerd: the sandbox
+// and its containers are loaded from the store and reconciled with micad, the
+// stdio of the tasks still running is reopened and their exit is watched again.
+// A shim started for a new sandbox finds no record and starts empty.
+func (s *shimService) recover() error {
+	sandbox, err := loadSandbox(s.ctx, s.id)
+	if err == er.SandboxNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("recovering sandbox %s after a shim restart", s.id)
+	s.sandbox = sandbox
+
+	for _, ct := range sandbox.GetAllContainers() {
+		var rec taskRecord
+		if err := store.Default().LoadTask(s.id, ct.ID(), &rec); err != nil {
+			log.Warnf("container %s of sandbox %s is not recovered: %v", ct.ID(), s.id, err)
+			continue
+		}
+		c, err := s.recoverTask(&rec)
+		if err != nil {
+			log.Warnf("container %s of sandbox %s is not recovered: %v", ct.ID(), s.id, err)
+			continue
+		}
+		s.mu.Lock()
+		s.containers[c.id] = c
+		s.resumeTask(c, ct.Status())
+		s.mu.Unlock()
+	}
+	s.startOrphanCheck()
+	return nil
+}
+
+// recoverTask rebuilds the shim container of a task record.
+func (s *shimService) recoverTask(rec *taskRecord) (*shimContainer, error) {
+	spec, err := oci.LoadSpec(rec.Bundle)
+	if err != nil {
+		return nil, err
+	}
+	// the config of the first task is the config of the shim, as at create
+	if _, err := loadRuntimeConfig(s, &taskAPI.CreateTaskRequest{ID: rec.ID, Bundle: rec.Bundle}, spec.Annotations); err != nil {
+		return nil, err
+	}
+
+	c, err := newContainer(s, &taskAPI.CreateTaskRequest{
+		ID:       rec.ID,
+		Bundle:   rec.Bundle,
+		Stdin:    rec.Stdin,
+		Stdout:   rec.Stdout,
+		Stderr:   rec.Stderr,
+		Terminal: rec.Terminal,
+	}, rec.Type, &spec, rec.Mounted)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Pid > 0 {
+		c.pid = rec.Pid
+	}
+	if rec.Exited {
+		c.status = task.Status_STOPPED
+		c.exit = rec.Exit
+		c.exitTime = rec.ExitedAt
+		c.ioExit()
+	}
+	return c, nil
+}
+
+// resumeTask brings the task back to the state of its client. Called with s.mu held.
+func (s *shimService) resumeTask(c *shimContainer, state cntr.StateString) {
+	if c.status == task.Status_STOPPED {
+		// the exit was reported before the shim went away
+		return
+	}
+	switch state {
+	case cntr.StateRunning, cntr.StatePaused:
+		if err := attachContainer(s.ctx, s, c); err != nil {
+			log.Warnf("failed to reattach task %s: %v", c.id, err)
+		}
+		if state == cntr.StatePaused {
+			c.status = task.Status_PAUSED
+		}
+		log.Infof("task %s recovered, %s", c.id, c.status)
+	case cntr.StateStopped, cntr.StateDown:
+		// the client went down while nobody watched it, its exit status is unknown
+		ts := time.Now()
+		c.status = task.Status_STOPPED
+		c.exit = exitCode
+		c.exitTime = ts
+		c.ioExit()
+		c.saveTask()
+		log.Infof("task %s exited while the shim was gone", c.id)
+		go func() {
+			s.ec <- exitEvent{ts: ts, cid: c.id, pid: shimPid, status: exitCode}
+		}()
+	default:
+		c.status = task.Status_CREATED
+	}
+}
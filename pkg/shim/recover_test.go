@@ -0,0 +1,36 @@
+package shim
+
+import (
+	"context"
+	"testing"
+
+	er "micrun/errors"
+	cntr "micrun/pkg/micantainer"
+)
+
+func TestRecoverOnServe(t *testing.T) {
+	defer func(load func(context.Context, string) (cntr.SandboxTraits, error)) { loadSandbox = load }(loadSandbox)
+
+	for _, tc := range []struct {
+		action string
+		load   bool
+	}{
+		{action: "delete"},
+		{action: "start"},
+		{action: "", load: true},
+	} {
+		loaded := false
+		loadSandbox = func(ctx context.Context, id string) (cntr.SandboxTraits, error) {
+			loaded = true
+			return nil, er.SandboxNotFound
+		}
+		s := &shimService{id: "sb", ctx: context.Background(), containers: make(map[string]*shimContainer)}
+		s.recoverOnServe(tc.action)
+		if loaded != tc.load {
+			t.Errorf("action %q: sandbox loaded %v, want %v", tc.action, loaded, tc.load)
+		}
+		if s.sandbox != nil || len(s.containers) != 0 {
+			t.Errorf("action %q: recovered %d containers", tc.action, len(s.containers))
+		}
+	}
+}
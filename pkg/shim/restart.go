@@ -0,0 +1,115 @@
+package shim
+
+import (
+	"syscall"
+	"time"
+
+	log "micrun/logger"
+	cntr "micrun/pkg/micantainer"
+
+	"github.com/containerd/containerd/api/types/task"
+)
+
+// clientWatchInterval is how often the liveness watcher polls a running client.
+const clientWatchInterval = time.Second
+
+// watchClient is the liveness watcher of a container with a restart policy: a
+// client which went down by itself is restarted in place as long as the policy
+// allows, the exit is reported once the policy gives up.
+func watchClient(s *shimService, c *shimContainer, exitIOch chan struct{}) {
+	ticker := time.NewTicker(clientWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exitIOch:
+			return
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		exited, failed := cntr.ClientExited(s.clientOf(c.id))
+		if !exited {
+			continue
+		}
+
+		s.mu.Lock()
+		if !c.restartable() {
+			// a stop was requested, Kill reports the exit
+			s.mu.Unlock()
+			return
+		}
+		restarts := s.restartCount(c.id)
+		delay, restart := c.restartPolicy.Next(failed, restarts)
+		if !restart {
+			c.clientGone(failed)
+			s.mu.Unlock()
+			log.Infof("container %s exited (failed: %v), not restarted after %d restarts", c.id, failed, restarts)
+			requestContainerKill(s.ctx, s, c, syscall.SIGKILL, "client-exited")
+			return
+		}
+		if s.sandbox != nil {
+			code := okCode
+			if failed {
+				code = exitCode
+			}
+			if err := s.sandbox.RecordContainerExit(c.id, cntr.ExitExited, code); err != nil {
+				log.Debugf("exit of container %s not recorded: %v", c.id, err)
+			}
+		}
+		s.mu.Unlock()
+
+		log.Infof("container %s exited (failed: %v), restart %d in %v", c.id, failed, restarts+1, delay)
+		select {
+		case <-time.After(delay):
+		case <-exitIOch:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+
+		s.mu.Lock()
+		if !c.restartable() || s.sandbox == nil {
+			s.mu.Unlock()
+			return
+		}
+		err := s.sandbox.RestartContainer(s.ctx, c.id)
+		if err != nil {
+			c.clientGone(true)
+		} else {
+			// a restart has no caller to fail, a client not ready in time is marked as failed
+			if ready, _ := s.sandbox.ContainerReadiness(c.id); ready != nil {
+				go watchReadiness(s, c, ready, exitIOch)
+			}
+			go s.labelRestartCount(c.id, s.restartCount(c.id))
+		}
+		s.mu.Unlock()
+		if err != nil {
+			log.Errorf("failed to restart container %s: %v", c.id, err)
+			requestContainerKill(s.ctx, s, c, syscall.SIGKILL, "restart-failed")
+			return
+		}
+	}
+}
+
+// restartCount is the number of restarts in place kept in the container record,
+// the restart policy counts it too. Called with s.mu held.
+func (s *shimService) restartCount(id string) int {
+	if s.sandbox == nil {
+		return 0
+	}
+	return s.sandbox.ContainerRestartCount(id)
+}
+
+// restartable tells whether the container runs with no stop requested. Called with s.mu held.
+func (c *shimContainer) restartable() bool {
+	return c.status == task.Status_RUNNING && !c.shuttingDown && c.stopOutcome == stopNone
+}
+
+// clientGone records the exit of a client which went down by itself. Called with s.mu held.
+func (c *shimContainer) clientGone(failed bool) {
+	c.stopOutcome = stopExited
+	c.clientExit = okCode
+	if failed {
+		c.clientExit = exitCode
+	}
+}
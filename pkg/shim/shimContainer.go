@@ -6,6 +6,7 @@ import (
 	defs "micrun/definitions"
 	log "micrun/logger"
 	cntr "micrun/pkg/micantainer"
+	"micrun/pkg/osprofile"
 	"sync"
 	"syscall"
 	"time"
@@ -37,8 +38,20 @@ type shimContainer struct {
 	pid         uint32 // shim pid
 	exitTime    time.Time
 	mounted     bool
-	// TODO: we can simulate `exec` by sending commands to mica pty
-	// execs map[string]*execTask // extensible in future
+	// execs are emulated by sending commands to the client shell, see exec.go
+	execs map[string]*execProcess
+	// controls translates stdin keys and signals, nil until the container is started
+	controls *osprofile.Controls
+	// shuttingDown is set while the client is given the grace period to power off
+	shuttingDown bool
+	// stopOutcome tells how the client was stopped, reported in the exit status
+	stopOutcome stopOutcome
+	// clientExit is the exit status of a client which went down by itself
+	clientExit int
+	// restartPolicy is applied by the liveness watcher
+	restartPolicy cntr.RestartPolicy
+	// ready is the readiness of a boot which fails the start, waited for by Start
+	ready <-chan error
 }
 
 // newContainer creates a new container object for the shim.
@@ -66,11 +79,30 @@ func newContainer(s *shimService, r *taskAPI.CreateTaskRequest, cType cntr.Conta
 		terminal:    r.Terminal,
 		mounted:     mounted,
 		pid:         shimPid,
+		execs:       make(map[string]*execProcess),
 	}
 
 	return c, nil
 }
 
+// resetRun prepares a stopped container to be started again in place, the
+// client is still registered so only the per-run state of the shim is renewed.
+func (c *shimContainer) resetRun() {
+	if c.ttyio != nil {
+		c.ttyio.close()
+		c.ttyio = nil
+	}
+	c.stdinPipe = nil
+	c.stdinCloser = make(chan struct{})
+	c.exitIOch = make(chan struct{})
+	c.exitIoOnce = sync.Once{}
+	c.exit = 0
+	c.exitTime = time.Time{}
+	c.stopOutcome = stopNone
+	c.shuttingDown = false
+	c.clientExit = 0
+}
+
 func (c *shimContainer) ioExit() {
 	log.Debugf("close shim container io channel")
 	if c == nil {
@@ -134,9 +166,16 @@ func waitContainerExit(ctx context.Context, s *shimService, c *shimContainer) (i
 	}
 
 	timeStamp := time.Now()
-	ret := 0
-
 	s.mu.Lock()
+	ret := c.exitStatus()
+	if c.stopOutcome != stopNone {
+		log.Infof("container %s stopped, %s", c.id, c.stopOutcome)
+		if s.sandbox != nil {
+			if err := s.sandbox.RecordContainerExit(c.id, c.stopOutcome.exitReason(), ret); err != nil {
+				log.Debugf("exit of container %s not recorded: %v", c.id, err)
+			}
+		}
+	}
 	// Update container status and exit information.
 	if c.cType.CanBeSandbox() {
 		if s.sandbox != nil {
@@ -163,6 +202,10 @@ func waitContainerExit(ctx context.Context, s *shimService, c *shimContainer) (i
 	c.status = task.Status_STOPPED
 	c.exit = uint32(ret)
 	c.exitTime = timeStamp
+	if !c.cType.CanBeSandbox() {
+		// the records of a sandbox are gone with it
+		c.saveTask()
+	}
 
 	log.Debugf("The container %s status is StatusStopped.", c.id)
 	s.mu.Unlock()
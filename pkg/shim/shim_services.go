@@ -2,6 +2,8 @@ package shim
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	er "micrun/errors"
 	log "micrun/logger"
@@ -14,6 +16,7 @@ import (
 
 	cntr "micrun/pkg/micantainer"
 	oci "micrun/pkg/oci"
+	"micrun/pkg/osprofile"
 	"micrun/pkg/utils"
 
 	"github.com/containerd/containerd/api/events"
@@ -66,6 +69,7 @@ type shimService struct {
 	ss         func()
 	monitor    chan error
 	mu         sync.Mutex
+	gcOnce     sync.Once
 }
 
 func New(ctx context.Context, id string, publisher shimv2.Publisher, shutdown func()) (shimv2.Shim, error) {
@@ -81,14 +85,16 @@ func New(ctx context.Context, id string, publisher shimv2.Publisher, shutdown fu
 	}
 
 	s := &shimService{
-		id:        id,
-		micadPid:  micadPid,
-		shimPid:   os.Getpid(),
-		namespace: ns,
-		ctx:       ctx,
-		events:    make(chan any, channelSize),
-		ss:        shutdown,
-		monitor:   make(chan error),
+		id:         id,
+		micadPid:   micadPid,
+		shimPid:    os.Getpid(),
+		namespace:  ns,
+		containers: make(map[string]*shimContainer),
+		ctx:        ctx,
+		events:     make(chan any, channelSize),
+		ec:         make(chan exitEvent, channelSize),
+		ss:         shutdown,
+		monitor:    make(chan error),
 	}
 
 	log.Debugf("starting service background goroutines exit listener")
@@ -99,6 +105,9 @@ func New(ctx context.Context, id string, publisher shimv2.Publisher, shutdown fu
 	forwarder := s.newEventsForwarder(ctx, publisher)
 	go forwarder.forward()
 
+	// the action of the shim binary, parsed by shim.Run, is empty for the serving shim
+	s.recoverOnServe(flag.Arg(0))
+
 	log.Debugf("completed successfully, returning shimService")
 	return s, nil
 }
@@ -356,6 +365,26 @@ func (s *shimService) State(ctx context.Context, r *taskAPI.StateRequest) (*task
 		return nil, fmt.Errorf("container %s not found", r.ID)
 	}
 
+	if r.ExecID != "" {
+		e, err := c.getExec(r.ExecID)
+		if err != nil {
+			return nil, err
+		}
+		return &taskAPI.StateResponse{
+			ID:         c.id,
+			Bundle:     c.bundle,
+			Pid:        shimPid,
+			Status:     e.status,
+			Stdin:      e.stdin,
+			Stdout:     e.stdout,
+			Stderr:     e.stderr,
+			Terminal:   e.terminal,
+			ExitStatus: e.exit,
+			ExitedAt:   timestamppb.New(e.exitTime),
+			ExecID:     e.id,
+		}, nil
+	}
+
 	return &taskAPI.StateResponse{
 		ID:         c.id,
 		Bundle:     c.bundle,
@@ -373,24 +402,21 @@ func (s *shimService) State(ctx context.Context, r *taskAPI.StateRequest) (*task
 
 // does not send request to micad, create container in memory
 func (s *shimService) Create(ctx context.Context, r *taskAPI.CreateTaskRequest) (*taskAPI.CreateTaskResponse, error) {
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	log.Debugf("creating task %s (bundle: %s, terminal: %v)", r.ID, r.Bundle, r.Terminal)
 	if err := utils.ValidContainerID(r.ID); err != nil {
 		return nil, er.InvalidCID
 	}
 
-	// create container sync
+	// create container sync, create takes the lock for the sandbox and config it sets
 	container, err := create(ctx, s, r)
 	if err != nil {
-		return nil, err
+		return nil, errdefs.ToGRPC(err)
 	}
 	// lock when updating shared state
 	s.mu.Lock()
 	container.status = task.Status_CREATED
 	s.containers[r.ID] = container
+	container.saveTask()
 	s.mu.Unlock()
 
 	pid := container.pid
@@ -428,7 +454,14 @@ func (s *shimService) Start(ctx context.Context, r *taskAPI.StartRequest) (*task
 
 	respPid := shimPid
 	if r.ExecID != "" {
-		log.Infof("container %s has no exec process", r.ID)
+		e, err := c.getExec(r.ExecID)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("starting exec %s in container %s: %q", e.id, c.id, e.spec.Args)
+		if err := startExec(ctx, s, c, e); err != nil {
+			return nil, errdefs.ToGRPC(err)
+		}
 		s.send(&events.TaskExecStarted{
 			ContainerID: c.id,
 			ExecID:      r.ExecID,
@@ -440,6 +473,9 @@ func (s *shimService) Start(ctx context.Context, r *taskAPI.StartRequest) (*task
 		if err != nil {
 			return nil, errdefs.ToGRPC(err)
 		}
+		if err := awaitReady(s, c); err != nil {
+			return nil, errdefs.ToGRPC(err)
+		}
 		if c.pid != 0 {
 			respPid = c.pid
 		}
@@ -477,9 +513,27 @@ func (s *shimService) Delete(ctx context.Context, r *taskAPI.DeleteRequest) (*ta
 	}
 
 	if r.ExecID != "" {
-		return nil, errdefs.ToGRPCf(errdefs.ErrNotImplemented, "exec processes are not supported for container %s", r.ID)
+		e, err := c.getExec(r.ExecID)
+		if err != nil {
+			return nil, err
+		}
+		if e.status == task.Status_RUNNING {
+			return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "exec %s of container %s is still running", e.id, c.id)
+		}
+		if e.ttyio != nil {
+			e.ttyio.close()
+			e.ttyio = nil
+		}
+		delete(c.execs, e.id)
+		return &taskAPI.DeleteResponse{
+			ExitStatus: e.exit,
+			ExitedAt:   timestamppb.New(e.exitTime),
+			Pid:        shimPid,
+		}, nil
 	}
 
+	c.cancelExecs()
+
 	// delete single container or entire sandbox
 	if c.cType.CanBeSandbox() {
 		if s.sandbox == nil {
@@ -603,8 +657,38 @@ func (s *shimService) Resume(ctx context.Context, r *taskAPI.ResumeRequest) (*pt
 
 	return emptyResponse, nil
 }
-func (s *shimService) Checkpoint(context.Context, *taskAPI.CheckpointTaskRequest) (*ptypes.Empty, error) {
 
+// Checkpoint saves the client of a running container and its record to the
+// checkpoint path, the container is stopped afterwards when asked to exit.
+func (s *shimService) Checkpoint(ctx context.Context, r *taskAPI.CheckpointTaskRequest) (*ptypes.Empty, error) {
+	s.mu.Lock()
+	c, found := s.containers[r.ID]
+	if !found || c == nil {
+		s.mu.Unlock()
+		return nil, errdefs.ToGRPC(er.ContainerNotFound)
+	}
+	if s.sandbox == nil {
+		s.mu.Unlock()
+		return nil, errdefs.ToGRPC(er.SandboxNotFound)
+	}
+	exit, dir := checkpointOptions(r)
+	log.Infof("checkpointing container %s to %s (exit: %v)", c.id, dir, exit)
+	save, err := s.sandbox.CheckpointContainer(ctx, c.id, dir)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, errdefs.ToGRPC(err)
+	}
+	// xl save runs without the lock, Kill and State are served meanwhile
+	if err := save(); err != nil {
+		return nil, errdefs.ToGRPC(err)
+	}
+
+	s.send(&events.TaskCheckpointed{
+		ContainerID: c.id,
+	})
+	if exit {
+		requestContainerKill(ctx, s, c, syscall.SIGKILL, "checkpoint")
+	}
 	return emptyResponse, nil
 }
 
@@ -621,16 +705,61 @@ func (s *shimService) Kill(ctx context.Context, r *taskAPI.KillRequest) (*ptypes
 	}
 
 	if r.ExecID != "" {
-		log.Debugf("exec processes are not supported for container %s, ignoring Kill request", r.ID)
+		e, err := c.getExec(r.ExecID)
+		if err != nil {
+			return nil, err
+		}
+		e.kill(signum)
 		return emptyResponse, nil
 	}
 
+	// the translation table tells what the signal means for the RTOS
+	if c.controls != nil {
+		action, ok := c.controls.Signal(signum)
+		switch {
+		case !ok:
+			// SIGSTOP and SIGCONT keep pausing the client
+		case action.Kind == osprofile.SignalStop,
+			// the sandbox container has no client os to power off
+			action.Kind == osprofile.SignalShutdown && c.cType.CanBeSandbox():
+			signum = syscall.SIGKILL
+		case action.Kind == osprofile.SignalShutdown && (c.shuttingDown || c.status != task.Status_RUNNING):
+			log.Debugf("container %s is already shutting down or not running", c.id)
+			return emptyResponse, nil
+		case action.Kind == osprofile.SignalIgnore:
+			log.Debugf("signal %v ignored for container %s", signum, c.id)
+			return emptyResponse, nil
+		default:
+			if s.sandbox == nil {
+				return nil, er.SandboxNotFound
+			}
+			err := s.sandbox.SignalContainer(ctx, c.id, signum)
+			if action.Kind == osprofile.SignalShutdown && errors.Is(err, er.NotSupported) {
+				// the client can not power off by itself, it is stopped instead
+				log.Debugf("container %s: %v, stop it", c.id, err)
+				signum = syscall.SIGKILL
+				break
+			}
+			if err != nil {
+				return nil, errdefs.ToGRPC(err)
+			}
+			if action.Kind == osprofile.SignalShutdown {
+				c.shuttingDown = true
+				go finishShutdown(s, c, action.Grace)
+			}
+			return emptyResponse, nil
+		}
+	}
+
 	switch signum {
 	case syscall.SIGKILL, syscall.SIGTERM:
 		if c.status == task.Status_STOPPED {
 			log.Debugf("container %s already stopped", c.id)
 			return emptyResponse, nil
 		}
+		if c.stopOutcome == stopNone {
+			c.stopOutcome = stopForced
+		}
 		if c.cType.CanBeSandbox() {
 			if s.sandbox != nil {
 				if err := s.sandbox.Stop(ctx, true); err != nil {
@@ -689,12 +818,34 @@ func (s *shimService) Kill(ctx context.Context, r *taskAPI.KillRequest) (*ptypes
 			return nil, err
 		}
 	default:
-		return emptyResponse, nil
+		return nil, errdefs.ToGRPC(fmt.Errorf("%w: %v is not supported by container %s", er.InvalidSignal, signum, c.id))
 	}
 	return emptyResponse, nil
 }
-func (s *shimService) Exec(context.Context, *taskAPI.ExecProcessRequest) (*ptypes.Empty, error) {
 
+// Exec registers a command to be run in the client shell when the exec is started.
+func (s *shimService) Exec(ctx context.Context, r *taskAPI.ExecProcessRequest) (*ptypes.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, found := s.containers[r.ID]
+	if c == nil || !found {
+		return nil, er.ContainerNotFound
+	}
+	if _, exists := c.execs[r.ExecID]; exists {
+		return nil, errdefs.ToGRPCf(errdefs.ErrAlreadyExists, "exec %s of container %s already exists", r.ExecID, r.ID)
+	}
+
+	e, err := newExecProcess(r)
+	if err != nil {
+		return nil, err
+	}
+	c.execs[e.id] = e
+
+	s.send(&events.TaskExecAdded{
+		ContainerID: c.id,
+		ExecID:      e.id,
+	})
 	return emptyResponse, nil
 }
 
@@ -729,7 +880,17 @@ func (s *shimService) CloseIO(ctx context.Context, r *taskAPI.CloseIORequest) (*
 	}
 
 	if r.ExecID != "" {
-		return nil, errdefs.ToGRPCf(errdefs.ErrNotImplemented, "exec processes are not supported for container %s", r.ID)
+		e, err := c.getExec(r.ExecID)
+		if err != nil {
+			return nil, err
+		}
+		// the command line is complete once written, exec stdin is never read
+		if r.Stdin && e.ttyio != nil && e.ttyio.io.Stdin() != nil {
+			if err := e.ttyio.io.Stdin().Close(); err != nil {
+				log.Debugf("failed to close stdin of exec %s: %v", e.id, err)
+			}
+		}
+		return emptyResponse, nil
 	}
 
 	if !r.Stdin {
@@ -803,8 +964,22 @@ func (s *shimService) Wait(ctx context.Context, r *taskAPI.WaitRequest) (*taskAP
 		return nil, er.ContainerNotFound
 	}
 	if r.ExecID != "" {
+		e, err := c.getExec(r.ExecID)
 		s.mu.Unlock()
-		return nil, errdefs.ToGRPCf(errdefs.ErrNotImplemented, "exec processes are not supported for container %s", r.ID)
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait canceled: %w", ctx.Err())
+		case <-e.exited:
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return &taskAPI.WaitResponse{
+			ExitStatus: e.exit,
+			ExitedAt:   timestamppb.New(e.exitTime),
+		}, nil
 	}
 
 	// Capture current status and the exit channel, then release the lock while waiting
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	log "micrun/logger"
+	"micrun/pkg/console"
+	"micrun/pkg/osprofile"
 	"net/url"
 	"os"
 	"strconv"
@@ -13,12 +15,11 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/namespaces"
 	"github.com/containerd/fifo"
+	units "github.com/docker/go-units"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/execabs"
-	"golang.org/x/sys/unix"
-	"golang.org/x/term"
 )
 
 // stdioInfo defines the standard IO paths for a container.
@@ -62,12 +63,17 @@ type pipeIO struct {
 type binaryIO struct {
 	cmd *execabs.Cmd
 	out *pipe
+	// err is handed to the logger as fd 4 for compatibility, the console output is merged into out.
+	err *pipe
 }
 
+// binaryIOProcTermTimeout is how long the logging binary has to flush and exit after SIGTERM.
+var binaryIOProcTermTimeout = 12 * time.Second
+
 // fileIO implements IO for files, supporting writing stdout/stderr to the same file.
 type fileIO struct {
-	out io.WriteCloser
-	in  string // File path.
+	out *logFile
+	in  io.ReadCloser
 }
 
 // ttyIO manages the TTY and IO streams for a container.
@@ -77,7 +83,9 @@ type ttyIO struct {
 }
 
 func (tty *ttyIO) close() {
-	tty.io.Close()
+	if err := tty.io.Close(); err != nil {
+		log.Debugf("failed to close container io: %v", err)
+	}
 }
 
 // newTtyIO creates a new TTY IO handler based on the provided URI scheme.
@@ -155,23 +163,143 @@ func newPipeIO(ctx context.Context, stdio *stdioInfo) (*pipeIO, error) {
 	return pipeIO, nil
 }
 
-func newFileIO(ctx context.Context, stdio *stdioInfo, uri *url.URL) (*fileIO, error) {
-	return nil, errdefs.ErrNotImplemented
+// newFileIO appends the merged console output to the file at the URI path, e.g.
+// file:///var/log/rtos.log?max_size=10MB&max_files=3&stdin=/path/to/input
+//   - max_size: rotate the log once it would grow past this size, disabled by default.
+//   - max_files: rotated files to keep (path.1 ... path.N), defaults to 1.
+//   - stdin: optional file fed to the client console, otherwise the stdin fifo is used.
+func newFileIO(ctx context.Context, stdio *stdioInfo, uri *url.URL) (_ *fileIO, err error) {
+	if uri.Path == "" {
+		return nil, fmt.Errorf("file log uri %q has no path", uri.String())
+	}
+	query := uri.Query()
+
+	var maxSize int64
+	if v := query.Get("max_size"); v != "" {
+		if maxSize, err = units.RAMInBytes(v); err != nil || maxSize <= 0 {
+			return nil, fmt.Errorf("invalid max_size %q in log uri", v)
+		}
+	}
+	maxFiles := 1
+	if v := query.Get("max_files"); v != "" {
+		if maxFiles, err = strconv.Atoi(v); err != nil || maxFiles < 1 {
+			return nil, fmt.Errorf("invalid max_files %q in log uri", v)
+		}
+	}
+
+	var in io.ReadCloser
+	if path := query.Get("stdin"); path != "" {
+		if in, err = os.Open(path); err != nil {
+			return nil, fmt.Errorf("failed to open stdin file: %w", err)
+		}
+	} else if stdio.Stdin != "" {
+		if in, err = fifo.OpenFifo(ctx, stdio.Stdin, syscall.O_RDONLY|syscall.O_NONBLOCK, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := openLogFile(uri.Path, maxSize, maxFiles)
+	if err != nil {
+		if in != nil {
+			in.Close()
+		}
+		return nil, err
+	}
+	return &fileIO{out: out, in: in}, nil
 }
 
 // newBinaryIO runs a custom binary process for pluggable shim logging
 // containerd newBinaryIO(ctx context.Context, id string, uri *url.URL) (_ runc.IO, err error)
+// The binary gets stdout/stderr pipes as fd 3/4 and closes or writes fd 5 once it is ready.
 func newBinaryIO(ctx context.Context, id string, uri *url.URL) (bio *binaryIO, err error) {
-	return nil, errdefs.ErrNotImplemented
+	ns, ok := namespaces.Namespace(ctx)
+	if !ok {
+		return nil, fmt.Errorf("namespace is required for binary logging")
+	}
+
+	var closers []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}()
+
+	out, err := newPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipes: %w", err)
+	}
+	closers = append(closers, out.Close)
+	serr, err := newPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipes: %w", err)
+	}
+	closers = append(closers, serr.Close)
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, r.Close, w.Close)
+
+	cmd := newBinaryCmd(uri, id, ns)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, out.r, serr.r, w)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start logging binary process: %w", err)
+	}
+	closers = append(closers, func() error { return cmd.Process.Kill() })
+
+	// close our side of the pipe after start
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close write pipe after start: %w", err)
+	}
+	// wait for the logging binary to be ready
+	b := make([]byte, 1)
+	if _, err := r.Read(b); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read from logging binary: %w", err)
+	}
+	r.Close()
+	log.Debugf("logging binary %s started for %s/%s, pid %d", uri.Path, ns, id, cmd.Process.Pid)
+
+	return &binaryIO{cmd: cmd, out: out, err: serr}, nil
+}
+
+// newBinaryCmd builds the logging command, URI query pairs become its arguments.
+func newBinaryCmd(binaryURI *url.URL, id, ns string) *execabs.Cmd {
+	var args []string
+	for k, vs := range binaryURI.Query() {
+		args = append(args, k)
+		if len(vs) > 0 {
+			args = append(args, vs[0])
+		}
+	}
+	cmd := execabs.Command(binaryURI.Path, args...)
+	cmd.Env = append(cmd.Env,
+		"CONTAINER_ID="+id,
+		"CONTAINER_NAMESPACE="+ns,
+	)
+	return cmd
+}
+
+func newPipe() (*pipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &pipe{r: r, w: w}, nil
 }
 
 func (p *pipeIO) Close() error {
-	var err error
-	if err = p.in.Close(); err != nil {
-		return fmt.Errorf("failed to close stdin: %w", err)
+	if p.in != nil {
+		if err := p.in.Close(); err != nil {
+			return fmt.Errorf("failed to close stdin: %w", err)
+		}
 	}
-	if err = p.out.Close(); err != nil && p.out != nil {
-		return fmt.Errorf("failed to close stdout: %w", err)
+	if p.out != nil {
+		if err := p.out.Close(); err != nil {
+			return fmt.Errorf("failed to close stdout: %w", err)
+		}
 	}
 	return nil
 }
@@ -188,10 +316,45 @@ func (p *pipeIO) Stderr() io.Writer {
 	return p.out
 }
 
+// Close closes the console pipes, so the logger drains them, then stops the logger.
 func (b *binaryIO) Close() error {
-	err0 := b.out.Close()
-	err1 := b.cmd.Cancel()
-	return errors.Join(err0, err1)
+	var errs []error
+	for _, p := range []*pipe{b.out, b.err} {
+		if p != nil {
+			errs = append(errs, p.Close())
+		}
+	}
+	errs = append(errs, b.cancel())
+	return errors.Join(errs...)
+}
+
+func (b *binaryIO) cancel() error {
+	if b.cmd == nil || b.cmd.Process == nil {
+		return nil
+	}
+	// Send SIGTERM first, so logger process has a chance to flush and exit properly
+	if err := b.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		log.Warnf("failed to send SIGTERM to logging binary, killing it: %v", err)
+		return errors.Join(err, b.cmd.Process.Kill())
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		var exitErr *execabs.ExitError
+		if errors.As(err, &exitErr) {
+			// exit status of the logger after SIGTERM is not an IO error
+			log.Debugf("logging binary exited: %v", err)
+			return nil
+		}
+		return err
+	case <-time.After(binaryIOProcTermTimeout):
+		log.Warnf("logging binary did not exit in %v, killing it", binaryIOProcTermTimeout)
+		return b.cmd.Process.Kill()
+	}
 }
 
 func (b *binaryIO) Stdin() io.ReadCloser {
@@ -207,15 +370,15 @@ func (b *binaryIO) Stderr() io.Writer {
 }
 
 func (f *fileIO) Close() error {
-	var err error
-	if err = f.out.Close(); err != nil && f.out != nil {
-		return err
+	var errIn error
+	if f.in != nil {
+		errIn = f.in.Close()
 	}
-	return nil
+	return errors.Join(f.out.Close(), errIn)
 }
 
 func (f *fileIO) Stdin() io.ReadCloser {
-	return nil
+	return f.in
 }
 
 func (f *fileIO) Stdout() io.Writer {
@@ -227,8 +390,10 @@ func (f *fileIO) Stderr() io.Writer {
 }
 
 // ioCopy manages copying data between the container's IO streams and the pipe.
-func ioCopy(ctx context.Context, exitch, stdinCloser chan struct{}, tty *ttyIO, stdinPipe io.WriteCloser, stdoutPipe io.Reader, onInterrupt func(syscall.Signal, string)) {
+// Keys typed on stdin are translated by controls, a nil controls forwards everything.
+func ioCopy(ctx context.Context, exitch, stdinCloser chan struct{}, tty *ttyIO, stdinPipe io.WriteCloser, stdoutPipe io.Reader, controls *osprofile.Controls, onInterrupt func(syscall.Signal, string)) {
 	var wg sync.WaitGroup
+	// the first control key ends the client, repeated keys must not kill it again
 	killOnce := sync.Once{}
 	notifyInterrupt := func(sig syscall.Signal, reason string) {
 		if onInterrupt == nil {
@@ -238,7 +403,10 @@ func ioCopy(ctx context.Context, exitch, stdinCloser chan struct{}, tty *ttyIO,
 			onInterrupt(sig, reason)
 		})
 	}
-	control := detectControlChars()
+	if controls == nil {
+		controls = &osprofile.Controls{}
+	}
+	filter := controls.NewInputFilter()
 
 	// Mica client **always** create ONE pty slave, we have to handle bytes from it for all different io stream methods of containerd
 	if tty.io.Stdout() != nil {
@@ -250,6 +418,10 @@ func ioCopy(ctx context.Context, exitch, stdinCloser chan struct{}, tty *ttyIO,
 			} else {
 				log.Debug("Stdout copy completed.")
 			}
+			// Detach from the console, it keeps being recorded in the console log.
+			if closer, ok := stdoutPipe.(io.Closer); ok {
+				closer.Close()
+			}
 			wg.Done()
 			if tty.io.Stdin() != nil {
 				tty.io.Stdin().Close()
@@ -275,18 +447,29 @@ func ioCopy(ctx context.Context, exitch, stdinCloser chan struct{}, tty *ttyIO,
 
 				n, err := tty.io.Stdin().Read(buf)
 				if n > 0 {
-					chunk := buf[:n]
-					if sig, ok := control.detect(chunk); ok {
-						log.Infof("Captured host control character, interrupting container IO.")
-						notifyInterrupt(sig, "host-control")
-						return
-					}
+					chunk, action, hit := filter.Filter(buf[:n])
 					if stdinPipe == nil {
 						log.Debug("stdin pipe is nil, stop copying stdin.")
 						return
 					}
-					if _, werr := stdinPipe.Write(chunk); werr != nil {
-						log.Debugf("Stdin write failed: %v", werr)
+					if len(chunk) > 0 {
+						if _, werr := stdinPipe.Write(chunk); werr != nil {
+							// another console session holds the input, keep the stream open
+							if !errors.Is(werr, console.ErrInputBusy) && !errors.Is(werr, console.ErrInputTakenOver) {
+								log.Debugf("Stdin write failed: %v", werr)
+								return
+							}
+							log.Debugf("Stdin dropped: %v", werr)
+						}
+					}
+					switch {
+					case hit && action.Kind == osprofile.KeySignal:
+						log.Infof("Captured host control key, sending %v to the container.", action.Signal)
+						notifyInterrupt(action.Signal, "host-control")
+					case hit && action.Kind == osprofile.KeyDetach:
+						// release the console input, the client keeps running
+						log.Info("Detach key received, stop copying stdin.")
+						stdinPipe.Close()
 						return
 					}
 				}
@@ -309,47 +492,6 @@ func ioCopy(ctx context.Context, exitch, stdinCloser chan struct{}, tty *ttyIO,
 	log.Debug("All IO copies completed.")
 }
 
-type controlCharSet struct {
-	intr byte
-	quit byte
-}
-
-func detectControlChars() controlCharSet {
-	cc := controlCharSet{intr: 0x03, quit: 0x1c} // Defaults: INTR (^C), QUIT (^\)
-
-	// current console, parse termios configurations
-	tty, err := os.Open("/dev/tty")
-	if err != nil {
-		return cc
-	}
-	defer tty.Close()
-
-	if !term.IsTerminal(int(tty.Fd())) {
-		return cc
-	}
-
-	if t, err := unix.IoctlGetTermios(int(tty.Fd()), unix.TCGETS); err == nil && len(t.Cc) > 0 {
-		if t.Cc[unix.VINTR] != 0 {
-			cc.intr = t.Cc[unix.VINTR]
-		}
-		if t.Cc[unix.VQUIT] != 0 {
-			cc.quit = t.Cc[unix.VQUIT]
-		}
-	}
-
-	return cc
-}
-
-func (c controlCharSet) detect(p []byte) (syscall.Signal, bool) {
-	for _, b := range p {
-		switch b {
-		case c.intr, c.quit:
-			return syscall.SIGKILL, true
-		}
-	}
-	return 0, false
-}
-
 // getBoolAnnotation parses a boolean annotation from the container spec with a default value.
 // Returns (value, isExplicitlySet) where isExplicitlySet indicates if the annotation was provided.
 func getBoolAnnotation(spec *specs.Spec, key string, defaultValue bool) (bool, bool) {
@@ -0,0 +1,98 @@
+package shim
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/namespaces"
+)
+
+// fakeLogger mimics a containerd logging binary: it reports ready on fd 5 and
+// copies fd 3 into the file given by the "out" argument.
+const fakeLogger = `#!/bin/sh
+trap '' TERM
+out="$2"
+echo "$CONTAINER_NAMESPACE/$CONTAINER_ID" > "$out.env"
+exec 5>&-
+cat <&3 > "$out"
+`
+
+// stuckLogger never exits on SIGTERM.
+const stuckLogger = `#!/bin/sh
+trap '' TERM
+exec 5>&-
+while true; do sleep 1; done
+`
+
+func writeScript(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBinaryIO(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "console.log")
+	uri, err := url.Parse("binary://" + writeScript(t, dir, "logger", fakeLogger) + "?out=" + url.QueryEscape(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newBinaryIO(context.Background(), "rtos", uri); err == nil {
+		t.Fatal("binary IO without namespace should fail")
+	}
+
+	ctx := namespaces.WithNamespace(context.Background(), "k8s.io")
+	bio, err := newBinaryIO(ctx, "rtos", uri)
+	if err != nil {
+		t.Fatalf("newBinaryIO: %v", err)
+	}
+	if _, err := bio.Stdout().Write([]byte("NuttShell (NSH)\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bio.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got := readFile(t, out); got != "NuttShell (NSH)\n" {
+		t.Fatalf("logger output = %q", got)
+	}
+	if got := strings.TrimSpace(readFile(t, out+".env")); got != "k8s.io/rtos" {
+		t.Fatalf("logger env = %q, want k8s.io/rtos", got)
+	}
+}
+
+func TestBinaryIOKillsStuckLogger(t *testing.T) {
+	old := binaryIOProcTermTimeout
+	binaryIOProcTermTimeout = 100 * time.Millisecond
+	defer func() { binaryIOProcTermTimeout = old }()
+
+	dir := t.TempDir()
+	uri, err := url.Parse("binary://" + writeScript(t, dir, "logger", stuckLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bio, err := newBinaryIO(namespaces.WithNamespace(context.Background(), "default"), "rtos", uri)
+	if err != nil {
+		t.Fatalf("newBinaryIO: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bio.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not kill the stuck logger")
+	}
+}
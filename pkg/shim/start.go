@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	log "micrun/logger"
+	cntr "micrun/pkg/micantainer"
 	"syscall"
 
 	"github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/namespaces"
 )
 
 // startContainer starts a container or sandbox within the shim service.
@@ -34,6 +37,15 @@ func startContainer(ctx context.Context, s *shimService, c *shimContainer) (retE
 		return err
 	}
 
+	switch c.status {
+	case task.Status_CREATED:
+	case task.Status_STOPPED:
+		log.Infof("restarting container %s in place", c.id)
+		c.resetRun()
+	default:
+		return fmt.Errorf("%w: container %s is %s, can not start it", errdefs.ErrFailedPrecondition, c.id, c.status)
+	}
+
 	if c.cType.CanBeSandbox() {
 		err := s.sandbox.Start(ctx)
 		if err != nil {
@@ -48,9 +60,18 @@ func startContainer(ctx context.Context, s *shimService, c *shimContainer) (retE
 		}
 	}
 
+	return attachContainer(ctx, s, c)
+}
+
+// attachContainer serves a running container: it connects the task stdio to
+// the client console, starts the watchers of the client and waits for its
+// exit. Called after the start, or for a client which kept running while its
+// shim was restarted.
+func attachContainer(ctx context.Context, s *shimService, c *shimContainer) error {
 	oldst := c.status
 	c.status = task.Status_RUNNING
 	log.Debugf("container status from %s => %s ", oldst, c.status)
+	c.saveTask()
 	stdin, stdout, stderr, err := s.sandbox.IOStream(c.id, c.id)
 	if err != nil {
 		return err
@@ -59,15 +80,27 @@ func startContainer(ctx context.Context, s *shimService, c *shimContainer) (retE
 
 	c.stdinPipe = stdin
 
+	controls, err := s.sandbox.ConsoleControls(c.id)
+	if err != nil {
+		// the annotations were accepted at create, keep the container running
+		log.Warnf("invalid console controls for %s, stdin is forwarded as is: %v", c.id, err)
+	}
+	c.controls = controls
+
 	if c.stdin != "" || c.stdout != "" || c.stderr != "" {
-		tty, err := newTtyIO(ctx, c.id, c.stdin, c.stdout, c.stderr, c.terminal)
+		// binary loggers need the namespace, which is not always carried by the request.
+		ioCtx := ctx
+		if _, ok := namespaces.Namespace(ctx); !ok && s.namespace != "" {
+			ioCtx = namespaces.WithNamespace(ctx, s.namespace)
+		}
+		tty, err := newTtyIO(ioCtx, c.id, c.stdin, c.stdout, c.stderr, c.terminal)
 		if err != nil {
 			return err
 		}
 		c.ttyio = tty
 
 		intr := hostIntrHandler(ctx, s, c)
-		go ioCopy(ctx, c.exitIOch, c.stdinCloser, tty, stdin, stdout, intr)
+		go ioCopy(ctx, c.exitIOch, c.stdinCloser, tty, stdin, stdout, controls, intr)
 	} else {
 		// Close stdin closer so CloseIO can unblock even when the container never
 		// had an input fifo.
@@ -81,6 +114,32 @@ func startContainer(ctx context.Context, s *shimService, c *shimContainer) (retE
 		}
 	}
 
+	if c.spec != nil && !c.cType.CanBeSandbox() {
+		policy, err := cntr.ParseRestartPolicy(c.spec.Annotations)
+		if err != nil {
+			log.Warnf("container %s is not restarted: %v", c.id, err)
+		}
+		c.restartPolicy = policy
+		if policy.Mode != cntr.RestartNever {
+			go watchClient(s, c, c.exitIOch)
+		}
+		// restarts of the workload before a node reboot count as well
+		if count := s.sandbox.ContainerRestartCount(c.id); count > 0 {
+			go s.labelRestartCount(c.id, count)
+		}
+		if ready, fail := s.sandbox.ContainerReadiness(c.id); ready != nil {
+			if fail {
+				// waited for by Start once it released the lock
+				c.ready = ready
+			} else {
+				go watchReadiness(s, c, ready, c.exitIOch)
+			}
+		}
+		if exited := s.sandbox.ContainerExited(c.id); exited != nil {
+			go watchExitStatus(s, c, exited, c.exitIOch)
+		}
+	}
+
 	go waitContainerExit(ctx, s, c)
 
 	return nil
@@ -372,3 +372,16 @@ func (s *shimService) getContainerStatus(id string) (task.Status, error) {
 
 	return st, nil
 }
+
+// clientOf is the name of the client of container id at micad, which differs
+// from id for an adopted client.
+func (s *shimService) clientOf(id string) string {
+	if s.sandbox == nil {
+		return id
+	}
+	cs, err := s.sandbox.StatusContainer(id)
+	if err != nil || cs.Client == "" {
+		return id
+	}
+	return cs.Client
+}
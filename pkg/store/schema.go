@@ -0,0 +1,66 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SchemaKey is the field of a record holding its schema version. Records
+// written before the field was introduced are version 1.
+const SchemaKey = "schema"
+
+// ErrNewerSchema is returned for a record written by a newer micrun.
+var ErrNewerSchema = errors.New("record schema is newer than supported")
+
+// Migration upgrades a decoded record by one schema version in place.
+type Migration func(rec map[string]any) error
+
+// Schema versions one kind of record and upgrades older records on load.
+type Schema struct {
+	// Kind names the records in errors, e.g. "sandbox".
+	Kind string
+	// Version is the version written by this micrun.
+	Version int
+	// Migrations[v] upgrades a record of version v to v+1, one is required
+	// for every version below Version.
+	Migrations map[int]Migration
+}
+
+// Upgrade returns raw migrated to the current version. A record newer than
+// the current version is refused, its fields may mean something this micrun
+// does not know about.
+func (sc *Schema) Upgrade(raw []byte) ([]byte, error) {
+	rec := make(map[string]any)
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, fmt.Errorf("corrupted %s record: %w", sc.Kind, err)
+	}
+
+	version := 1
+	if v, ok := rec[SchemaKey]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return nil, fmt.Errorf("%s record has an invalid schema version %v", sc.Kind, v)
+		}
+		version = int(n)
+	}
+	if version > sc.Version {
+		return nil, fmt.Errorf("%w: %s record has schema version %d, this micrun supports up to %d, upgrade micrun",
+			ErrNewerSchema, sc.Kind, version, sc.Version)
+	}
+	if version == sc.Version {
+		return raw, nil
+	}
+
+	for ; version < sc.Version; version++ {
+		migrate, ok := sc.Migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration of %s records from schema version %d", sc.Kind, version)
+		}
+		if err := migrate(rec); err != nil {
+			return nil, fmt.Errorf("failed to migrate %s record from schema version %d: %w", sc.Kind, version, err)
+		}
+	}
+	rec[SchemaKey] = sc.Version
+	return json.Marshal(rec)
+}
@@ -0,0 +1,569 @@
+// Package store persists the sandbox and container records of micrun.
+//
+// Every sandbox owns one directory below the store root:
+//
+//	<root>/<sandbox>/sandbox.json
+//	<root>/<sandbox>/containers/<container>.json
+//	<root>/<sandbox>/tasks/<container>.json
+//	<root>/<sandbox>.lock
+//
+// Records of micrun before the store are moved to these paths when they are
+// first loaded, so that pods running through an upgrade keep them, see NewUpgrading.
+//
+// Records are replaced atomically, a crash leaves either the previous or the
+// new record on disk but never a truncated one. The running shim and the
+// `shim delete` binary share the records, they are serialised by flock(2) on
+// the lock file of the sandbox.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	defs "micrun/definitions"
+	er "micrun/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+const lockSuffix = ".lock"
+
+// Store is a tree of sandbox and container records.
+type Store struct {
+	root string
+	// legacyRoot holds the container records of micrun before the store, empty
+	// when there are none to upgrade.
+	legacyRoot string
+
+	mu    sync.Mutex
+	locks map[string]*sandboxLock
+}
+
+// sandboxLock is the flock held by this process on a sandbox, shared by its goroutines.
+type sandboxLock struct {
+	f    *os.File
+	refs int
+}
+
+var defaultStore = NewUpgrading(defs.SandboxDataDir, defs.MicrunStateDir)
+
+// New returns a store rooted at root.
+func New(root string) *Store {
+	return &Store{root: root, locks: make(map[string]*sandboxLock)}
+}
+
+// NewUpgrading returns a store rooted at root which also finds the records
+// micrun kept before the store: the sandbox record <root>/<sandbox>/state.json
+// and the container records <legacyRoot>/<sandbox>/<container>/state.json.
+func NewUpgrading(root, legacyRoot string) *Store {
+	s := New(root)
+	s.legacyRoot = legacyRoot
+	return s
+}
+
+// Default returns the store of the host, rooted at SandboxDataDir.
+func Default() *Store {
+	return defaultStore
+}
+
+// SandboxDir returns the directory of the records of sandbox id.
+func (s *Store) SandboxDir(id string) string {
+	return filepath.Join(s.root, id)
+}
+
+func (s *Store) sandboxFile(id string) string {
+	return filepath.Join(s.SandboxDir(id), defs.SandboxStateFile)
+}
+
+func (s *Store) containerFile(sandboxID, id string) string {
+	return filepath.Join(s.SandboxDir(sandboxID), defs.ContainerStateDir, id+".json")
+}
+
+func (s *Store) taskFile(sandboxID, id string) string {
+	return filepath.Join(s.SandboxDir(sandboxID), defs.TaskStateDir, id+".json")
+}
+
+func (s *Store) legacySandboxFile(id string) string {
+	if s.legacyRoot == "" {
+		return ""
+	}
+	return filepath.Join(s.SandboxDir(id), defs.LegacyStateFile)
+}
+
+func (s *Store) legacyContainerFile(sandboxID, id string) string {
+	if s.legacyRoot == "" {
+		return ""
+	}
+	return filepath.Join(s.legacyRoot, sandboxID, id, defs.LegacyStateFile)
+}
+
+func (s *Store) lockFile(id string) string {
+	return filepath.Join(s.root, id+lockSuffix)
+}
+
+// Lock takes the exclusive lock of sandbox id and returns the function releasing it.
+// The lock excludes other processes, goroutines of this process share it, so
+// that a caller holding it can still save and load records.
+func (s *Store) Lock(id string) (unlock func(), err error) {
+	if err := checkID(id, er.EmptySandboxID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.locks[id]
+	if !ok {
+		f, err := s.flock(id)
+		if err != nil {
+			return nil, err
+		}
+		l = &sandboxLock{f: f}
+		s.locks[id] = l
+	}
+	l.refs++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if l.refs--; l.refs == 0 {
+				unix.Flock(int(l.f.Fd()), unix.LOCK_UN)
+				l.f.Close()
+				delete(s.locks, id)
+			}
+		})
+	}, nil
+}
+
+// flock locks the lock file of sandbox id. The file is removed with the
+// sandbox, a lock taken on a removed file is dropped and taken again.
+func (s *Store) flock(id string) (*os.File, error) {
+	if err := os.MkdirAll(s.root, defs.DirMode); err != nil {
+		return nil, fmt.Errorf("store: failed to create %s: %w", s.root, err)
+	}
+	path := s.lockFile(id)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, defs.FileMode)
+		if err != nil {
+			return nil, fmt.Errorf("store: failed to open lock of sandbox %s: %w", id, err)
+		}
+		if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("store: failed to lock sandbox %s: %w", id, err)
+		}
+		held, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if cur, err := os.Stat(path); err == nil && os.SameFile(held, cur) {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+// SaveSandbox replaces the record of sandbox id with rec.
+func (s *Store) SaveSandbox(id string, rec any) error {
+	if err := checkID(id, er.EmptySandboxID); err != nil {
+		return err
+	}
+	return s.save(id, s.sandboxFile(id), rec)
+}
+
+// LoadSandbox decodes the record of sandbox id into rec, it returns
+// SandboxNotFound when the sandbox has no record.
+func (s *Store) LoadSandbox(id string, rec any) error {
+	if err := checkID(id, er.EmptySandboxID); err != nil {
+		return err
+	}
+	return s.load(id, s.sandboxFile(id), s.legacySandboxFile(id), rec, er.SandboxNotFound)
+}
+
+// SaveContainer replaces the record of container id of sandbox sandboxID with rec.
+func (s *Store) SaveContainer(sandboxID, id string, rec any) error {
+	if err := checkID(sandboxID, er.EmptySandboxID); err != nil {
+		return err
+	}
+	if err := checkID(id, er.EmptyContainerID); err != nil {
+		return err
+	}
+	return s.save(sandboxID, s.containerFile(sandboxID, id), rec)
+}
+
+// LoadContainer decodes the record of container id into rec, it returns
+// ContainerNotFound when the container has no record.
+func (s *Store) LoadContainer(sandboxID, id string, rec any) error {
+	if err := checkID(sandboxID, er.EmptySandboxID); err != nil {
+		return err
+	}
+	if err := checkID(id, er.EmptyContainerID); err != nil {
+		return err
+	}
+	return s.load(sandboxID, s.containerFile(sandboxID, id), s.legacyContainerFile(sandboxID, id), rec, er.ContainerNotFound)
+}
+
+// DeleteContainer removes the record of container id, a missing record is not an error.
+func (s *Store) DeleteContainer(sandboxID, id string) error {
+	if err := checkID(sandboxID, er.EmptySandboxID); err != nil {
+		return err
+	}
+	if err := checkID(id, er.EmptyContainerID); err != nil {
+		return err
+	}
+	if err := s.remove(sandboxID, s.legacyContainerFile(sandboxID, id)); err != nil {
+		return err
+	}
+	return s.remove(sandboxID, s.containerFile(sandboxID, id))
+}
+
+// SaveTask replaces the task record of container id with rec. Task records
+// are kept by the shim for what containerd gave it, e.g. the stdio of the task.
+func (s *Store) SaveTask(sandboxID, id string, rec any) error {
+	if err := checkID(sandboxID, er.EmptySandboxID); err != nil {
+		return err
+	}
+	if err := checkID(id, er.EmptyContainerID); err != nil {
+		return err
+	}
+	return s.save(sandboxID, s.taskFile(sandboxID, id), rec)
+}
+
+// LoadTask decodes the task record of container id into rec, it returns
+// ContainerNotFound when the container has no task record.
+func (s *Store) LoadTask(sandboxID, id string, rec any) error {
+	if err := checkID(sandboxID, er.EmptySandboxID); err != nil {
+		return err
+	}
+	if err := checkID(id, er.EmptyContainerID); err != nil {
+		return err
+	}
+	return s.load(sandboxID, s.taskFile(sandboxID, id), "", rec, er.ContainerNotFound)
+}
+
+// DeleteTask removes the task record of container id, a missing record is not an error.
+func (s *Store) DeleteTask(sandboxID, id string) error {
+	if err := checkID(sandboxID, er.EmptySandboxID); err != nil {
+		return err
+	}
+	if err := checkID(id, er.EmptyContainerID); err != nil {
+		return err
+	}
+	return s.remove(sandboxID, s.taskFile(sandboxID, id))
+}
+
+// Sandboxes returns the ids of the sandboxes with records.
+func (s *Store) Sandboxes() ([]string, error) {
+	return listDir(s.root, func(e os.DirEntry) (string, bool) {
+		return e.Name(), e.IsDir()
+	})
+}
+
+// Containers returns the ids of the containers of sandbox sandboxID with records.
+func (s *Store) Containers(sandboxID string) ([]string, error) {
+	if err := checkID(sandboxID, er.EmptySandboxID); err != nil {
+		return nil, err
+	}
+	ids, err := listDir(filepath.Join(s.SandboxDir(sandboxID), defs.ContainerStateDir), func(e os.DirEntry) (string, bool) {
+		// temporary files of WriteFile are hidden
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		return id, ok && !e.IsDir() && !strings.HasPrefix(id, ".")
+	})
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := s.legacyContainers(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range legacy {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// legacyContainers returns the ids of the containers of sandbox sandboxID with
+// records of micrun before the store.
+func (s *Store) legacyContainers(sandboxID string) ([]string, error) {
+	if s.legacyRoot == "" {
+		return nil, nil
+	}
+	return listDir(filepath.Join(s.legacyRoot, sandboxID), func(e os.DirEntry) (string, bool) {
+		_, err := os.Stat(s.legacyContainerFile(sandboxID, e.Name()))
+		return e.Name(), e.IsDir() && err == nil
+	})
+}
+
+// PeekSandbox decodes the record of sandbox id into rec without taking the
+// lock of the sandbox. Records are replaced atomically, the record read is
+// whole but may be replaced right after, so it suits observers only.
+func (s *Store) PeekSandbox(id string, rec any) error {
+	if err := checkID(id, er.EmptySandboxID); err != nil {
+		return err
+	}
+	err := decode(s.sandboxFile(id), rec, er.SandboxNotFound)
+	if err == er.SandboxNotFound && s.legacyRoot != "" {
+		return decode(s.legacySandboxFile(id), rec, er.SandboxNotFound)
+	}
+	return err
+}
+
+// DeleteSandbox removes sandbox id with the records of its containers.
+func (s *Store) DeleteSandbox(id string) error {
+	if err := checkID(id, er.EmptySandboxID); err != nil {
+		return err
+	}
+	unlock, err := s.Lock(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	legacy, err := s.legacyContainers(id)
+	if err != nil {
+		return err
+	}
+	for _, cid := range legacy {
+		if err := s.remove(id, s.legacyContainerFile(id, cid)); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(s.SandboxDir(id)); err != nil {
+		return fmt.Errorf("store: failed to remove sandbox %s: %w", id, err)
+	}
+	// processes waiting for the lock take it again on a new file, see flock
+	if err := os.Remove(s.lockFile(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("store: failed to remove lock of sandbox %s: %w", id, err)
+	}
+	return syncDir(s.root)
+}
+
+func (s *Store) save(sandboxID, path string, rec any) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("store: failed to encode %s: %w", path, err)
+	}
+	unlock, err := s.Lock(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.MkdirAll(filepath.Dir(path), defs.DirMode); err != nil {
+		return fmt.Errorf("store: failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return WriteFile(path, raw, defs.FileMode)
+}
+
+func (s *Store) remove(sandboxID, path string) error {
+	if path == "" {
+		return nil
+	}
+	unlock, err := s.Lock(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.removeFile(path)
+}
+
+// removeFile removes path. The directories of a legacy record are removed
+// with it once they are empty.
+func (s *Store) removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("store: failed to remove %s: %w", path, err)
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if s.legacyRoot == "" || filepath.Base(path) != defs.LegacyStateFile {
+		return nil
+	}
+	// other files of micrun before the store may be left in them
+	for dir := filepath.Dir(path); dir != s.legacyRoot && dir != s.root; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// load decodes the record at path into rec. A record of micrun before the
+// store found at legacy is moved to path first.
+func (s *Store) load(sandboxID, path, legacy string, rec any, notFound error) error {
+	unlock, err := s.Lock(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.upgrade(path, legacy); err != nil {
+		return err
+	}
+	return decode(path, rec, notFound)
+}
+
+// upgrade moves the legacy record to path unless path has a record already,
+// the caller holds the lock of the sandbox. The record is moved as it is, its
+// schema is upgraded by the caller decoding it.
+func (s *Store) upgrade(path, legacy string) error {
+	if legacy == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	raw, err := os.ReadFile(legacy)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("store: failed to read %s: %w", legacy, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), defs.DirMode); err != nil {
+		return fmt.Errorf("store: failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := WriteFile(path, raw, defs.FileMode); err != nil {
+		return err
+	}
+	return s.removeFile(legacy)
+}
+
+func decode(path string, rec any, notFound error) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return notFound
+	}
+	if err != nil {
+		return fmt.Errorf("store: failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, rec); err != nil {
+		return fmt.Errorf("store: corrupted record %s: %w", path, err)
+	}
+	return nil
+}
+
+func listDir(dir string, keep func(os.DirEntry) (string, bool)) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to list %s: %w", dir, err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := keep(e); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// WriteFile replaces path with data: the data is written and synced to a
+// temporary file of the same directory which is then renamed over path, and
+// the directory is synced so that the rename survives a power loss.
+func WriteFile(path string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("store: failed to create temporary file in %s: %w", dir, err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return fmt.Errorf("store: failed to write %s: %w", tmp.Name(), err)
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("store: failed to sync %s: %w", tmp.Name(), err)
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("store: failed to replace %s: %w", path, err)
+	}
+	return syncDir(dir)
+}
+
+// UpdateRecord loads the JSON record at path into rec, runs fn and writes rec
+// back with WriteFile, all under an exclusive flock(2) of path+".lock". rec is
+// left as given when path does not exist yet, and path is removed when fn
+// returns keep false. It serves host wide records outside of the sandbox tree.
+func UpdateRecord(path string, rec any, fn func() (keep bool, err error)) error {
+	if err := os.MkdirAll(filepath.Dir(path), defs.DirMode); err != nil {
+		return fmt.Errorf("store: failed to create %s: %w", filepath.Dir(path), err)
+	}
+	lock, err := os.OpenFile(path+lockSuffix, os.O_CREATE|os.O_RDWR, defs.FileMode)
+	if err != nil {
+		return fmt.Errorf("store: failed to open lock of %s: %w", path, err)
+	}
+	defer lock.Close()
+	if err := unix.Flock(int(lock.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("store: failed to lock %s: %w", path, err)
+	}
+	defer unix.Flock(int(lock.Fd()), unix.LOCK_UN)
+
+	if raw, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(raw, rec); err != nil {
+			return fmt.Errorf("store: corrupted record %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("store: failed to read %s: %w", path, err)
+	}
+
+	keep, err := fn()
+	if err != nil {
+		return err
+	}
+	if !keep {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("store: failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, raw, defs.FileMode)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("store: failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// checkID rejects ids which would escape the store root, empty is returned for an empty id.
+func checkID(id string, empty error) error {
+	if id == "" {
+		return empty
+	}
+	if id == "." || id == ".." || strings.ContainsRune(id, '/') {
+		return fmt.Errorf("store: invalid id %q", id)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	er "micrun/errors"
+)
+
+type record struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+}
+
+func TestRecords(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+
+	var got record
+	if err := s.LoadSandbox("pod", &got); err != er.SandboxNotFound {
+		t.Fatalf("LoadSandbox of a missing sandbox: got %v, want SandboxNotFound", err)
+	}
+	if err := s.SaveSandbox("pod", &record{ID: "pod", State: "ready"}); err != nil {
+		t.Fatalf("SaveSandbox: %v", err)
+	}
+	if err := s.SaveSandbox("pod", &record{ID: "pod", State: "running"}); err != nil {
+		t.Fatalf("SaveSandbox: %v", err)
+	}
+	if err := s.LoadSandbox("pod", &got); err != nil || got.State != "running" {
+		t.Fatalf("LoadSandbox: got %+v, %v", got, err)
+	}
+
+	if err := s.SaveContainer("pod", "rtos", &record{ID: "rtos", State: "stopped"}); err != nil {
+		t.Fatalf("SaveContainer: %v", err)
+	}
+	if err := s.LoadContainer("pod", "rtos", &got); err != nil || got.ID != "rtos" {
+		t.Fatalf("LoadContainer: got %+v, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pod", "containers", "rtos.json")); err != nil {
+		t.Fatalf("container record not at the canonical path: %v", err)
+	}
+
+	// no temporary file is left next to the records
+	for _, dir := range []string{filepath.Join(root, "pod"), filepath.Join(root, "pod", "containers")} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && filepath.Ext(e.Name()) != ".json" {
+				t.Errorf("unexpected file %s in %s", e.Name(), dir)
+			}
+		}
+	}
+
+	if ids, err := s.Sandboxes(); err != nil || !reflect.DeepEqual(ids, []string{"pod"}) {
+		t.Fatalf("Sandboxes: got %v, %v", ids, err)
+	}
+	if ids, err := s.Containers("pod"); err != nil || !reflect.DeepEqual(ids, []string{"rtos"}) {
+		t.Fatalf("Containers: got %v, %v", ids, err)
+	}
+	if err := s.PeekSandbox("pod", &got); err != nil || got.State != "running" {
+		t.Fatalf("PeekSandbox: got %+v, %v", got, err)
+	}
+
+	if err := s.SaveTask("pod", "rtos", &record{ID: "rtos", State: "running"}); err != nil {
+		t.Fatalf("SaveTask: %v", err)
+	}
+	if err := s.LoadTask("pod", "rtos", &got); err != nil || got.State != "running" {
+		t.Fatalf("LoadTask: got %+v, %v", got, err)
+	}
+	if err := s.DeleteTask("pod", "rtos"); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := s.LoadTask("pod", "rtos", &got); err != er.ContainerNotFound {
+		t.Fatalf("LoadTask after delete: got %v, want ContainerNotFound", err)
+	}
+
+	if err := s.DeleteContainer("pod", "rtos"); err != nil {
+		t.Fatalf("DeleteContainer: %v", err)
+	}
+	if err := s.LoadContainer("pod", "rtos", &got); err != er.ContainerNotFound {
+		t.Fatalf("LoadContainer after delete: got %v, want ContainerNotFound", err)
+	}
+	if err := s.DeleteContainer("pod", "rtos"); err != nil {
+		t.Fatalf("DeleteContainer of a missing record: %v", err)
+	}
+
+	if err := s.DeleteSandbox("pod"); err != nil {
+		t.Fatalf("DeleteSandbox: %v", err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Fatalf("DeleteSandbox left %d entries in the store", len(entries))
+	}
+
+	for _, id := range []string{"", "..", "a/b"} {
+		if err := s.SaveSandbox(id, &record{}); err == nil {
+			t.Errorf("SaveSandbox accepted id %q", id)
+		}
+	}
+}
+
+func TestLegacyRecords(t *testing.T) {
+	// the layout of micrun before the store, the store root lies in the legacy root
+	legacyRoot := t.TempDir()
+	root := filepath.Join(legacyRoot, "sandbox")
+	s := NewUpgrading(root, legacyRoot)
+	write := func(path, state string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(`{"id": "x", "state": "`+state+`"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, "pod", "state.json"), "running")
+	write(filepath.Join(legacyRoot, "pod", "rtos", "state.json"), "running")
+	write(filepath.Join(legacyRoot, "pod", "linux", "state.json"), "stopped")
+
+	var got record
+	if err := s.PeekSandbox("pod", &got); err != nil || got.State != "running" {
+		t.Fatalf("PeekSandbox of a legacy record: got %+v, %v", got, err)
+	}
+	if ids, err := s.Containers("pod"); err != nil || !reflect.DeepEqual(ids, []string{"linux", "rtos"}) {
+		t.Fatalf("Containers: got %v, %v", ids, err)
+	}
+
+	// loaded records are moved to the paths of the store
+	if err := s.LoadSandbox("pod", &got); err != nil || got.State != "running" {
+		t.Fatalf("LoadSandbox of a legacy record: got %+v, %v", got, err)
+	}
+	if err := s.LoadContainer("pod", "rtos", &got); err != nil || got.State != "running" {
+		t.Fatalf("LoadContainer of a legacy record: got %+v, %v", got, err)
+	}
+	for _, path := range []string{filepath.Join(root, "pod", "sandbox.json"), filepath.Join(root, "pod", "containers", "rtos.json")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("record not moved: %v", err)
+		}
+	}
+	for _, path := range []string{filepath.Join(root, "pod", "state.json"), filepath.Join(legacyRoot, "pod", "rtos")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("legacy record %s left: %v", path, err)
+		}
+	}
+	// the record of the store wins over a legacy one
+	write(filepath.Join(legacyRoot, "pod", "rtos", "state.json"), "stopped")
+	if err := s.LoadContainer("pod", "rtos", &got); err != nil || got.State != "running" {
+		t.Fatalf("LoadContainer: got %+v, %v", got, err)
+	}
+	if ids, err := s.Containers("pod"); err != nil || !reflect.DeepEqual(ids, []string{"rtos", "linux"}) {
+		t.Fatalf("Containers: got %v, %v", ids, err)
+	}
+
+	if err := s.DeleteSandbox("pod"); err != nil {
+		t.Fatalf("DeleteSandbox: %v", err)
+	}
+	if entries, _ := os.ReadDir(legacyRoot); len(entries) != 1 {
+		t.Fatalf("DeleteSandbox left %d entries next to the store", len(entries)-1)
+	}
+}
+
+func TestLock(t *testing.T) {
+	root := t.TempDir()
+	shim, cleanup := New(root), New(root)
+
+	unlock, err := shim.Lock("pod")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	// the lock holder can still use its records
+	if err := shim.SaveSandbox("pod", &record{ID: "pod"}); err != nil {
+		t.Fatalf("SaveSandbox under lock: %v", err)
+	}
+
+	// a second store stands for another process, e.g. shim delete
+	locked := make(chan func())
+	go func() {
+		u, err := cleanup.Lock("pod")
+		if err != nil {
+			t.Errorf("Lock: %v", err)
+		}
+		locked <- u
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the lock is held twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the sandbox is deleted while the other process waits for it
+	if err := shim.DeleteSandbox("pod"); err != nil {
+		t.Fatalf("DeleteSandbox: %v", err)
+	}
+	unlock()
+	unlock2 := <-locked
+	defer unlock2()
+	if _, err := os.Stat(filepath.Join(root, "pod.lock")); err != nil {
+		t.Fatalf("lock taken on a removed file: %v", err)
+	}
+}
+
+func TestUpdateRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "claims.json")
+	add := func(key string) error {
+		claims := map[string]int{}
+		return UpdateRecord(path, &claims, func() (bool, error) {
+			claims[key] = len(claims)
+			return true, nil
+		})
+	}
+	if err := add("a"); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	if err := add("b"); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	claims := map[string]int{}
+	err := UpdateRecord(path, &claims, func() (bool, error) {
+		if want := map[string]int{"a": 0, "b": 1}; !reflect.DeepEqual(claims, want) {
+			t.Errorf("claims %v, want %v", claims, want)
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("record not removed: %v", err)
+	}
+}
@@ -181,20 +181,6 @@ func SetReadonly(path string) error {
 	})
 }
 
-// remove state file in micran state directory
-func RemoveExternalStatFile(id string) error {
-	// if the file does not exist, return nil
-	path := filepath.Join(defs.MicrunStateDir, id, defs.MicrunContainerStateFile)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil
-	}
-	return os.Remove(path)
-}
-
-func RemoveStateDir(id string) error {
-	return os.RemoveAll(filepath.Join(defs.MicrunStateDir, id))
-}
-
 func RemoveContainerCacheDir(id string) error {
 	if strings.TrimSpace(id) == "" {
 		return fmt.Errorf("container id cannot be empty")